@@ -5,19 +5,66 @@ import (
 )
 
 func lbcdEncode(data []byte) ([]byte, error) {
+	return lbcdPadEncode(data, '0')
+}
+
+func rbcdEncode(data []byte) ([]byte, error) {
+	return rbcdPadEncode(data, '0')
+}
+
+//lbcdPadEncode fills the odd last nibble with the hex digit pad
+func lbcdPadEncode(data []byte, pad byte) ([]byte, error) {
 	if len(data)%2 != 0 {
-		return bcdEncode(append(data, "0"...))
+		return bcdEncode(append(data, pad))
 	}
 	return bcdEncode(data)
 }
 
-func rbcdEncode(data []byte) ([]byte, error) {
+//rbcdPadEncode fills the odd first nibble with the hex digit pad
+func rbcdPadEncode(data []byte, pad byte) ([]byte, error) {
 	if len(data)%2 != 0 {
-		return bcdEncode(append([]byte("0"), data...))
+		return bcdEncode(append([]byte{pad}, data...))
 	}
 	return bcdEncode(data)
 }
 
+//appendBcd packs the hex digits at dst[start:] in place, an odd count of digits is filled
+//with the hex digit pad in the first nibble when right is set and in the last nibble otherwise
+func appendBcd(dst []byte, start int, pad byte, right bool) ([]byte, error) {
+	if (len(dst)-start)%2 != 0 {
+		if right {
+			dst = insertBytes(dst, start, []byte{pad})
+		} else {
+			dst = append(dst, pad)
+		}
+	}
+	n := (len(dst) - start) / 2
+	for i := 0; i < n; i++ {
+		hi, ok := fromHexChar(dst[start+2*i])
+		if !ok {
+			return nil, hex.InvalidByteError(dst[start+2*i])
+		}
+		lo, ok := fromHexChar(dst[start+2*i+1])
+		if !ok {
+			return nil, hex.InvalidByteError(dst[start+2*i+1])
+		}
+		dst[start+i] = hi<<4 | lo
+	}
+	return dst[:start+n], nil
+}
+
+func fromHexChar(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
 func bcdEncode(data []byte) ([]byte, error) {
 	out := make([]byte, len(data)/2+1)
 	n, err := hex.Decode(out, data)
@@ -4,72 +4,222 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"io/ioutil"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
-	"golang.org/x/net/html/charset"
-	"golang.org/x/text/transform"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
 )
 
+//CodepageEncoder converts utf8 bytes to bytes in a code page
+type CodepageEncoder func(utf8 []byte) ([]byte, error)
+
+//CodepageDecoder converts bytes in a code page to utf8 bytes
+type CodepageDecoder func(b []byte) ([]byte, error)
+
+type codepage struct {
+	encode CodepageEncoder
+	decode CodepageDecoder
+}
+
+var (
+	codepageLock sync.RWMutex
+	codepages    = map[string]codepage{
+		string(hexstring): {
+			encode: hexstringEncode,
+			decode: hexstringDecode,
+		},
+	}
+	//labels resolved through x/text, filled on first use
+	resolvedCodepages sync.Map
+)
+
+//RegisterCodepage makes an in-house code page available to struct tags as cp:"name".
+//A registered name takes precedence over the IANA and WHATWG labels known to x/text
+func RegisterCodepage(name string, enc CodepageEncoder, dec CodepageDecoder) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return fmt.Errorf("codepage name must be specified")
+	}
+	if enc == nil || dec == nil {
+		return fmt.Errorf("codepage %s encoder and decoder must not be nil", name)
+	}
+	codepageLock.Lock()
+	defer codepageLock.Unlock()
+	if _, ok := codepages[name]; ok {
+		return fmt.Errorf("codepage %s is already registered", name)
+	}
+	codepages[name] = codepage{
+		encode: enc,
+		decode: dec,
+	}
+	return nil
+}
+
+//TableCodepage builds a single byte code page from decodeTable,
+//decodeTable[b] holds the utf8 bytes of the character b
+func TableCodepage(decodeTable [][]byte) (CodepageEncoder, CodepageDecoder) {
+	encodeTable := make(map[string]byte, len(decodeTable))
+	for i, utf := range decodeTable {
+		if i > 0xff || len(utf) == 0 {
+			continue
+		}
+		if _, ok := encodeTable[string(utf)]; !ok {
+			encodeTable[string(utf)] = byte(i)
+		}
+	}
+	enc := func(utf []byte) ([]byte, error) {
+		return encodeUtf8(encodeTable, utf)
+	}
+	dec := func(b []byte) ([]byte, error) {
+		return decodeUtf8(decodeTable, b), nil
+	}
+	return enc, dec
+}
+
+func lookupCodepage(name string) (codepage, bool) {
+	codepageLock.RLock()
+	cp, ok := codepages[name]
+	codepageLock.RUnlock()
+	if ok {
+		return cp, true
+	}
+	if v, ok := resolvedCodepages.Load(name); ok {
+		return v.(codepage), true
+	}
+	e := lookupEncoding(name)
+	if e == nil {
+		return codepage{}, false
+	}
+	cp = codepage{
+		encode: func(b []byte) ([]byte, error) {
+			return e.NewEncoder().Bytes(b)
+		},
+		decode: func(b []byte) ([]byte, error) {
+			return e.NewDecoder().Bytes(b)
+		},
+	}
+	resolvedCodepages.Store(name, cp)
+	return cp, true
+}
+
+//lookupEncoding prefers WHATWG labels, as the library always did, then IANA names
+func lookupEncoding(name string) encoding.Encoding {
+	if e, err := htmlindex.Get(name); err == nil && e != nil {
+		return e
+	}
+	if e, err := ianaindex.IANA.Encoding(name); err == nil && e != nil {
+		return e
+	}
+	return nil
+}
+
 func decodeUtf8(decodeTable [][]byte, ebc []byte) []byte {
 	var uni []byte
 	for _, v := range ebc {
-		subArr := decodeTable[v]
-		for _, b := range subArr {
-			uni = append(uni, b)
+		if int(v) >= len(decodeTable) || len(decodeTable[v]) == 0 {
+			uni = append(uni, string(utf8.RuneError)...)
+			continue
 		}
+		uni = append(uni, decodeTable[v]...)
 	}
 	return uni
 }
 
-func encodeUtf8(encodeTable map[string]byte, utf []byte) []byte {
+func encodeUtf8(encodeTable map[string]byte, utf []byte) ([]byte, error) {
 	var ebc []byte
 	for _, ch := range string(utf) {
-		ebc = append(ebc, encodeTable[fmt.Sprintf("%c", ch)])
+		b, ok := encodeTable[string(ch)]
+		if !ok {
+			return nil, fmt.Errorf("character %q is not in codepage", ch)
+		}
+		ebc = append(ebc, b)
 	}
-	return ebc
+	return ebc, nil
 }
 
-//DecodeUTF8 is converting byte with codepage to byte in utf8
-func decodeUTF8(codePage string, b []byte) []byte {
-	if codePage == "hexstring" {
-		return []byte(strings.ToUpper(hex.EncodeToString(b)))
-	}
-	return defaultDecodeUtf8(codePage, b)
+func hexstringEncode(b []byte) ([]byte, error) {
+	return hex.DecodeString(string(b))
 }
 
-//EncodeUTF8 is converting byte in utf8 to byte in codepage
-func encodeUTF8(codePage string, b []byte) []byte {
-	if codePage == "hexstring" {
-		hb, err := hex.DecodeString(string(b))
-		if err != nil {
-			return b
+func hexstringDecode(b []byte) ([]byte, error) {
+	return []byte(strings.ToUpper(hex.EncodeToString(b))), nil
+}
+
+//padding returns n bytes of the pad character in codePage, hexstring is padded as is.
+//A pad character of several bytes must fill n exactly
+func padding(codePage string, pad string, n int) ([]byte, error) {
+	unit := []byte(pad)
+	if codePage != "" && codePage != string(hexstring) {
+		var err error
+		if unit, err = encodeUTF8(codePage, failPolicy, unit); err != nil {
+			return nil, err
 		}
-		return hb
 	}
-	return defaultEncodeUtf8(codePage, b)
+	if n <= 0 {
+		return nil, nil
+	}
+	if len(unit) == 0 || n%len(unit) != 0 {
+		return nil, fmt.Errorf("pad %q is %d bytes in codepage %s and cannot fill %d bytes", pad, len(unit), codePage, n)
+	}
+	return bytes.Repeat(unit, n/len(unit)), nil
 }
 
-func defaultDecodeUtf8(codePage string, b []byte) []byte {
-	newCodeReader := bytes.NewBuffer(b)
-	reader, err := charset.NewReaderLabel(codePage, newCodeReader)
-	if err != nil {
-		return b
+//DecodeUTF8 is converting byte with codepage to byte in utf8,
+//bytes without a character are handled by policy
+func decodeUTF8(codePage string, policy codepagePolicy, b []byte) ([]byte, error) {
+	cp, ok := lookupCodepage(codePage)
+	if !ok {
+		return nil, fmt.Errorf("codepage %s is not supported", codePage)
 	}
-
-	nb, err := ioutil.ReadAll(reader)
+	nb, err := cp.decode(b)
 	if err != nil {
-		return b
+		return nil, fmt.Errorf("codepage %s decode failed %s", codePage, err.Error())
 	}
-	return nb
+	if !bytes.ContainsRune(nb, utf8.RuneError) {
+		return nb, nil
+	}
+	switch policy {
+	case lenientPolicy:
+		return nb, nil
+	case replacePolicy:
+		return bytes.Replace(nb, []byte(string(utf8.RuneError)), []byte("?"), -1), nil
+	case dropPolicy:
+		return bytes.Replace(nb, []byte(string(utf8.RuneError)), nil, -1), nil
+	}
+	return nil, fmt.Errorf("codepage %s decode failed data %X has no character", codePage, b)
 }
 
-func defaultEncodeUtf8(codePage string, b []byte) []byte {
-	e, _ := charset.Lookup(codePage)
-	reader := transform.NewReader(bytes.NewReader(b), e.NewEncoder())
-	nb, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return b
+//EncodeUTF8 is converting byte in utf8 to byte in codepage,
+//characters the codepage cannot represent are handled by policy, a field without a policy replaces them
+func encodeUTF8(codePage string, policy codepagePolicy, b []byte) ([]byte, error) {
+	cp, ok := lookupCodepage(codePage)
+	if !ok {
+		return nil, fmt.Errorf("codepage %s is not supported", codePage)
+	}
+	nb, err := cp.encode(b)
+	if err == nil {
+		return nb, nil
+	}
+	if policy == failPolicy || codePage == string(hexstring) {
+		return nil, fmt.Errorf("codepage %s encode failed %s", codePage, err.Error())
+	}
+	//find the characters that fail on their own, then encode the rest in one pass
+	//so a stateful code page such as iso-2022-jp keeps its shift state
+	var text []byte
+	for _, ch := range string(b) {
+		if _, err := cp.encode([]byte(string(ch))); err != nil || ch == utf8.RuneError {
+			if policy != dropPolicy {
+				text = append(text, '?')
+			}
+			continue
+		}
+		text = utf8.AppendRune(text, ch)
+	}
+	if nb, err = cp.encode(text); err != nil {
+		return nil, fmt.Errorf("codepage %s encode failed %s", codePage, err.Error())
 	}
-	return nb
+	return nb, nil
 }
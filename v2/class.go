@@ -0,0 +1,100 @@
+package iso8583v2
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+const noClass attributeClass = ""
+
+//attributeClasses are the ISO attribute classes, a for letters, n for digits, s for special characters
+//including space, b for binary, z for track 2 and 3 code sets and x+n for a C or D sign before digits
+var attributeClasses = map[attributeClass]struct{}{
+	"a": {}, "n": {}, "s": {}, "an": {}, "as": {}, "ns": {}, "ans": {},
+	"b": {}, "z": {}, "x+n": {},
+}
+
+//strictClass returns the class Strict checks for t, the class of the tag or of its dictionary entry,
+//otherwise n for numeric and ans for alpha fields
+func strictClass(t iso8583Tag) attributeClass {
+	if t.class != noClass {
+		return t.class
+	}
+	switch t.fieldType {
+	case numeric:
+		return "n"
+	case alpha:
+		return "ans"
+	}
+	return noClass
+}
+
+//check reports the first character of text outside class c
+func (c attributeClass) check(text []byte) error {
+	switch c {
+	case noClass, "b":
+		return nil
+	case "x+n":
+		if len(text) > 0 && (text[0] == 'C' || text[0] == 'D') {
+			if err := attributeClass("n").check(text[1:]); err != nil {
+				return fmt.Errorf("%s after the sign", err.Error())
+			}
+			return nil
+		}
+		return fmt.Errorf("class x+n value must start with C or D")
+	}
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		if !c.accepts(r) {
+			return fmt.Errorf("character %q at %d is not class %s", r, i, c)
+		}
+		i += size
+	}
+	return nil
+}
+
+func (c attributeClass) accepts(r rune) bool {
+	if c == "z" {
+		return ('0' <= r && r <= '9') || r == 'D' || r == '=' || r == ';' || r == '?' || r == ':' || r == '<' || r == '>'
+	}
+	for _, k := range c {
+		switch {
+		case k == 'a' && unicode.IsLetter(r):
+			return true
+		case k == 'n' && '0' <= r && r <= '9':
+			return true
+		case k == 's' && r != utf8.RuneError && unicode.IsPrint(r) && !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			return true
+		}
+	}
+	return false
+}
+
+//checkClass checks the text of a field value in strict mode,
+//trailing spaces of an alpha field are padding
+func checkClass(t iso8583Tag, text []byte, decoded bool) error {
+	c := strictClass(t)
+	if decoded && t.fieldType == alpha {
+		text = trimRightSpace(text)
+	}
+	if err := c.check(text); err != nil {
+		return fmt.Errorf("field:%s %s", t.name, err.Error())
+	}
+	return nil
+}
+
+//checkSubfieldClass checks the text of a fixed width sub field in strict mode, only its tag gives a class
+func checkSubfieldClass(t fixedwidthTag, text []byte) error {
+	if err := t.class.check(text); err != nil {
+		return fmt.Errorf("subfield:%s %s", t.name, err.Error())
+	}
+	return nil
+}
+
+func trimRightSpace(b []byte) []byte {
+	for len(b) > 0 && b[len(b)-1] == ' ' {
+		b = b[:len(b)-1]
+	}
+	return b
+}
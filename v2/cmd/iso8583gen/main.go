@@ -0,0 +1,637 @@
+// Command iso8583gen writes MarshalISO8583 and UnmarshalISO8583 methods for
+// structs tagged for iso8583v2, so Marshal and Unmarshal can skip reflection.
+//
+// It is meant to be run by go generate from the package of the structs
+//
+//	//go:generate go run github.com/henglory/iso8583/v2/cmd/iso8583gen -type Message
+//
+// Next to the methods it writes a test checking that the generated code and
+// the reflective path produce the same bytes and values.
+// Fields with a marshaler, a field type or code page registered by the program
+// and struct fields of type tlv are not supported by the generator
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	iso8583v2 "github.com/henglory/iso8583/v2"
+)
+
+const importPath = "github.com/henglory/iso8583/v2"
+
+type kind int
+
+const (
+	kindString kind = iota + 1
+	kindInt
+	kindFloat
+	kindBytes
+	kindStruct
+)
+
+type goType struct {
+	kind kind
+	name string
+	ptr  bool
+	bits int
+	sub  []*subfield
+}
+
+type field struct {
+	name string
+	tag  string
+	typ  goType
+	gen  *iso8583v2.GenField
+}
+
+type subfield struct {
+	name string
+	tag  string
+	typ  goType
+	gen  *iso8583v2.GenSubfield
+}
+
+type message struct {
+	name   string
+	dict   string
+	mti    *field
+	fields []*field
+}
+
+type generator struct {
+	pkg        string
+	types      map[string]*ast.TypeSpec
+	marshalers map[string]bool
+}
+
+//types with these methods are encoded through the marshaler by Marshal
+var marshalerMethods = map[string]bool{
+	"MarshalText":           true,
+	"UnmarshalText":         true,
+	"MarshalISO8583Field":   true,
+	"UnmarshalISO8583Field": true,
+}
+
+func main() {
+	typeNames := flag.String("type", "", "comma separated list of struct type names, must be set")
+	output := flag.String("output", "", "output file name, default <type>_iso8583.go")
+	tests := flag.Bool("tests", true, "write a test comparing generated and reflective output")
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	if err := run(dir, strings.Split(*typeNames, ","), *output, *tests); err != nil {
+		fmt.Fprintf(os.Stderr, "iso8583gen: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(dir string, names []string, output string, tests bool) error {
+	src, test, err := generate(dir, names)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		output = strings.ToLower(strings.TrimSpace(names[0])) + "_iso8583.go"
+	}
+	output = filepath.Join(dir, output)
+	if err := os.WriteFile(output, src, 0644); err != nil {
+		return err
+	}
+	if !tests {
+		return nil
+	}
+	return os.WriteFile(strings.TrimSuffix(output, ".go")+"_test.go", test, 0644)
+}
+
+//generate returns the formatted source of the methods and of their test
+func generate(dir string, names []string) ([]byte, []byte, error) {
+	g, err := load(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var msgs []*message
+	for _, name := range names {
+		m, err := g.message(strings.TrimSpace(name))
+		if err != nil {
+			return nil, nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	src, err := format.Source(g.source(msgs, names))
+	if err != nil {
+		return nil, nil, fmt.Errorf("format generated code failed %s", err.Error())
+	}
+	test, err := format.Source(g.test(msgs, names))
+	if err != nil {
+		return nil, nil, fmt.Errorf("format generated test failed %s", err.Error())
+	}
+	return src, test, nil
+}
+
+func load(dir string) (*generator, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("%s must contain exactly one package, found %d", dir, len(pkgs))
+	}
+	g := &generator{
+		types:      make(map[string]*ast.TypeSpec),
+		marshalers: make(map[string]bool),
+	}
+	for name, pkg := range pkgs {
+		g.pkg = name
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				switch d := decl.(type) {
+				case *ast.GenDecl:
+					for _, spec := range d.Specs {
+						if ts, ok := spec.(*ast.TypeSpec); ok {
+							g.types[ts.Name.Name] = ts
+						}
+					}
+				case *ast.FuncDecl:
+					if d.Recv == nil || len(d.Recv.List) == 0 || !marshalerMethods[d.Name.Name] {
+						continue
+					}
+					recv := d.Recv.List[0].Type
+					if star, ok := recv.(*ast.StarExpr); ok {
+						recv = star.X
+					}
+					if id, ok := recv.(*ast.Ident); ok {
+						g.marshalers[id.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return g, nil
+}
+
+func (g *generator) message(name string) (*message, error) {
+	ts, ok := g.types[name]
+	if !ok {
+		return nil, fmt.Errorf("type %s is not found in package %s", name, g.pkg)
+	}
+	st, ok := ts.Type.(*ast.StructType)
+	if !ok || ts.TypeParams != nil {
+		return nil, fmt.Errorf("type %s must be a struct without type parameters", name)
+	}
+	m := &message{name: name, dict: iso8583v2.DefaultDictionary}
+	//the mti names the dictionary of the other fields
+	for _, af := range st.Fields.List {
+		tag, err := fieldTag(af)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+		for _, id := range af.Names {
+			if gen, err := iso8583v2.ParseGenField(id.Name, tag); err == nil && gen.IsMTI() {
+				m.dict = gen.Dictionary()
+			}
+		}
+	}
+	for _, af := range st.Fields.List {
+		tag, err := fieldTag(af)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+		if len(af.Names) == 0 {
+			if tag != "" {
+				return nil, fmt.Errorf("%s: embedded field %s is not supported", name, types.ExprString(af.Type))
+			}
+			continue
+		}
+		for _, id := range af.Names {
+			f, err := g.field(m.dict, id.Name, tag, af.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %s", name, id.Name, err.Error())
+			}
+			if f == nil {
+				continue
+			}
+			if f.gen.IsMTI() {
+				m.mti = f
+				continue
+			}
+			m.fields = append(m.fields, f)
+		}
+	}
+	if m.mti == nil {
+		return nil, fmt.Errorf("%s: mti is required", name)
+	}
+	return m, nil
+}
+
+//field returns nil for fields Marshal ignores
+func (g *generator) field(dict string, name string, tag string, expr ast.Expr) (*field, error) {
+	gen, err := iso8583v2.ParseGenFieldDict(dict, name, tag)
+	if err != nil {
+		if _, namespaced := reflect.StructTag(tag).Lookup("iso8583"); namespaced || reflect.StructTag(tag).Get("type") != "" {
+			return nil, fmt.Errorf("%s, registered field types and code pages are not supported", err.Error())
+		}
+		return nil, nil
+	}
+	if !ast.IsExported(name) {
+		return nil, fmt.Errorf("unexported field cannot be set by Unmarshal")
+	}
+	f := &field{name: name, tag: tag, gen: gen}
+	if f.typ, err = g.resolve(expr, true); err != nil {
+		return nil, err
+	}
+	if gen.IsMTI() {
+		if f.typ.kind != kindString || f.typ.ptr {
+			return nil, fmt.Errorf("mti type must be string")
+		}
+		return f, nil
+	}
+	if gen.Tag().Type == "binary" && (f.typ.kind != kindBytes || f.typ.ptr) {
+		return nil, fmt.Errorf("binary field must be []byte")
+	}
+	if f.typ.kind == kindStruct {
+		if gen.Tag().Type == "tlv" {
+			return nil, fmt.Errorf("tlv struct field is not supported, use []byte")
+		}
+		if f.typ.sub, err = g.subfields(gen, f.typ.name); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (g *generator) subfields(parent *iso8583v2.GenField, name string) ([]*subfield, error) {
+	typ := g.types[name].Type
+	for id, ok := typ.(*ast.Ident); ok; id, ok = typ.(*ast.Ident) {
+		typ = g.types[id.Name].Type
+	}
+	st := typ.(*ast.StructType)
+	var subs []*subfield
+	for _, af := range st.Fields.List {
+		tag, err := fieldTag(af)
+		if err != nil {
+			return nil, err
+		}
+		if len(af.Names) == 0 {
+			if tag != "" {
+				return nil, fmt.Errorf("%s: embedded field %s is not supported", name, types.ExprString(af.Type))
+			}
+			continue
+		}
+		for _, id := range af.Names {
+			gen, err := iso8583v2.ParseGenSubfield(parent, id.Name, tag)
+			if err != nil {
+				st := reflect.StructTag(tag)
+				if _, namespaced := st.Lookup("iso8583"); namespaced || st.Get("field") != "" && st.Get("length") != "" {
+					return nil, fmt.Errorf("%s.%s: %s", name, id.Name, err.Error())
+				}
+				continue
+			}
+			if !ast.IsExported(id.Name) {
+				return nil, fmt.Errorf("%s.%s: unexported field cannot be set by Unmarshal", name, id.Name)
+			}
+			typ, err := g.resolve(af.Type, false)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %s", name, id.Name, err.Error())
+			}
+			subs = append(subs, &subfield{name: id.Name, tag: tag, typ: typ, gen: gen})
+		}
+	}
+	return subs, nil
+}
+
+func fieldTag(af *ast.Field) (string, error) {
+	if af.Tag == nil {
+		return "", nil
+	}
+	tag, err := strconv.Unquote(af.Tag.Value)
+	if err != nil {
+		return "", fmt.Errorf("invalid tag %s", af.Tag.Value)
+	}
+	return tag, nil
+}
+
+//resolve maps a field type to the way it is encoded, structs are only allowed when allowStruct is set
+func (g *generator) resolve(expr ast.Expr, allowStruct bool) (goType, error) {
+	var t goType
+	if star, ok := expr.(*ast.StarExpr); ok {
+		t.ptr = true
+		expr = star.X
+	}
+	t.name = types.ExprString(expr)
+	k, bits, err := g.kind(expr, allowStruct)
+	if err != nil {
+		return t, err
+	}
+	t.kind, t.bits = k, bits
+	return t, nil
+}
+
+func (g *generator) kind(expr ast.Expr, allowStruct bool) (kind, int, error) {
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return g.kind(e.X, allowStruct)
+	case *ast.ArrayType:
+		if id, ok := e.Elt.(*ast.Ident); ok && e.Len == nil && (id.Name == "byte" || id.Name == "uint8") {
+			return kindBytes, 0, nil
+		}
+	case *ast.Ident:
+		switch e.Name {
+		case "string":
+			return kindString, 0, nil
+		case "int", "int8", "int16", "int32", "int64":
+			return kindInt, 0, nil
+		case "float32":
+			return kindFloat, 32, nil
+		case "float64":
+			return kindFloat, 64, nil
+		}
+		ts, ok := g.types[e.Name]
+		if !ok || ts.TypeParams != nil {
+			break
+		}
+		if g.marshalers[e.Name] {
+			return 0, 0, fmt.Errorf("type %s has a marshaler which is not supported", e.Name)
+		}
+		if _, ok := ts.Type.(*ast.StructType); ok {
+			if !allowStruct {
+				return 0, 0, fmt.Errorf("struct type %s is not supported in a fixed width field", e.Name)
+			}
+			return kindStruct, 0, nil
+		}
+		if _, ok := ts.Type.(*ast.StarExpr); !ok {
+			return g.kind(ts.Type, allowStruct)
+		}
+	}
+	return 0, 0, fmt.Errorf("type %s is not supported", types.ExprString(expr))
+}
+
+func (g *generator) header(b *bytes.Buffer, names []string) {
+	fmt.Fprintf(b, "// Code generated by iso8583gen -type %s; DO NOT EDIT.\n\n", strings.Join(names, ","))
+	fmt.Fprintf(b, "package %s\n\n", g.pkg)
+}
+
+func fieldVar(m *message, f *field) string {
+	return "_iso8583" + m.name + "_" + f.name
+}
+
+func subVar(m *message, f *field, s *subfield) string {
+	return fieldVar(m, f) + "_" + s.name
+}
+
+func (g *generator) source(msgs []*message, names []string) []byte {
+	var b bytes.Buffer
+	g.header(&b, names)
+	fmt.Fprintf(&b, "import iso8583v2 %q\n\n", importPath)
+	for _, m := range msgs {
+		b.WriteString("var (\n")
+		fmt.Fprintf(&b, "%s = iso8583v2.MustGenField(%q, %q)\n", fieldVar(m, m.mti), m.mti.name, m.mti.tag)
+		for _, f := range m.fields {
+			if m.dict != iso8583v2.DefaultDictionary {
+				fmt.Fprintf(&b, "%s = iso8583v2.MustGenFieldDict(%q, %q, %q)\n", fieldVar(m, f), m.dict, f.name, f.tag)
+			} else {
+				fmt.Fprintf(&b, "%s = iso8583v2.MustGenField(%q, %q)\n", fieldVar(m, f), f.name, f.tag)
+			}
+			for _, s := range f.typ.sub {
+				fmt.Fprintf(&b, "%s = iso8583v2.MustGenSubfield(%s, %q, %q)\n", subVar(m, f, s), fieldVar(m, f), s.name, s.tag)
+			}
+		}
+		b.WriteString(")\n\n")
+		g.marshal(&b, m)
+		g.unmarshal(&b, m)
+	}
+	return b.Bytes()
+}
+
+func (g *generator) marshal(b *bytes.Buffer, m *message) {
+	fmt.Fprintf(b, "//MarshalISO8583 encodes v without reflection\n")
+	fmt.Fprintf(b, "func (v %s) MarshalISO8583() ([]byte, error) {\n", m.name)
+	b.WriteString("m := iso8583v2.NewGenMessage()\n")
+	fmt.Fprintf(b, "b, err := %s.EncodeMTI(string(v.%s))\n", fieldVar(m, m.mti), m.mti.name)
+	b.WriteString("if err != nil {\nreturn nil, err\n}\nm.SetMTI(b)\n")
+	for _, f := range m.fields {
+		fv := fieldVar(m, f)
+		expr := "v." + f.name
+		if f.typ.ptr {
+			fmt.Fprintf(b, "if %s != nil {\n", expr)
+			if f.typ.kind != kindStruct {
+				expr = "*" + expr
+			}
+		}
+		if f.typ.kind == kindStruct {
+			if !f.typ.ptr {
+				b.WriteString("{\n")
+			}
+			fmt.Fprintf(b, "s := %s.NewStruct()\n", fv)
+			for _, s := range f.typ.sub {
+				sv := subVar(m, f, s)
+				sexpr := expr + "." + s.name
+				if s.typ.ptr {
+					fmt.Fprintf(b, "if %s != nil {\n", sexpr)
+					sexpr = "*" + sexpr
+				}
+				fmt.Fprintf(b, "if b, err = %s; err != nil {\nreturn nil, err\n}\ns.Add(%s, b)\n", encodeCall(sv, s.typ, sexpr), sv)
+				if s.typ.ptr {
+					b.WriteString("}\n")
+				}
+			}
+			b.WriteString("if b, err = s.Bytes(); err != nil {\nreturn nil, err\n}\n")
+			fmt.Fprintf(b, "m.Add(%s, b)\n", fv)
+			if !f.typ.ptr {
+				b.WriteString("}\n")
+			}
+		} else {
+			fmt.Fprintf(b, "if b, err = %s; err != nil {\nreturn nil, err\n}\nm.Add(%s, b)\n", encodeCall(fv, f.typ, expr), fv)
+		}
+		if f.typ.ptr {
+			b.WriteString("}\n")
+		}
+	}
+	b.WriteString("return m.Bytes()\n}\n\n")
+}
+
+func encodeCall(fv string, t goType, expr string) string {
+	switch t.kind {
+	case kindString:
+		return fmt.Sprintf("%s.EncodeString(string(%s))", fv, expr)
+	case kindInt:
+		return fmt.Sprintf("%s.EncodeInt(int64(%s))", fv, expr)
+	case kindFloat:
+		return fmt.Sprintf("%s.EncodeFloat(float64(%s), %d)", fv, expr, t.bits)
+	default:
+		return fmt.Sprintf("%s.EncodeBytes([]byte(%s))", fv, expr)
+	}
+}
+
+func (g *generator) unmarshal(b *bytes.Buffer, m *message) {
+	fmt.Fprintf(b, "//UnmarshalISO8583 decodes data into v without reflection\n")
+	fmt.Fprintf(b, "func (v *%s) UnmarshalISO8583(data []byte) error {\n", m.name)
+	fmt.Fprintf(b, "r, mti, err := iso8583v2.NewGenReader(data, %s)\n", fieldVar(m, m.mti))
+	b.WriteString("if err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(b, "v.%s = %s(mti)\n", m.mti.name, m.mti.typ.name)
+	if len(m.fields) > 0 {
+		b.WriteString("var val []byte\nvar ok bool\n")
+	}
+	for _, f := range m.fields {
+		fv := fieldVar(m, f)
+		expr := "v." + f.name
+		fmt.Fprintf(b, "if val, ok, err = r.Next(%s); err != nil {\nreturn err\n} else if ok {\n", fv)
+		if f.typ.ptr {
+			fmt.Fprintf(b, "if %s == nil {\n%s = new(%s)\n}\n", expr, expr, f.typ.name)
+			if f.typ.kind != kindStruct {
+				expr = "*" + expr
+			}
+		}
+		if f.typ.kind == kindStruct {
+			fmt.Fprintf(b, "s, err := %s.NewStructReader(val)\nif err != nil {\nreturn err\n}\n", fv)
+			for _, s := range f.typ.sub {
+				sv := subVar(m, f, s)
+				sexpr := expr + "." + s.name
+				fmt.Fprintf(b, "if val, ok, err := s.Next(%s); err != nil {\nreturn err\n} else if ok {\n", sv)
+				if s.typ.ptr {
+					fmt.Fprintf(b, "if %s == nil {\n%s = new(%s)\n}\n", sexpr, sexpr, s.typ.name)
+					sexpr = "*" + sexpr
+				}
+				subDecode(b, sv, s.typ, sexpr)
+				b.WriteString("}\n")
+			}
+		} else {
+			fieldDecode(b, fv, f.typ, expr)
+		}
+		b.WriteString("}\n")
+	}
+	b.WriteString("return nil\n}\n\n")
+}
+
+func fieldDecode(b *bytes.Buffer, fv string, t goType, expr string) {
+	switch t.kind {
+	case kindString:
+		fmt.Fprintf(b, "x, err := %s.DecodeString(val)\nif err != nil {\nreturn err\n}\n%s = %s(x)\n", fv, expr, t.name)
+	case kindInt:
+		fmt.Fprintf(b, "i, err := %s.DecodeInt(val)\nif err != nil {\nreturn err\n}\n%s = %s(i)\n", fv, expr, t.name)
+	case kindFloat:
+		fmt.Fprintf(b, "fl, err := %s.DecodeFloat(val, %d)\nif err != nil {\nreturn err\n}\n%s = %s(fl)\n", fv, t.bits, expr, t.name)
+	default:
+		fmt.Fprintf(b, "%s = %s(%s.DecodeBytes(val))\n", expr, t.name, fv)
+	}
+}
+
+func subDecode(b *bytes.Buffer, sv string, t goType, expr string) {
+	switch t.kind {
+	case kindString:
+		fmt.Fprintf(b, "x, err := %s.DecodeString(val)\nif err != nil {\nreturn err\n}\n%s = %s(x)\n", sv, expr, t.name)
+	case kindInt:
+		fmt.Fprintf(b, "if i, ok, err := %s.DecodeInt(val); err != nil {\nreturn err\n} else if ok {\n%s = %s(i)\n}\n", sv, expr, t.name)
+	case kindFloat:
+		fmt.Fprintf(b, "if fl, ok, err := %s.DecodeFloat(val, %d); err != nil {\nreturn err\n} else if ok {\n%s = %s(fl)\n}\n", sv, t.bits, expr, t.name)
+	default:
+		fmt.Fprintf(b, "x, err := %s.DecodeBytes(val)\nif err != nil {\nreturn err\n}\n%s = %s(x)\n", sv, expr, t.name)
+	}
+}
+
+func (g *generator) test(msgs []*message, names []string) []byte {
+	var b bytes.Buffer
+	g.header(&b, names)
+	fmt.Fprintf(&b, "import (\n\"bytes\"\n\"reflect\"\n\"testing\"\n\niso8583v2 %q\n)\n\n", importPath)
+	for _, m := range msgs {
+		fmt.Fprintf(&b, "func TestIso8583Gen%s(t *testing.T) {\n", m.name)
+		fmt.Fprintf(&b, "for i, v := range []%s{\n", m.name)
+		fmt.Fprintf(&b, "{%s: \"0200\"},\n", m.mti.name)
+		fmt.Fprintf(&b, "{\n%s: \"0200\",\n", m.mti.name)
+		for _, f := range m.fields {
+			fmt.Fprintf(&b, "%s: %s,\n", f.name, sampleField(f))
+		}
+		b.WriteString("},\n} {\n")
+		b.WriteString(`want, err := iso8583v2.Marshal(v, iso8583v2.IgnoreGenerated())
+if err != nil {
+t.Fatalf("case %d reflect marshal failed %s", i, err.Error())
+}
+got, err := v.MarshalISO8583()
+if err != nil {
+t.Fatalf("case %d generated marshal failed %s", i, err.Error())
+}
+if !bytes.Equal(got, want) {
+t.Fatalf("case %d generated %X, reflect %X", i, got, want)
+}
+`)
+		fmt.Fprintf(&b, "var fromReflect, fromGenerated %s\n", m.name)
+		b.WriteString(`if err := iso8583v2.Unmarshal(want, &fromReflect, iso8583v2.IgnoreGenerated()); err != nil {
+t.Fatalf("case %d reflect unmarshal failed %s", i, err.Error())
+}
+if err := fromGenerated.UnmarshalISO8583(want); err != nil {
+t.Fatalf("case %d generated unmarshal failed %s", i, err.Error())
+}
+if !reflect.DeepEqual(fromGenerated, fromReflect) {
+t.Fatalf("case %d generated %#v, reflect %#v", i, fromGenerated, fromReflect)
+}
+}
+}
+
+`)
+	}
+	return b.Bytes()
+}
+
+//sampleField returns a value that fits the field tag
+func sampleField(f *field) string {
+	tag := f.gen.Tag()
+	if f.typ.kind == kindStruct {
+		var parts []string
+		for _, s := range f.typ.sub {
+			parts = append(parts, fmt.Sprintf("%s: %s", s.name, sample(s.typ, s.gen.Tag().Length, "")))
+		}
+		lit := fmt.Sprintf("%s{%s}", f.typ.name, strings.Join(parts, ", "))
+		if f.typ.ptr {
+			return "&" + lit
+		}
+		return lit
+	}
+	return sample(f.typ, tag.Length, tag.Type)
+}
+
+func sample(t goType, length int, fieldType string) string {
+	n := 2
+	if length >= 0 && length < n {
+		n = length
+	}
+	var v string
+	switch t.kind {
+	case kindInt, kindFloat:
+		v = "12"[:n]
+		if v == "" {
+			v = "0"
+		}
+	case kindBytes:
+		v = fmt.Sprintf("%s(%q)", t.name, "AB"[:n])
+	default:
+		if fieldType == "numeric" {
+			v = strconv.Quote("12"[:n])
+		} else {
+			v = strconv.Quote("AB"[:n])
+		}
+	}
+	if !t.ptr {
+		return v
+	}
+	return fmt.Sprintf("func() *%s { x := %s(%s); return &x }()", t.name, t.name, v)
+}
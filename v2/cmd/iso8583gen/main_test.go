@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeneratedUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "..", "internal", "gentest")
+	src, test, err := generate(dir, []string{"Message", "Reversal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, b := range map[string][]byte{
+		"message_iso8583.go":      src,
+		"message_iso8583_test.go": test,
+	} {
+		old, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(old, b) {
+			t.Errorf("%s is out of date, run go generate in internal/gentest", name)
+		}
+	}
+}
+
+func TestGenerateUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	src := `package p
+
+import "time"
+
+type NoMti struct {
+	Pan string ` + "`field:\"2\" type:\"llvar\"`" + `
+}
+
+type Uint struct {
+	Mti string
+	N   uint ` + "`field:\"3\" type:\"numeric\" length:\"6\"`" + `
+}
+
+type Foreign struct {
+	Mti string
+	T   time.Time ` + "`field:\"7\" type:\"numeric\" length:\"10\"`" + `
+}
+
+type Text string
+
+func (t Text) MarshalText() ([]byte, error) { return []byte(t), nil }
+
+type Marshaler struct {
+	Mti string
+	T   Text ` + "`field:\"7\" type:\"alpha\" length:\"10\"`" + `
+}
+
+type Icc struct {
+	Amount int64 ` + "`tlv:\"9F02\"`" + `
+}
+
+type Tlv struct {
+	Mti string
+	Icc Icc ` + "`field:\"55\" type:\"tlv\"`" + `
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"NoMti", "Uint", "Foreign", "Marshaler", "Tlv", "Missing"} {
+		if _, _, err := generate(dir, []string{name}); err == nil {
+			t.Errorf("%s should not be generated", name)
+		}
+	}
+}
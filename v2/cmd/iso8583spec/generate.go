@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	iso8583v2 "github.com/henglory/iso8583/v2"
+)
+
+//generate returns the formatted source of the message struct and its sub field structs,
+//every tag is checked by the iso8583v2 tag parser
+func generate(s *spec, pkg string, source string) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by iso8583spec from %s; DO NOT EDIT.\n\n", filepath.Base(source))
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	if len(s.Rules) > 0 {
+		b.WriteString("import iso8583v2 \"github.com/henglory/iso8583/v2\"\n\n")
+	}
+
+	if s.Description != "" {
+		fmt.Fprintf(&b, "// %s %s\n", s.Name, s.Description)
+	} else {
+		fmt.Fprintf(&b, "// %s is generated from %s\n", s.Name, filepath.Base(source))
+	}
+	fmt.Fprintf(&b, "type %s struct {\n", s.Name)
+	mtiTag := ""
+	if s.MTI.Encode != "" && s.MTI.Encode != "ascii" {
+		mtiTag = tag("encode", s.MTI.Encode)
+	}
+	if _, err := iso8583v2.ParseGenField("Mti", mtiTag); err != nil {
+		return nil, err
+	}
+	writeField(&b, "Mti", "string", mtiTag)
+	var subStructs []fieldSpec
+	for _, f := range s.Fields {
+		fmt.Fprintf(&b, "\n%s\n", fieldComment(f.Name, f.Field, f.Description))
+		if f.skip != "" {
+			fmt.Fprintf(&b, "// not generated, %s\n", f.skip)
+			continue
+		}
+		t := fieldTag(f)
+		parent, err := iso8583v2.ParseGenField(f.Name, t)
+		if err != nil {
+			return nil, err
+		}
+		goType := f.GoType
+		if len(f.Subfields) > 0 {
+			goType = s.Name + f.Name
+			for _, sf := range f.Subfields {
+				if _, err := iso8583v2.ParseGenSubfield(parent, sf.Name, subfieldTag(sf)); err != nil {
+					return nil, err
+				}
+			}
+			subStructs = append(subStructs, f)
+		}
+		writeField(&b, f.Name, goType, t)
+	}
+	b.WriteString("}\n")
+
+	for _, f := range subStructs {
+		fmt.Fprintf(&b, "\n// %s%s holds the sub fields of field %d\n", s.Name, f.Name, f.Field)
+		fmt.Fprintf(&b, "type %s%s struct {\n", s.Name, f.Name)
+		for i, sf := range f.Subfields {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%s\n", fieldComment(sf.Name, sf.Field, sf.Description))
+			writeField(&b, sf.Name, sf.GoType, subfieldTag(sf))
+		}
+		b.WriteString("}\n")
+	}
+	writeRules(&b, s)
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code failed %s", err.Error())
+	}
+	return src, nil
+}
+
+//writeRules writes the presence rules of s as a package variable named after the message
+func writeRules(b *bytes.Buffer, s *spec) {
+	if len(s.Rules) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n// %sRules are the presence rules of %s by mti\n", s.Name, s.Name)
+	fmt.Fprintf(b, "var %sRules = iso8583v2.MustPresenceRules(\n", s.Name)
+	for _, r := range s.Rules {
+		fmt.Fprintf(b, "iso8583v2.PresenceRule{\nMTI: %s,\n", strconv.Quote(r.MTI))
+		if len(r.Mandatory) > 0 {
+			fields := make([]string, len(r.Mandatory))
+			for i, n := range r.Mandatory {
+				fields[i] = strconv.Itoa(n)
+			}
+			fmt.Fprintf(b, "Mandatory: []int{%s},\n", strings.Join(fields, ", "))
+		}
+		if len(r.Conditional) > 0 {
+			b.WriteString("Conditional: []iso8583v2.ConditionalField{\n")
+			for _, c := range r.Conditional {
+				fmt.Fprintf(b, "{Field: %d, When: %d, Prefix: %s},\n", c.Field, c.When, strconv.Quote(c.Prefix))
+			}
+			b.WriteString("},\n")
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString(")\n")
+}
+
+func fieldComment(name string, number int, description string) string {
+	if description == "" {
+		return fmt.Sprintf("// %s is field %d", name, number)
+	}
+	return fmt.Sprintf("// %s is field %d, %s", name, number, strings.Join(strings.Fields(description), " "))
+}
+
+func writeField(b *bytes.Buffer, name string, goType string, t string) {
+	if t == "" {
+		fmt.Fprintf(b, "%s %s\n", name, goType)
+		return
+	}
+	fmt.Fprintf(b, "%s %s `%s`\n", name, goType, t)
+}
+
+func fieldTag(f fieldSpec) string {
+	parts := []string{tag("field", strconv.Itoa(f.Field)), tag("type", f.Type)}
+	if f.Length > 0 {
+		parts = append(parts, tag("length", strconv.Itoa(f.Length)))
+	}
+	if f.Encode != "" && f.Encode != "ascii" && f.Encode != "ascii,ascii" {
+		parts = append(parts, tag("encode", f.Encode))
+	}
+	if f.Codepage != "" {
+		parts = append(parts, tag("cp", f.Codepage))
+	}
+	if f.BitmapSize > 0 {
+		parts = append(parts, tag("bitmapsize", strconv.Itoa(f.BitmapSize)))
+	}
+	return strings.Join(parts, " ")
+}
+
+func subfieldTag(sf subfieldSpec) string {
+	parts := []string{tag("field", strconv.Itoa(sf.Field)), tag("length", strconv.Itoa(sf.Length))}
+	if sf.Codepage != "" {
+		parts = append(parts, tag("cp", sf.Codepage))
+	}
+	return strings.Join(parts, " ")
+}
+
+func tag(key string, value string) string {
+	return key + ":" + strconv.Quote(value)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//jposField is an isofield or isofieldpackager element of a jPOS packager
+type jposField struct {
+	XMLName    xml.Name
+	ID         string      `xml:"id,attr"`
+	Length     int         `xml:"length,attr"`
+	Name       string      `xml:"name,attr"`
+	Class      string      `xml:"class,attr"`
+	EmitBitmap string      `xml:"emitBitmap,attr"`
+	Fields     []jposField `xml:",any"`
+}
+
+//jposClass is how a jPOS field class maps to tags
+type jposClass struct {
+	typ    string
+	encode string
+	cp     string
+	goType string
+}
+
+//jposClasses holds the classes the library can express, others are skipped
+var jposClasses = map[string]jposClass{
+	"IF_CHAR":       {typ: "alpha"},
+	"IFA_CHAR":      {typ: "alpha"},
+	"IFE_CHAR":      {typ: "alpha", cp: "ibm037"},
+	"IFA_NUMERIC":   {typ: "numeric"},
+	"IFB_NUMERIC":   {typ: "numeric", encode: "bcd"},
+	"IFB_BINARY":    {typ: "binary", goType: "[]byte"},
+	"IFA_LLCHAR":    {typ: "llvar"},
+	"IFA_LLNUM":     {typ: "llvar"},
+	"IFB_LLCHAR":    {typ: "llvar", encode: "bcd,ascii"},
+	"IFB_LLBINARY":  {typ: "llvar", encode: "bcd,ascii", goType: "[]byte"},
+	"IFA_LLLCHAR":   {typ: "lllvar"},
+	"IFA_LLLNUM":    {typ: "lllvar"},
+	"IFB_LLLCHAR":   {typ: "lllvar", encode: "bcd,ascii"},
+	"IFB_LLLBINARY": {typ: "lllvar", encode: "bcd,ascii", goType: "[]byte"},
+}
+
+//jposSubfieldClasses holds the fixed width classes allowed in a sub field packager
+var jposSubfieldClasses = map[string]jposClass{
+	"IF_CHAR":     {goType: "string"},
+	"IFA_CHAR":    {goType: "string"},
+	"IFE_CHAR":    {goType: "string", cp: "ibm037"},
+	"IFA_NUMERIC": {goType: "int64"},
+}
+
+func loadJpos(b []byte) (*spec, error) {
+	var root jposField
+	if err := xml.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+	if root.XMLName.Local != "isopackager" {
+		return nil, fmt.Errorf("root element must be isopackager, got %s", root.XMLName.Local)
+	}
+	s := &spec{}
+	for _, jf := range root.Fields {
+		id, err := strconv.Atoi(jf.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%s id %q is not a number", jf.XMLName.Local, jf.ID)
+		}
+		class := jposClassName(jf.Class)
+		switch {
+		case id == 0:
+			switch class {
+			case "IFA_NUMERIC":
+			case "IFB_NUMERIC":
+				s.MTI.Encode = "bcd"
+			case "IFE_NUMERIC":
+				s.MTI.Encode = "ebcdic"
+			default:
+				return nil, fmt.Errorf("mti class %s is not supported", class)
+			}
+			continue
+		case strings.HasSuffix(class, "_BITMAP"):
+			continue
+		}
+		s.Fields = append(s.Fields, jposFieldSpec(id, jf, class))
+	}
+	return s, nil
+}
+
+func jposFieldSpec(id int, jf jposField, class string) fieldSpec {
+	f := fieldSpec{
+		Field:       id,
+		Description: jf.Name,
+		Length:      jf.Length,
+	}
+	c, ok := jposClasses[class]
+	if !ok {
+		f.skip = fmt.Sprintf("class %s is not supported", class)
+		return f
+	}
+	f.Type, f.Encode, f.Codepage, f.GoType = c.typ, c.encode, c.cp, c.goType
+	if jf.XMLName.Local != "isofieldpackager" {
+		return f
+	}
+	if f.GoType != "" {
+		f.skip = fmt.Sprintf("sub fields of binary class %s are not supported", class)
+		return f
+	}
+	for _, sub := range jf.Fields {
+		sid, err := strconv.Atoi(sub.ID)
+		if err != nil {
+			f.skip = fmt.Sprintf("sub field id %q is not a number", sub.ID)
+			return f
+		}
+		subClass := jposClassName(sub.Class)
+		if strings.HasSuffix(subClass, "_BITMAP") {
+			if strings.EqualFold(jf.EmitBitmap, "true") {
+				f.BitmapSize = sub.Length
+			}
+			continue
+		}
+		sc, ok := jposSubfieldClasses[subClass]
+		if !ok {
+			//sub fields are read by position so one unknown width loses the rest
+			f.skip = fmt.Sprintf("sub field %d class %s is not fixed width", sid, subClass)
+			f.Subfields = nil
+			return f
+		}
+		if sc.goType == "int64" && sub.Length > 18 {
+			sc.goType = "string"
+		}
+		f.Subfields = append(f.Subfields, subfieldSpec{
+			Field:       sid,
+			Description: sub.Name,
+			Length:      sub.Length,
+			Codepage:    sc.cp,
+			GoType:      sc.goType,
+		})
+	}
+	return f
+}
+
+//jposClassName strips the package from a class like org.jpos.iso.IFA_LLNUM
+func jposClassName(class string) string {
+	return class[strings.LastIndex(class, ".")+1:]
+}
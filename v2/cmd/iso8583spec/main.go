@@ -0,0 +1,88 @@
+//Command iso8583spec generates tagged Go structs for iso8583v2 from a message specification.
+//
+//The specification is JSON or YAML, or a jPOS packager XML when the file ends with .xml
+//
+//	iso8583spec -in visa.yaml -package visa -out visa_message.go
+//
+//A JSON or YAML specification looks like
+//
+//	name: Authorization
+//	mti:
+//	  encode: bcd
+//	fields:
+//	  - field: 2
+//	    name: Pan
+//	    description: primary account number
+//	    type: llvar
+//	    length: 19
+//	  - field: 48
+//	    description: additional data
+//	    type: lllvar
+//	    bitmapsize: 1
+//	    subfields:
+//	      - field: 1
+//	        name: Terminal
+//	        length: 8
+//	rules:
+//	  - mti: "0100"
+//	    mandatory: [2, 3, 4]
+//	    conditional:
+//	      - field: 14
+//	        when: 22
+//	        prefix: "05"
+//
+//Fields take type, length, encode, cp, bitmapsize and gotype, sub fields take
+//length, cp and gotype. Names default to the description, then to Field<number>.
+//Rules become a <name>Rules variable for iso8583v2.EnforcePresence, a JSON specification
+//can also be read at run time with iso8583v2.ReadPresenceRules.
+//jPOS fields with a class the library cannot express are left out with a comment
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	in := flag.String("in", "", "specification file, .json, .yaml, .yml or .xml for a jPOS packager")
+	out := flag.String("out", "", "output file, default standard output")
+	pkg := flag.String("package", "main", "package name of the generated file")
+	name := flag.String("type", "", "message type name, overrides the name in the specification")
+	flag.Parse()
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*in, *out, *pkg, *name); err != nil {
+		fmt.Fprintf(os.Stderr, "iso8583spec: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(in string, out string, pkg string, name string) error {
+	s, err := loadSpec(in)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		s.Name = name
+	}
+	if err := s.normalize(); err != nil {
+		return fmt.Errorf("%s: %s", in, err.Error())
+	}
+	for _, f := range s.Fields {
+		if f.skip != "" {
+			fmt.Fprintf(os.Stderr, "iso8583spec: field %d %s is skipped, %s\n", f.Field, f.Name, f.skip)
+		}
+	}
+	src, err := generate(s, pkg, in)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0644)
+}
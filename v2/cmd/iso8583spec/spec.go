@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	iso8583v2 "github.com/henglory/iso8583/v2"
+	"gopkg.in/yaml.v3"
+)
+
+//spec describes one message, it is read from JSON or YAML
+//or converted from a jPOS packager
+type spec struct {
+	Name        string      `json:"name" yaml:"name"`
+	Description string      `json:"description" yaml:"description"`
+	MTI         mtiSpec     `json:"mti" yaml:"mti"`
+	Fields      []fieldSpec `json:"fields" yaml:"fields"`
+	//Rules are the presence rules by mti, they are generated as a PresenceRules variable
+	Rules []iso8583v2.PresenceRule `json:"rules" yaml:"rules"`
+}
+
+type mtiSpec struct {
+	Encode string `json:"encode" yaml:"encode"`
+}
+
+type fieldSpec struct {
+	Field       int            `json:"field" yaml:"field"`
+	Name        string         `json:"name" yaml:"name"`
+	Description string         `json:"description" yaml:"description"`
+	Type        string         `json:"type" yaml:"type"`
+	Length      int            `json:"length" yaml:"length"`
+	Encode      string         `json:"encode" yaml:"encode"`
+	Codepage    string         `json:"cp" yaml:"cp"`
+	BitmapSize  int            `json:"bitmapsize" yaml:"bitmapsize"`
+	GoType      string         `json:"gotype" yaml:"gotype"`
+	Subfields   []subfieldSpec `json:"subfields" yaml:"subfields"`
+	//skip is set by the jPOS loader for fields the library cannot express
+	skip string
+}
+
+type subfieldSpec struct {
+	Field       int    `json:"field" yaml:"field"`
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+	Length      int    `json:"length" yaml:"length"`
+	Codepage    string `json:"cp" yaml:"cp"`
+	GoType      string `json:"gotype" yaml:"gotype"`
+}
+
+var goTypes = map[string]bool{
+	"string":  true,
+	"int":     true,
+	"int8":    true,
+	"int16":   true,
+	"int32":   true,
+	"int64":   true,
+	"float32": true,
+	"float64": true,
+	"[]byte":  true,
+}
+
+//loadSpec reads path by its extension, .xml is read as a jPOS packager
+func loadSpec(path string) (*spec, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &spec{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(b, s)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, s)
+	case ".xml":
+		s, err = loadJpos(b)
+	default:
+		return nil, fmt.Errorf("%s has unknown extension, expected .json, .yaml, .yml or .xml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s failed %s", path, err.Error())
+	}
+	return s, nil
+}
+
+//normalize fills derived names and types and validates the spec
+func (s *spec) normalize() error {
+	if s.Name == "" {
+		s.Name = "Message"
+	}
+	if !isIdentifier(s.Name) {
+		return fmt.Errorf("message name %q is not a Go identifier", s.Name)
+	}
+	sort.SliceStable(s.Fields, func(i, j int) bool {
+		return s.Fields[i].Field < s.Fields[j].Field
+	})
+	names := map[string]bool{"Mti": true}
+	numbers := map[int]bool{}
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		if f.Field < 2 || f.Field > 128 || f.Field == 65 {
+			return fmt.Errorf("field %d is not a data field, expected 2..128 without 65", f.Field)
+		}
+		if numbers[f.Field] {
+			return fmt.Errorf("field %d is defined twice", f.Field)
+		}
+		numbers[f.Field] = true
+		f.Name = fieldName(f.Name, f.Description, f.Field, "Field", names)
+		if f.skip != "" {
+			continue
+		}
+		if err := f.normalize(); err != nil {
+			return fmt.Errorf("field %d %s", f.Field, err.Error())
+		}
+	}
+	if _, err := iso8583v2.NewPresenceRules(s.Rules...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (f *fieldSpec) normalize() error {
+	f.Type = strings.ToLower(f.Type)
+	switch f.Type {
+	case "numeric", "alpha", "binary":
+		if f.Length <= 0 {
+			return fmt.Errorf("type %s requires a length", f.Type)
+		}
+	case "llvar", "lllvar":
+	default:
+		//registered field types are checked by the tag parser
+		if f.Type == "" {
+			return fmt.Errorf("type is required")
+		}
+	}
+	if len(f.Subfields) > 0 {
+		if f.Type != "llvar" && f.Type != "lllvar" {
+			return fmt.Errorf("sub fields require type llvar or lllvar, got %s", f.Type)
+		}
+		if f.GoType != "" {
+			return fmt.Errorf("gotype cannot be set on a field with sub fields")
+		}
+		return f.normalizeSubfields()
+	}
+	if f.BitmapSize > 0 {
+		return fmt.Errorf("bitmapsize requires sub fields")
+	}
+	if f.GoType == "" {
+		f.GoType = "string"
+		if f.Type == "binary" {
+			f.GoType = "[]byte"
+		}
+	}
+	if !goTypes[f.GoType] {
+		return fmt.Errorf("gotype %s is not supported", f.GoType)
+	}
+	if f.Type == "binary" && f.GoType != "[]byte" {
+		return fmt.Errorf("type binary requires gotype []byte")
+	}
+	return nil
+}
+
+func (f *fieldSpec) normalizeSubfields() error {
+	sort.SliceStable(f.Subfields, func(i, j int) bool {
+		return f.Subfields[i].Field < f.Subfields[j].Field
+	})
+	names := map[string]bool{}
+	numbers := map[int]bool{}
+	for i := range f.Subfields {
+		sf := &f.Subfields[i]
+		if sf.Field < 1 {
+			return fmt.Errorf("sub field %d is below 1", sf.Field)
+		}
+		if f.BitmapSize > 0 && sf.Field > f.BitmapSize*8 {
+			return fmt.Errorf("sub field %d does not fit bitmapsize %d", sf.Field, f.BitmapSize)
+		}
+		if numbers[sf.Field] {
+			return fmt.Errorf("sub field %d is defined twice", sf.Field)
+		}
+		numbers[sf.Field] = true
+		sf.Name = fieldName(sf.Name, sf.Description, sf.Field, "Sub", names)
+		if sf.Length <= 0 {
+			return fmt.Errorf("sub field %d requires a length", sf.Field)
+		}
+		if sf.GoType == "" {
+			sf.GoType = "string"
+		}
+		if !goTypes[sf.GoType] {
+			return fmt.Errorf("sub field %d gotype %s is not supported", sf.Field, sf.GoType)
+		}
+	}
+	return nil
+}
+
+//fieldName returns name, or an identifier built from description, or prefix and the field number,
+//made unique within names
+func fieldName(name string, description string, number int, prefix string, names map[string]bool) string {
+	if name == "" {
+		name = identifier(description)
+	}
+	if name == "" || !isIdentifier(name) {
+		name = fmt.Sprintf("%s%d", prefix, number)
+	}
+	if names[name] {
+		name = fmt.Sprintf("%s%d", name, number)
+	}
+	names[name] = true
+	return name
+}
+
+//identifier turns a description like "PAN - PRIMARY ACCOUNT NUMBER" into PanPrimaryAccountNumber
+func identifier(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, w := range words {
+		rs := []rune(strings.ToLower(w))
+		rs[0] = unicode.ToUpper(rs[0])
+		b.WriteString(string(rs))
+	}
+	id := b.String()
+	if id == "" || !unicode.IsLetter([]rune(id)[0]) {
+		return ""
+	}
+	return id
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != "" && unicode.IsUpper([]rune(s)[0])
+}
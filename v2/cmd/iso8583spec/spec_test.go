@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	iso8583v2 "github.com/henglory/iso8583/v2"
+)
+
+func generateFile(t *testing.T, path string, name string) []byte {
+	s, err := loadSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "" {
+		s.Name = name
+	}
+	if err := s.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(s, "network", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestGenerateGolden(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		name   string
+		golden string
+	}{
+		{"authorization.yaml", "", "authorization.golden"},
+		{"packager.xml", "Financial", "packager.golden"},
+	} {
+		got := generateFile(t, filepath.Join("testdata", tc.in), tc.name)
+		want, err := os.ReadFile(filepath.Join("testdata", tc.golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s generated\n%s\nexpected\n%s", tc.in, got, want)
+		}
+	}
+}
+
+func TestGenerateJSONMatchesYAML(t *testing.T) {
+	yml := generateFile(t, filepath.Join("testdata", "authorization.yaml"), "")
+	js := generateFile(t, filepath.Join("testdata", "authorization.json"), "")
+	js = bytes.Replace(js, []byte("authorization.json"), []byte("authorization.yaml"), 1)
+	if !bytes.Equal(yml, js) {
+		t.Fatalf("json and yaml specs differ\n%s\n%s", yml, js)
+	}
+}
+
+func TestSpecInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		s   spec
+		err string
+	}{
+		{spec{Fields: []fieldSpec{{Field: 1, Type: "alpha", Length: 2}}}, "not a data field"},
+		{spec{Fields: []fieldSpec{{Field: 2, Type: "llvar"}, {Field: 2, Type: "llvar"}}}, "defined twice"},
+		{spec{Fields: []fieldSpec{{Field: 3, Type: "numeric"}}}, "requires a length"},
+		{spec{Fields: []fieldSpec{{Field: 3}}}, "type is required"},
+		{spec{Fields: []fieldSpec{{Field: 52, Type: "binary", Length: 8, GoType: "string"}}}, "requires gotype []byte"},
+		{spec{Fields: []fieldSpec{{Field: 4, Type: "numeric", Length: 12, GoType: "uint"}}}, "not supported"},
+		{spec{Fields: []fieldSpec{{Field: 48, Type: "alpha", Length: 8, Subfields: []subfieldSpec{{Field: 1, Length: 8}}}}}, "require type llvar"},
+		{spec{Fields: []fieldSpec{{Field: 48, Type: "llvar", BitmapSize: 1, Subfields: []subfieldSpec{{Field: 9, Length: 8}}}}}, "does not fit"},
+		{spec{Fields: []fieldSpec{{Field: 48, Type: "llvar", Subfields: []subfieldSpec{{Field: 1}}}}}, "requires a length"},
+		{spec{Name: "lower"}, "not a Go identifier"},
+		{spec{Rules: []iso8583v2.PresenceRule{{MTI: "0200", Mandatory: []int{65}}}}, "not a data field"},
+		{spec{Rules: []iso8583v2.PresenceRule{{MTI: "200"}}}, "MTI"},
+	} {
+		err := tc.s.normalize()
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("expected error containing %q, got %v", tc.err, err)
+		}
+	}
+}
+
+func TestGenerateRejectsInvalidTag(t *testing.T) {
+	s := &spec{Fields: []fieldSpec{{Field: 2, Type: "llvr"}}}
+	if err := s.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generate(s, "network", "spec.yaml"); err == nil {
+		t.Fatal("expected error for unknown type")
+	}
+	s = &spec{Fields: []fieldSpec{{Field: 43, Type: "alpha", Length: 40, Codepage: "no-such-codepage"}}}
+	if err := s.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generate(s, "network", "spec.yaml"); err == nil {
+		t.Fatal("expected error for unknown code page")
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	for in, want := range map[string]string{
+		"PAN - PRIMARY ACCOUNT NUMBER": "PanPrimaryAccountNumber",
+		"card acceptor name/location":  "CardAcceptorNameLocation",
+		"3DS data":                     "",
+		"":                             "",
+	} {
+		if got := identifier(in); got != want {
+			t.Errorf("identifier(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//Command iso8583vet runs the iso8583tag analyzer as a vet tool
+//
+//	go install github.com/henglory/iso8583/v2/cmd/iso8583vet
+//	go vet -vettool=$(which iso8583vet) ./...
+//
+//Field types and code pages registered by the program are passed with
+//-iso8583tag.types and -iso8583tag.codepages
+package main
+
+import (
+	"github.com/henglory/iso8583/v2/passes/iso8583tag"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(iso8583tag.Analyzer)
+}
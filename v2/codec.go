@@ -0,0 +1,223 @@
+package iso8583v2
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+//Codec marshals and unmarshals T with a field plan compiled once by Compile.
+//A Codec is safe for concurrent use
+type Codec[T any] struct {
+	typ    reflect.Type
+	mti    compiledField
+	fields []compiledField
+	tags   map[string]*iso8583Tag
+	opts   []Option
+	opt    options
+	err    error
+}
+
+type compiledField struct {
+	index int
+	tg    iso8583Tag
+	enc   fieldEncoderFunc
+}
+
+type compiledSubField struct {
+	index int
+	tg    fixedwidthTag
+	enc   fixedwidthEncoderFunc
+}
+
+//Compile builds the field plan of struct type T, opts apply to every Marshal and Unmarshal of the Codec.
+//UseDictionary and ValidateTags take effect once here, IgnoreGenerated has no effect since a Codec never
+//uses generated methods. An invalid T is reported by every Marshal and Unmarshal call of the returned Codec
+func Compile[T any](opts ...Option) *Codec[T] {
+	c := &Codec[T]{
+		typ:  reflect.TypeOf((*T)(nil)).Elem(),
+		opts: append([]Option(nil), opts...),
+		opt:  newOptions(opts),
+	}
+	strict := c.opt.strict
+	if c.typ.Kind() != reflect.Struct {
+		c.err = fmt.Errorf("not support for not struct type %v", c.typ)
+		return c
+	}
+	if c.opt.validateTags {
+		if c.err = validateType(c.typ, c.opt.dict); c.err != nil {
+			return c
+		}
+	}
+	c.tags = cachedOptionTag(c.typ, c.opt)
+	tag := c.tags
+	hasMti := false
+	for i := 0; i < c.typ.NumField(); i++ {
+		field := c.typ.Field(i)
+		isoTag := tag[field.Name]
+		if isoTag == nil {
+			continue
+		}
+		if isoTag.isMti {
+			c.mti = compiledField{index: i, tg: *isoTag}
+			hasMti = true
+			continue
+		}
+		if isoTag.field <= 0 || isoTag.field > 128 {
+			c.err = fmt.Errorf("field:%s accepted only primary and secondary bitmap idx > 0 and idx <= 128", isoTag.name)
+			return c
+		}
+		enc := getFieldEncoder(field.Type, *isoTag, strict)
+		if field.Type.Kind() == reflect.Struct && isoTag.fieldType != tlvFieldType &&
+			!implements(field.Type, fieldMarshalerType) &&
+			!implements(field.Type, textMarshalerType) {
+			sub, err := compileFixedwidth(field.Type, *isoTag, strict)
+			if err != nil {
+				c.err = err
+				return c
+			}
+			enc = fieldEncoder{typ: field.Type, tg: *isoTag, sub: sub, strict: strict}.structEncodeFunc
+		}
+		c.fields = append(c.fields, compiledField{
+			index: i,
+			tg:    *isoTag,
+			enc:   enc,
+		})
+	}
+	if !hasMti {
+		c.err = fmt.Errorf("compile %v failed because mti is required", c.typ)
+		return c
+	}
+	sort.SliceStable(c.fields, func(i, j int) bool {
+		return c.fields[i].tg.field < c.fields[j].tg.field
+	})
+	for i := 1; i < len(c.fields); i++ {
+		if c.fields[i].tg.field == c.fields[i-1].tg.field {
+			c.err = fmt.Errorf("field:%s and field:%s share field number %d", c.fields[i-1].tg.name, c.fields[i].tg.name, c.fields[i].tg.field)
+			return c
+		}
+	}
+	return c
+}
+
+//Marshal encodes v with the compiled plan
+func (c *Codec[T]) Marshal(v T) ([]byte, error) {
+	return pooledMarshal(func(dst []byte) ([]byte, error) {
+		return c.MarshalAppend(dst, v)
+	})
+}
+
+//MarshalAppend encodes v with the compiled plan and appends the message to dst,
+//every field is encoded in place at the end of dst
+func (c *Codec[T]) MarshalAppend(dst []byte, v T) ([]byte, error) {
+	if c.err != nil {
+		return dst, c.err
+	}
+	rv := reflect.ValueOf(&v).Elem()
+	ret, err := appendMti(dst, rv.Field(c.mti.index), c.mti.tg)
+	if err != nil {
+		return dst, fmt.Errorf("Encode %v failed because mti %s", c.typ, err.Error())
+	}
+	var w bitmapWriter
+	ret = w.reserve(ret)
+	for _, cf := range c.fields {
+		before := len(ret)
+		ret, err = cf.enc(ret, rv.Field(cf.index))
+		if err != nil {
+			return dst, err
+		}
+		if len(ret) > before {
+			if err := w.set(cf.tg.field); err != nil {
+				return dst, err
+			}
+		}
+	}
+	if c.opt.presence != nil {
+		if err := c.opt.presence.checkStruct(rv, c.tags, w.present()); err != nil {
+			return dst, err
+		}
+	}
+	return w.finish(ret), nil
+}
+
+//Unmarshal decodes data into v with the compiled plan and the options of Compile,
+//ZeroCopy, EnforcePresence and Strict of opts are added on top of them for this call.
+//UseDictionary, ValidateTags and IgnoreGenerated change the plan itself so they are
+//rejected here, give them to Compile instead
+func (c *Codec[T]) Unmarshal(data []byte, v *T, opts ...Option) error {
+	if c.err != nil {
+		return c.err
+	}
+	if v == nil {
+		return fmt.Errorf("Unmarshaling struct must be a pointer")
+	}
+	opt := c.opt
+	if len(opts) > 0 {
+		if call := newOptions(opts); call.dict != nil || call.validateTags || call.ignoreGenerated {
+			return fmt.Errorf("Unsupport UseDictionary, ValidateTags or IgnoreGenerated on Codec.Unmarshal, give them to Compile")
+		}
+		opt = newOptions(append(append([]Option(nil), c.opts...), opts...))
+	}
+	rv := reflect.ValueOf(v).Elem()
+	var err error
+	m := mtiDecoder{
+		v:  rv.Field(c.mti.index),
+		tg: c.mti.tg,
+	}
+	data, err = m.decode(data)
+	if err != nil {
+		return fmt.Errorf("decode mti failed %s", err.Error())
+	}
+	b := &bitmapDecoder{}
+	data, err = b.decode(data)
+	if err != nil {
+		return fmt.Errorf("decode bitmap failed %s", err.Error())
+	}
+	fd := &fieldDecoder{
+		getBitmap: b.getBitmap,
+		opt:       opt,
+	}
+	for _, cf := range c.fields {
+		fd.v = rv.Field(cf.index)
+		fd.tg = cf.tg
+		data, err = fd.decode(data)
+		if err != nil {
+			return fmt.Errorf("decode field:%s failed %s", cf.tg.name, err.Error())
+		}
+	}
+	if opt.presence != nil {
+		return opt.presence.checkStruct(rv, c.tags, bitmapPresent(b.getBitmap()))
+	}
+	return nil
+}
+
+//compileFixedwidth returns the sub fields of struct typ sorted by field number
+func compileFixedwidth(typ reflect.Type, tg iso8583Tag, strict bool) ([]compiledSubField, error) {
+	tag := cachedFixedwidthTag(typ)
+	sub := make([]compiledSubField, 0, len(tag))
+	for i := 0; i < typ.NumField(); i++ {
+		subField := typ.Field(i)
+		fixedTag := tag[subField.Name]
+		if fixedTag == nil {
+			continue
+		}
+		if fixedTag.field < 1 {
+			return nil, fmt.Errorf("field:%s has sub field below 1", tg.name)
+		}
+		sub = append(sub, compiledSubField{
+			index: i,
+			tg:    *fixedTag,
+			enc:   getFixedwidthEncoder(subField.Type, *fixedTag, tg.bitmapSize > 0, strict),
+		})
+	}
+	sort.SliceStable(sub, func(i, j int) bool {
+		return sub[i].tg.field < sub[j].tg.field
+	})
+	for i := 1; i < len(sub); i++ {
+		if sub[i].tg.field == sub[i-1].tg.field {
+			return nil, fmt.Errorf("field:%s sub field:%s and sub field:%s share field number %d", tg.name, sub[i-1].tg.name, sub[i].tg.name, sub[i].tg.field)
+		}
+	}
+	return sub, nil
+}
+
@@ -3,34 +3,32 @@ package iso8583v2
 import (
 	"fmt"
 	"reflect"
-	"sync/atomic"
 )
 
-func Unmarshal(data []byte, v interface{}) error {
+//Unmarshal decodes data into v.
+//A generated UnmarshalISO8583 method is preferred unless IgnoreGenerated, ZeroCopy, UseDictionary,
+//EnforcePresence or Strict is given
+func Unmarshal(data []byte, v interface{}, opts ...Option) error {
+	return unmarshal(data, v, newOptions(opts))
+}
+
+func unmarshal(data []byte, v interface{}, opt options) error {
 	rv, err := validateDecode(v)
 	if err != nil {
 		return fmt.Errorf("validate failed %s", err.Error())
 	}
-
-	//lookup for tag in cache
-	structKey := rv.Type().PkgPath() + rv.Type().Name()
-	tagLock.RLock()
-	mpTag := tagCache[structKey]
-	tagLock.RUnlock()
-	tag, ok := mpTag.Load().(map[string]*iso8583Tag)
-	if !ok {
-		var tagValue atomic.Value
-		tag = loadTag(rv)
-		tagValue.Store(tag)
-		tagLock.Lock()
-		tagCache[structKey] = tagValue
-		tagLock.Unlock()
+	if opt.validateTags {
+		if err := validateType(rv.Type(), opt.dict); err != nil {
+			return err
+		}
 	}
-
-	return decodeIso8583wthTag(data, rv, tag)
+	if u, ok := v.(Unmarshaler); ok && opt.useGenerated() && !opt.zeroCopy {
+		return u.UnmarshalISO8583(data)
+	}
+	return decodeIso8583wthTag(data, rv, cachedOptionTag(rv.Type(), opt), opt)
 }
 
-func decodeIso8583wthTag(data []byte, v reflect.Value, tag map[string]*iso8583Tag) error {
+func decodeIso8583wthTag(data []byte, v reflect.Value, tag map[string]*iso8583Tag, opt options) error {
 	d := &decoder{}
 	for i := 0; i < v.Type().NumField(); i++ {
 		field := v.Type().Field(i)
@@ -47,11 +45,18 @@ func decodeIso8583wthTag(data []byte, v reflect.Value, tag map[string]*iso8583Ta
 			continue
 		}
 		d.addFieldDecoder(&fieldDecoder{
-			v:  v.Field(i),
-			tg: *isoTag,
+			v:   v.Field(i),
+			tg:  *isoTag,
+			opt: opt,
 		})
 	}
-	return d.execute(data)
+	if err := d.execute(data); err != nil {
+		return err
+	}
+	if opt.presence != nil {
+		return opt.presence.checkStruct(v, tag, bitmapPresent(d.getBitmap()))
+	}
+	return nil
 }
 
 func validateDecode(v interface{}) (reflect.Value, error) {
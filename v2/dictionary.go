@@ -0,0 +1,499 @@
+package iso8583v2
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//DictionaryField is the definition of a data element in a Dictionary
+type DictionaryField struct {
+	//Type is numeric, alpha, binary, llvar, lllvar or a registered type
+	Type string
+	//Length is the length, or the maximum length of llvar and lllvar
+	Length int
+	//Encode is written as the encode tag, empty is ascii
+	Encode string
+	//Class is the ISO attribute class such as n, an, ans, b or z, it is checked by Strict
+	Class       string
+	Description string
+}
+
+//Dictionary holds the definitions of the data elements of a message standard.
+//A field tagged without a type takes its type, length and encode from the dictionary,
+//keys set on the tag override the dictionary.
+//A dictionary made by Extend is an overlay, fields it does not define come from its parent
+type Dictionary struct {
+	name   string
+	parent *Dictionary
+	fields map[int]DictionaryField
+}
+
+//NewDictionary returns a dictionary of fields 1 to 128, registered types must be registered first
+func NewDictionary(name string, fields map[int]DictionaryField) (*Dictionary, error) {
+	return newDictionary(name, nil, fields)
+}
+
+func newDictionary(name string, parent *Dictionary, fields map[int]DictionaryField) (*Dictionary, error) {
+	d := &Dictionary{name: name, parent: parent, fields: make(map[int]DictionaryField, len(fields))}
+	for n, f := range fields {
+		if n < 1 || n > 128 {
+			return nil, fmt.Errorf("dictionary %s field %d is not between 1 and 128", name, n)
+		}
+		if _, err := parseType(f.Type); err != nil {
+			return nil, fmt.Errorf("dictionary %s field %d type %q is not a built in or registered type", name, n, f.Type)
+		}
+		if _, err := parseClass(f.Class); err != nil {
+			return nil, fmt.Errorf("dictionary %s field %d %s", name, n, err.Error())
+		}
+		d.fields[n] = f
+	}
+	return d, nil
+}
+
+//Extend returns an overlay named name whose fields replace the fields of d,
+//such as a network that is 1987 except fields 48 and 60 to 63
+func (d *Dictionary) Extend(name string, fields map[int]DictionaryField) (*Dictionary, error) {
+	return newDictionary(name, d, fields)
+}
+
+//Name returns the name the dictionary is registered with
+func (d *Dictionary) Name() string {
+	return d.name
+}
+
+//Layers returns the names of d and the dictionaries it extends, d first
+func (d *Dictionary) Layers() []string {
+	var names []string
+	for l := d; l != nil; l = l.parent {
+		names = append(names, l.name)
+	}
+	return names
+}
+
+//Field returns the definition of field n
+func (d *Dictionary) Field(n int) (DictionaryField, bool) {
+	f, _, ok := d.Resolve(n)
+	return f, ok
+}
+
+//Resolve returns the definition of field n and the name of the layer that defines it
+func (d *Dictionary) Resolve(n int) (DictionaryField, string, bool) {
+	for l := d; l != nil; l = l.parent {
+		if f, ok := l.fields[n]; ok {
+			return f, l.name, true
+		}
+	}
+	return DictionaryField{}, "", false
+}
+
+//Fields returns the defined field numbers in order
+func (d *Dictionary) Fields() []int {
+	seen := make(map[int]bool)
+	var numbers []int
+	for l := d; l != nil; l = l.parent {
+		for n := range l.fields {
+			if !seen[n] {
+				seen[n] = true
+				numbers = append(numbers, n)
+			}
+		}
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+//mustExtend builds the built in editions
+func (d *Dictionary) mustExtend(name string, fields map[int]DictionaryField) *Dictionary {
+	c, err := d.Extend(name, fields)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+//DefaultDictionary is used by messages whose mti tag does not name a dictionary
+const DefaultDictionary = "1987"
+
+var (
+	dictionaryLock sync.RWMutex
+	dictionaries   = map[string]*Dictionary{
+		iso1987.name: iso1987,
+		iso1993.name: iso1993,
+		iso2003.name: iso2003,
+	}
+)
+
+//RegisterDictionary makes d available to the mti tag as dict:"name"
+func RegisterDictionary(d *Dictionary) error {
+	name := strings.ToLower(strings.TrimSpace(d.name))
+	if name == "" || name == noDictionary {
+		return fmt.Errorf("dictionary name %q is not valid", d.name)
+	}
+	dictionaryLock.Lock()
+	defer dictionaryLock.Unlock()
+	if _, ok := dictionaries[name]; ok {
+		return fmt.Errorf("dictionary %s is already registered", name)
+	}
+	dictionaries[name] = d
+	return nil
+}
+
+//LookupDictionary returns a built in dictionary, 1987, 1993 or 2003, or a registered one
+func LookupDictionary(name string) (*Dictionary, bool) {
+	dictionaryLock.RLock()
+	defer dictionaryLock.RUnlock()
+	d, ok := dictionaries[strings.ToLower(strings.TrimSpace(name))]
+	return d, ok
+}
+
+//registered reports whether d is the dictionary LookupDictionary returns for its name
+func (d *Dictionary) registered() bool {
+	r, ok := LookupDictionary(d.name)
+	return ok && r == d
+}
+
+//noDictionary turns the dictionary off for a message
+const noDictionary = "none"
+
+//parseDictionary reads the dict tag, empty is DefaultDictionary and none is no dictionary
+func parseDictionary(s string) (*Dictionary, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		s = DefaultDictionary
+	case noDictionary:
+		return nil, nil
+	}
+	d, ok := LookupDictionary(s)
+	if !ok {
+		return nil, fmt.Errorf("Unsupport dictionary %s", s)
+	}
+	return d, nil
+}
+
+//messageDictionary returns the dictionary named by the mti field of a message
+func messageDictionary(names []string, tags []reflect.StructTag) (*Dictionary, error) {
+	for i, name := range names {
+		tag, err := expandTag(tags[i], false)
+		if err != nil || !isMtiField(name, tag) {
+			continue
+		}
+		return parseDictionary(tag.Get(dictWord))
+	}
+	return parseDictionary("")
+}
+
+//typeDictionary returns the dictionary named by the mti field of a struct type, nil when it is unknown
+func typeDictionary(typ reflect.Type) *Dictionary {
+	names := make([]string, typ.NumField())
+	tags := make([]reflect.StructTag, typ.NumField())
+	for i := range names {
+		names[i], tags[i] = typ.Field(i).Name, typ.Field(i).Tag
+	}
+	d, _ := messageDictionary(names, tags)
+	return d
+}
+
+//FieldDefinition is the format a message field is encoded with once its tag and dictionary are resolved
+type FieldDefinition struct {
+	FieldTag
+	//Source is the dictionary layer that defines the field, empty when the tag sets the type
+	Source string
+}
+
+//Describe returns the resolved format of the mti and every field of T in field number order,
+//so the format a field actually uses can be audited. opts may hold UseDictionary
+func Describe[T any](opts ...Option) ([]FieldDefinition, error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("not support for not struct type %v", typ)
+	}
+	opt := newOptions(opts)
+	d := opt.dict
+	if d == nil {
+		d = typeDictionary(typ)
+	}
+	tags := cachedOptionTag(typ, opt)
+	var defs []FieldDefinition
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		t := tags[f.Name]
+		if t == nil {
+			continue
+		}
+		def := FieldDefinition{FieldTag: t.export()}
+		if !t.isMti {
+			def.Source = dictionarySource(f.Tag, d, t.field)
+		}
+		defs = append(defs, def)
+	}
+	sort.SliceStable(defs, func(i, j int) bool {
+		return defs[i].Field < defs[j].Field
+	})
+	return defs, nil
+}
+
+//dictionarySource returns the layer that typed field n, empty when the tag has a type
+func dictionarySource(tag reflect.StructTag, d *Dictionary, n int) string {
+	tag, err := expandTag(tag, false)
+	if err != nil {
+		return ""
+	}
+	if _, typed := tag.Lookup(typeWord); typed {
+		return ""
+	}
+	if v, ok := tag.Lookup(dictWord); ok {
+		if d, err = parseDictionary(v); err != nil {
+			return ""
+		}
+	}
+	if d == nil {
+		return ""
+	}
+	_, source, _ := d.Resolve(n)
+	return source
+}
+
+//applyDictionary fills a tag without a type from the definition of its field number
+func applyDictionary(tag reflect.StructTag, d *Dictionary) reflect.StructTag {
+	if d == nil {
+		return tag
+	}
+	if _, typed := tag.Lookup(typeWord); typed {
+		return tag
+	}
+	n, err := strconv.Atoi(tag.Get(fieldWord))
+	if err != nil {
+		return tag
+	}
+	def, ok := d.Field(n)
+	if !ok {
+		return tag
+	}
+	parts := []string{string(tag), typeWord + ":" + strconv.Quote(def.Type)}
+	if _, ok := tag.Lookup(lengthWord); !ok && def.Length > 0 {
+		parts = append(parts, lengthWord+":"+strconv.Quote(strconv.Itoa(def.Length)))
+	}
+	if _, ok := tag.Lookup(encodeWord); !ok && def.Encode != "" {
+		parts = append(parts, encodeWord+":"+strconv.Quote(def.Encode))
+	}
+	if _, ok := tag.Lookup(classWord); !ok && def.Class != "" {
+		parts = append(parts, classWord+":"+strconv.Quote(def.Class))
+	}
+	return reflect.StructTag(strings.TrimSpace(strings.Join(parts, " ")))
+}
+
+func dictN(length int, description string) DictionaryField {
+	return DictionaryField{Type: "numeric", Length: length, Class: "n", Description: description}
+}
+
+func dictAN(class string, length int, description string) DictionaryField {
+	return DictionaryField{Type: "alpha", Length: length, Class: class, Description: description}
+}
+
+func dictB(length int, description string) DictionaryField {
+	return DictionaryField{Type: "binary", Length: length, Class: "b", Description: description}
+}
+
+func dictLL(class string, length int, description string) DictionaryField {
+	return DictionaryField{Type: "llvar", Length: length, Class: class, Description: description}
+}
+
+func dictLLL(class string, length int, description string) DictionaryField {
+	return DictionaryField{Type: "lllvar", Length: length, Class: class, Description: description}
+}
+
+//iso1987 is ISO 8583:1987, x+n amounts are alpha with the sign, b64 fields are 8 bytes
+var iso1987 = &Dictionary{name: "1987", fields: map[int]DictionaryField{
+	2:   dictLL("n", 19, "primary account number"),
+	3:   dictN(6, "processing code"),
+	4:   dictN(12, "amount, transaction"),
+	5:   dictN(12, "amount, settlement"),
+	6:   dictN(12, "amount, cardholder billing"),
+	7:   dictN(10, "transmission date and time"),
+	8:   dictN(8, "amount, cardholder billing fee"),
+	9:   dictN(8, "conversion rate, settlement"),
+	10:  dictN(8, "conversion rate, cardholder billing"),
+	11:  dictN(6, "system trace audit number"),
+	12:  dictN(6, "time, local transaction"),
+	13:  dictN(4, "date, local transaction"),
+	14:  dictN(4, "date, expiration"),
+	15:  dictN(4, "date, settlement"),
+	16:  dictN(4, "date, conversion"),
+	17:  dictN(4, "date, capture"),
+	18:  dictN(4, "merchant type"),
+	19:  dictN(3, "acquiring institution country code"),
+	20:  dictN(3, "pan extended, country code"),
+	21:  dictN(3, "forwarding institution country code"),
+	22:  dictN(3, "point of service entry mode"),
+	23:  dictN(3, "application pan sequence number"),
+	24:  dictN(3, "network international identifier"),
+	25:  dictN(2, "point of service condition code"),
+	26:  dictN(2, "point of service capture code"),
+	27:  dictN(1, "authorizing identification response length"),
+	28:  dictAN("x+n", 9, "amount, transaction fee"),
+	29:  dictAN("x+n", 9, "amount, settlement fee"),
+	30:  dictAN("x+n", 9, "amount, transaction processing fee"),
+	31:  dictAN("x+n", 9, "amount, settlement processing fee"),
+	32:  dictLL("n", 11, "acquiring institution identification code"),
+	33:  dictLL("n", 11, "forwarding institution identification code"),
+	34:  dictLL("ns", 28, "primary account number, extended"),
+	35:  dictLL("z", 37, "track 2 data"),
+	36:  dictLLL("n", 104, "track 3 data"),
+	37:  dictAN("an", 12, "retrieval reference number"),
+	38:  dictAN("an", 6, "authorization identification response"),
+	39:  dictAN("an", 2, "response code"),
+	40:  dictAN("an", 3, "service restriction code"),
+	41:  dictAN("ans", 8, "card acceptor terminal identification"),
+	42:  dictAN("ans", 15, "card acceptor identification code"),
+	43:  dictAN("ans", 40, "card acceptor name/location"),
+	44:  dictLL("an", 25, "additional response data"),
+	45:  dictLL("an", 76, "track 1 data"),
+	46:  dictLLL("an", 999, "additional data - iso"),
+	47:  dictLLL("an", 999, "additional data - national"),
+	48:  dictLLL("an", 999, "additional data - private"),
+	49:  dictAN("an", 3, "currency code, transaction"),
+	50:  dictAN("an", 3, "currency code, settlement"),
+	51:  dictAN("an", 3, "currency code, cardholder billing"),
+	52:  dictB(8, "personal identification number data"),
+	53:  dictN(16, "security related control information"),
+	54:  dictLLL("an", 120, "additional amounts"),
+	55:  dictLLL("ans", 999, "reserved iso"),
+	56:  dictLLL("ans", 999, "reserved iso"),
+	57:  dictLLL("ans", 999, "reserved national"),
+	58:  dictLLL("ans", 999, "reserved national"),
+	59:  dictLLL("ans", 999, "reserved national"),
+	60:  dictLLL("ans", 999, "reserved national"),
+	61:  dictLLL("ans", 999, "reserved private"),
+	62:  dictLLL("ans", 999, "reserved private"),
+	63:  dictLLL("ans", 999, "reserved private"),
+	64:  dictB(8, "message authentication code"),
+	66:  dictN(1, "settlement code"),
+	67:  dictN(2, "extended payment code"),
+	68:  dictN(3, "receiving institution country code"),
+	69:  dictN(3, "settlement institution country code"),
+	70:  dictN(3, "network management information code"),
+	71:  dictN(4, "message number"),
+	72:  dictN(4, "message number, last"),
+	73:  dictN(6, "date, action"),
+	74:  dictN(10, "credits, number"),
+	75:  dictN(10, "credits, reversal number"),
+	76:  dictN(10, "debits, number"),
+	77:  dictN(10, "debits, reversal number"),
+	78:  dictN(10, "transfer number"),
+	79:  dictN(10, "transfer, reversal number"),
+	80:  dictN(10, "inquiries number"),
+	81:  dictN(10, "authorizations, number"),
+	82:  dictN(12, "credits, processing fee amount"),
+	83:  dictN(12, "credits, transaction fee amount"),
+	84:  dictN(12, "debits, processing fee amount"),
+	85:  dictN(12, "debits, transaction fee amount"),
+	86:  dictN(16, "credits, amount"),
+	87:  dictN(16, "credits, reversal amount"),
+	88:  dictN(16, "debits, amount"),
+	89:  dictN(16, "debits, reversal amount"),
+	90:  dictN(42, "original data elements"),
+	91:  dictAN("an", 1, "file update code"),
+	92:  dictAN("an", 2, "file security code"),
+	93:  dictAN("an", 5, "response indicator"),
+	94:  dictAN("an", 7, "service indicator"),
+	95:  dictAN("an", 42, "replacement amounts"),
+	96:  dictB(8, "message security code"),
+	97:  dictAN("x+n", 17, "amount, net settlement"),
+	98:  dictAN("ans", 25, "payee"),
+	99:  dictLL("n", 11, "settlement institution identification code"),
+	100: dictLL("n", 11, "receiving institution identification code"),
+	101: dictLL("ans", 17, "file name"),
+	102: dictLL("ans", 28, "account identification 1"),
+	103: dictLL("ans", 28, "account identification 2"),
+	104: dictLLL("ans", 100, "transaction description"),
+	105: dictLLL("ans", 999, "reserved for iso use"),
+	106: dictLLL("ans", 999, "reserved for iso use"),
+	107: dictLLL("ans", 999, "reserved for iso use"),
+	108: dictLLL("ans", 999, "reserved for iso use"),
+	109: dictLLL("ans", 999, "reserved for iso use"),
+	110: dictLLL("ans", 999, "reserved for iso use"),
+	111: dictLLL("ans", 999, "reserved for iso use"),
+	112: dictLLL("ans", 999, "reserved for national use"),
+	113: dictLLL("ans", 999, "reserved for national use"),
+	114: dictLLL("ans", 999, "reserved for national use"),
+	115: dictLLL("ans", 999, "reserved for national use"),
+	116: dictLLL("ans", 999, "reserved for national use"),
+	117: dictLLL("ans", 999, "reserved for national use"),
+	118: dictLLL("ans", 999, "reserved for national use"),
+	119: dictLLL("ans", 999, "reserved for national use"),
+	120: dictLLL("ans", 999, "reserved for private use"),
+	121: dictLLL("ans", 999, "reserved for private use"),
+	122: dictLLL("ans", 999, "reserved for private use"),
+	123: dictLLL("ans", 999, "reserved for private use"),
+	124: dictLLL("ans", 999, "reserved for private use"),
+	125: dictLLL("ans", 999, "reserved for private use"),
+	126: dictLLL("ans", 999, "reserved for private use"),
+	127: dictLLL("ans", 999, "reserved for private use"),
+	128: dictB(8, "message authentication code"),
+}}
+
+//iso1993 is ISO 8583:1993 as laid out by the common ISO93 packagers
+var iso1993 = iso1987.mustExtend("1993", map[int]DictionaryField{
+	12:  dictN(12, "date and time, local transaction"),
+	15:  dictN(6, "date, settlement"),
+	17:  dictN(4, "date, capture"),
+	22:  dictAN("an", 12, "point of service data code"),
+	24:  dictN(3, "function code"),
+	25:  dictN(4, "message reason code"),
+	26:  dictN(4, "card acceptor business code"),
+	28:  dictN(6, "date, reconciliation"),
+	29:  dictN(3, "reconciliation indicator"),
+	30:  dictN(24, "amounts, original"),
+	31:  dictLL("ans", 99, "acquirer reference data"),
+	36:  dictLLL("z", 104, "track 3 data"),
+	39:  dictN(3, "action code"),
+	43:  dictLL("ans", 99, "card acceptor name/location"),
+	44:  dictLL("ans", 99, "additional response data"),
+	45:  dictLL("ans", 76, "track 1 data"),
+	46:  dictLLL("ans", 204, "amounts, fees"),
+	47:  dictLLL("ans", 999, "additional data - national"),
+	48:  dictLLL("ans", 999, "additional data - private"),
+	53:  dictLL("b", 48, "security related control information"),
+	54:  dictLLL("ans", 120, "amounts, additional"),
+	55:  dictLLL("b", 255, "integrated circuit card system related data"),
+	56:  dictLL("n", 35, "original data elements"),
+	57:  dictN(3, "authorization life cycle code"),
+	58:  dictLL("n", 11, "authorizing agent institution identification code"),
+	59:  dictLLL("ans", 999, "transport data"),
+	66:  dictLLL("ans", 204, "amounts, original fees"),
+	71:  dictN(8, "message number"),
+	72:  dictLLL("ans", 999, "data record"),
+	81:  dictN(10, "authorizations, number"),
+	82:  dictN(10, "inquiries, reversal number"),
+	83:  dictN(10, "payments, number"),
+	84:  dictN(10, "payments, reversal number"),
+	85:  dictN(10, "fee collections, number"),
+	90:  dictN(10, "authorizations, reversal number"),
+	91:  dictN(3, "country code, transaction destination institution"),
+	92:  dictN(3, "country code, transaction originator institution"),
+	93:  dictLL("n", 11, "transaction destination institution identification code"),
+	94:  dictLL("n", 11, "transaction originator institution identification code"),
+	95:  dictLL("ans", 99, "card issuer reference data"),
+	96:  dictLLL("b", 999, "key management data"),
+	97:  dictAN("x+n", 17, "amount, net reconciliation"),
+	99:  dictLL("an", 11, "settlement institution identification code"),
+	105: dictN(16, "credits, chargeback amount"),
+	106: dictN(16, "debits, chargeback amount"),
+	107: dictN(10, "credits, chargeback number"),
+	108: dictN(10, "debits, chargeback number"),
+	109: dictLL("ans", 84, "credits, fee amounts"),
+	110: dictLL("ans", 84, "debits, fee amounts"),
+})
+
+//iso2003 is ISO 8583:2003, dates carry the century
+var iso2003 = iso1993.mustExtend("2003", map[int]DictionaryField{
+	12: dictN(14, "date and time, local transaction"),
+	15: dictN(8, "date, settlement"),
+	17: dictN(8, "date, capture"),
+	28: dictN(8, "date, reconciliation"),
+	73: dictN(8, "date, action"),
+})
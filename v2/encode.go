@@ -5,72 +5,161 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
-	"strconv"
-	"sync/atomic"
 )
 
-func Marshal(v interface{}) ([]byte, error) {
+func Marshal(v interface{}, opts ...Option) ([]byte, error) {
+	return pooledMarshal(func(dst []byte) ([]byte, error) {
+		return MarshalAppend(dst, v, opts...)
+	})
+}
+
+//pooledMarshal encodes into a pooled buffer and returns a copy of the message the size it needs
+func pooledMarshal(marshal func(dst []byte) ([]byte, error)) ([]byte, error) {
+	bp := getBuffer()
+	defer putBuffer(bp)
+	b, err := marshal(*bp)
+	*bp = b
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), b...), nil
+}
+
+//MarshalAppend encodes v and appends the message to dst.
+//A generated MarshalISO8583 method is preferred unless IgnoreGenerated, UseDictionary, EnforcePresence
+//or Strict is given
+func MarshalAppend(dst []byte, v interface{}, opts ...Option) ([]byte, error) {
 	val, err := validateEncode(v)
 	if err != nil {
-		return nil, fmt.Errorf("validate failed: %s", err.Error())
-	}
-	//lookup for tag in cache
-	structKey := val.Type().PkgPath() + val.Type().Name()
-	tagLock.RLock()
-	mpTag := tagCache[structKey]
-	tagLock.RUnlock()
-	tag, ok := mpTag.Load().(map[string]*iso8583Tag)
-	if !ok {
-		var tagValue atomic.Value
-		tag = loadTag(val)
-		tagValue.Store(tag)
-		tagLock.Lock()
-		tagCache[structKey] = tagValue
-		tagLock.Unlock()
-	}
-	//////////////////////////
-	return encodeIso8583wthTag(val, tag)
-}
-
-func encodeIso8583wthTag(v reflect.Value, tag map[string]*iso8583Tag) ([]byte, error) {
-	var mti []byte
-	var err error
-	dataMap := make(map[int][]byte)
-	for i := 0; i < v.Type().NumField(); i++ {
-		field := v.Type().Field(i)
-		isoTag := tag[field.Name]
+		return dst, fmt.Errorf("validate failed: %s", err.Error())
+	}
+	opt := newOptions(opts)
+	if opt.validateTags {
+		if err := validateType(val.Type(), opt.dict); err != nil {
+			return dst, err
+		}
+	}
+	if m, ok := v.(Marshaler); ok && opt.useGenerated() {
+		b, err := m.MarshalISO8583()
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, b...), nil
+	}
+	return encodeIso8583wthTag(dst, val, cachedOptionTag(val.Type(), opt), opt)
+}
+
+func encodeIso8583wthTag(dst []byte, v reflect.Value, tag map[string]*iso8583Tag, opt options) ([]byte, error) {
+	typ := v.Type()
+	mti := -1
+	order := make([]int, 0, len(tag))
+	for i := 0; i < typ.NumField(); i++ {
+		isoTag := tag[typ.Field(i).Name]
 		if isoTag == nil {
 			continue
 		}
 		if isoTag.isMti {
-			mti, err = encodeMti(v.Field(i), *isoTag)
-			if err != nil {
-				return nil, fmt.Errorf("Encode %v failed because mti %v", v, v.Field(i))
-			}
+			mti = i
+			continue
+		}
+		order = append(order, i)
+	}
+	if mti < 0 {
+		return dst, fmt.Errorf("Encode %v failed because mti is required", v)
+	}
+	ret, err := appendMti(dst, v.Field(mti), *tag[typ.Field(mti).Name])
+	if err != nil {
+		return dst, fmt.Errorf("Encode %v failed because mti %v", v, v.Field(mti))
+	}
+	number := func(k int) int {
+		return tag[typ.Field(order[k]).Name].field
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return number(i) < number(j)
+	})
+	var w bitmapWriter
+	ret = w.reserve(ret)
+	for k, i := range order {
+		//a field that shares its number with a later one is replaced by it
+		if k+1 < len(order) && number(k+1) == number(k) {
 			continue
 		}
-		b, err := getFieldEncoder(field.Type, *isoTag)(v.Field(i))
+		before := len(ret)
+		field := typ.Field(i)
+		ret, err = newFieldEncoder(field.Type, *tag[field.Name], opt.strict).encode(ret, v.Field(i))
 		if err != nil {
-			return nil, err
+			return dst, err
 		}
-		if b != nil {
-			dataMap[isoTag.field] = b
+		if len(ret) > before {
+			if err := w.set(number(k)); err != nil {
+				return dst, err
+			}
 		}
 	}
-	if len(mti) == 0 {
-		return nil, fmt.Errorf("Encode %v failed because mti is required", v)
+	if opt.presence != nil {
+		if err := opt.presence.checkStruct(v, tag, w.present()); err != nil {
+			return dst, err
+		}
 	}
-	return encodeStructValue(dataMap, mti)
+	return w.finish(ret), nil
 }
 
-func encodeStructValue(dataMap map[int]([]byte), mti []byte) ([]byte, error) {
-	var ret []byte
-	ret = append(ret, mti...)
-	bitmap := make([]byte, 8)
-	var data []byte
-	var hasSecondBitmap = false
+//bitmapWriter builds the bitmap of a message whose fields are appended after it
+type bitmapWriter struct {
+	at        int
+	bitmap    [16]byte
+	secondary bool
+}
 
-	var keys []int
+//reserve appends room for both bitmaps to dst, the fields follow it
+func (w *bitmapWriter) reserve(dst []byte) []byte {
+	w.at = len(dst)
+	return appendZeros(dst, 16)
+}
+
+//set turns on the bit of field n
+func (w *bitmapWriter) set(n int) error {
+	if n <= 0 || n > 128 {
+		return fmt.Errorf("Accepted only primary and secondary bitmap idx > 0 and idx <= 128")
+	}
+	if n > 64 {
+		//add second bitmap
+		w.secondary = true
+		w.bitmap[0] |= 0x80
+	}
+	w.bitmap[(n-1)/8] |= 0x80 >> uint((n-1)%8)
+	return nil
+}
+
+func (w *bitmapWriter) size() int {
+	if w.secondary {
+		return 16
+	}
+	return 8
+}
+
+//present reads presence from the bits set so far
+func (w *bitmapWriter) present() func(int) bool {
+	return bitmapPresent(w.bitmap[:w.size()])
+}
+
+//finish writes the bitmap into the room of reserve and drops the room of an unused secondary bitmap
+func (w *bitmapWriter) finish(dst []byte) []byte {
+	copy(dst[w.at:], w.bitmap[:w.size()])
+	if !w.secondary {
+		copy(dst[w.at+8:], dst[w.at+16:])
+		dst = dst[:len(dst)-8]
+	}
+	return dst
+}
+
+//encodeStructValue appends mti, bitmap and data to dst, growing dst at most once
+func encodeStructValue(dst []byte, dataMap map[int]([]byte), mti []byte) ([]byte, error) {
+	var bitmap [16]byte
+	bitmapSize := 8
+	size := len(mti)
+
+	keys := make([]int, 0, len(dataMap))
 	for k := range dataMap {
 		keys = append(keys, k)
 	}
@@ -81,30 +170,78 @@ func encodeStructValue(dataMap map[int]([]byte), mti []byte) ([]byte, error) {
 			continue
 		}
 		if idx <= 0 || idx > 128 {
-			return nil, fmt.Errorf("Accepted only primary and secondary bitmap idx > 0 and idx <= 128")
+			return dst, fmt.Errorf("Accepted only primary and secondary bitmap idx > 0 and idx <= 128")
 		}
-		if idx > 64 && !hasSecondBitmap {
+		if idx > 64 {
 			//add second bitmap
-			bitmap = append(bitmap, make([]byte, 8)...)
-			bitmap[0] |= (0x80)
-			hasSecondBitmap = true
+			bitmapSize = 16
+			bitmap[0] |= 0x80
 		}
 		byteIdx := (idx - 1) / 8
 		bitIdx := (idx - 1) % 8
 		step := uint(7 - bitIdx)
 		bitmap[byteIdx] |= (0x01 << step)
-		data = append(data, m...)
+		size += len(m)
+	}
+	size += bitmapSize
+
+	ret := grow(dst, size)
+	ret = append(ret, mti...)
+	ret = append(ret, bitmap[:bitmapSize]...)
+	for _, idx := range keys {
+		ret = append(ret, dataMap[idx]...)
 	}
-	ret = append(ret, bitmap...)
-	ret = append(ret, data...)
 	return ret, nil
 }
 
+//grow makes room for n more bytes in b
+func grow(b []byte, n int) []byte {
+	if cap(b)-len(b) >= n {
+		return b
+	}
+	nb := make([]byte, len(b), len(b)+n)
+	copy(nb, b)
+	return nb
+}
+
+//encodeMti accepts a string, an integer or a type implementing FieldMarshaler or encoding.TextMarshaler
 func encodeMti(v reflect.Value, t iso8583Tag) ([]byte, error) {
-	if v.Type().Kind() != reflect.String {
-		return nil, fmt.Errorf("MTI type must be string")
+	return appendMti(nil, v, t)
+}
+
+//appendMti appends the encoded mti of v to dst
+func appendMti(dst []byte, v reflect.Value, t iso8583Tag) ([]byte, error) {
+	mti, err := mtiValue(v, t)
+	if err != nil {
+		return nil, err
 	}
-	mti := v.String()
+	return appendMtiString(dst, mti, t)
+}
+
+//mtiValue returns the digits of an mti field before they are encoded
+func mtiValue(v reflect.Value, t iso8583Tag) (string, error) {
+	if b, ok, err := marshalValue(v, t.export()); ok || err != nil {
+		if err != nil {
+			return "", fmt.Errorf("MTI marshal failed %s", err.Error())
+		}
+		return string(b), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%04d", v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("%04d", v.Uint()), nil
+	}
+	return "", fmt.Errorf("MTI type must be string, integer or encoding.TextMarshaler")
+}
+
+func encodeMtiString(mti string, t iso8583Tag) ([]byte, error) {
+	return appendMtiString(nil, mti, t)
+}
+
+func appendMtiString(dst []byte, mti string, t iso8583Tag) ([]byte, error) {
 	if mti == "" {
 		return nil, fmt.Errorf("MTI value must be defined")
 	}
@@ -113,16 +250,36 @@ func encodeMti(v reflect.Value, t iso8583Tag) ([]byte, error) {
 	}
 
 	// check MTI, it must contain only digits
-	if _, err := strconv.Atoi(mti); err != nil {
+	if !isDigits(mti) {
 		return nil, errors.New("MTI must be only numeric")
 	}
 
 	switch t.valEncode {
 	case bcd:
-		return bcdEncode([]byte(mti))
+		return appendBcd(append(dst, mti...), len(dst), '0', false)
+	case ebcdic:
+		for i := range mti {
+			dst = append(dst, 0xf0|(mti[i]-'0'))
+		}
+		return dst, nil
+	case hexEncode:
+		const digits = "0123456789ABCDEF"
+		for i := range mti {
+			dst = append(dst, digits[mti[i]>>4], digits[mti[i]&0x0f])
+		}
+		return dst, nil
 	default:
-		return []byte(mti), nil
+		return append(dst, mti...), nil
+	}
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
 	}
+	return true
 }
 
 //Receive interface and validate
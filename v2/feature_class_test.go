@@ -0,0 +1,128 @@
+package iso8583v2
+
+import (
+	"strings"
+	"testing"
+)
+
+type classSub struct {
+	Code  string `field:"1" length:"4" class:"an"`
+	Count int    `field:"2" length:"3" class:"n"`
+}
+
+type classStruct struct {
+	Mti      string
+	ProcCode string   `field:"3" type:"numeric" length:"6"`
+	Amount   string   `field:"28" type:"alpha" length:"9" class:"x+n"`
+	Track    string   `field:"35" type:"llvar" class:"z"`
+	Terminal string   `iso8583:"41,an,8"`
+	Name     string   `field:"43" type:"alpha" length:"10"`
+	Sub      classSub `field:"48" type:"lllvar"`
+}
+
+func validClassStruct() classStruct {
+	return classStruct{
+		Mti:      "0200",
+		ProcCode: "000000",
+		Amount:   "D00000100",
+		Track:    "4111111111111111D2612101",
+		Terminal: "TERM0001",
+		Name:     "Shop #1",
+		Sub:      classSub{Code: "AB12", Count: 7},
+	}
+}
+
+func TestStrictClass(t *testing.T) {
+	v := validClassStruct()
+	b, err := Marshal(v, Strict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := classStruct{}
+	if err := Unmarshal(b, &after, Strict()); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		change func(v *classStruct)
+		err    string
+	}{
+		{"numeric letters", func(v *classStruct) { v.ProcCode = "00A000" }, "field:ProcCode character 'A' at 2 is not class n"},
+		{"alpha control", func(v *classStruct) { v.Name = "Shop\x01" }, "field:Name character '\\x01' at 4 is not class ans"},
+		{"sign", func(v *classStruct) { v.Amount = "X00000100" }, "must start with C or D"},
+		{"track 2", func(v *classStruct) { v.Track = "4111^2612" }, "field:Track character '^' at 4 is not class z"},
+		{"namespaced class", func(v *classStruct) { v.Terminal = "TERM-001" }, "is not class an"},
+		{"sub field", func(v *classStruct) { v.Sub.Code = "AB 1" }, "subfield:Code character ' ' at 2 is not class an"},
+		{"sub field number", func(v *classStruct) { v.Sub.Count = -1 }, "subfield:Count character '-' at 0 is not class n"},
+	}
+	for _, tt := range tests {
+		v := validClassStruct()
+		tt.change(&v)
+		if _, err := Marshal(v); err != nil {
+			t.Errorf("%s should pass without strict, got %s", tt.name, err.Error())
+		}
+		_, err := Marshal(v, Strict())
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s expect %q got %v", tt.name, tt.err, err)
+		}
+		_, err = Compile[classStruct](Strict()).Marshal(v)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s codec expect %q got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestStrictClassDecode(t *testing.T) {
+	v := validClassStruct()
+	v.ProcCode = "00A000"
+	v.Sub.Code = "AB 1"
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := classStruct{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatalf("without strict got %s", err.Error())
+	}
+	err = Unmarshal(b, &after, Strict())
+	if err == nil || !strings.Contains(err.Error(), "is not class n") {
+		t.Errorf("expect class n error got %v", err)
+	}
+	err = Compile[classStruct]().Unmarshal(b, &after, Strict())
+	if err == nil || !strings.Contains(err.Error(), "is not class n") {
+		t.Errorf("codec expect class n error got %v", err)
+	}
+	v = validClassStruct()
+	v.Sub.Code = "AB 1"
+	if b, err = Marshal(v); err != nil {
+		t.Fatal(err)
+	}
+	err = Unmarshal(b, &after, Strict())
+	if err == nil || !strings.Contains(err.Error(), "subfield:Code") {
+		t.Errorf("expect sub field error got %v", err)
+	}
+}
+
+func TestDictionaryClass(t *testing.T) {
+	type pos struct {
+		Mti   string
+		Entry string `field:"22"`
+		Track string `field:"35"`
+	}
+	if _, err := Marshal(pos{Mti: "0200", Entry: "05A", Track: "4111=2612"}, Strict()); err == nil || !strings.Contains(err.Error(), "field:Entry") {
+		t.Errorf("dictionary class n expected, got %v", err)
+	}
+	if _, err := Marshal(pos{Mti: "0200", Entry: "051", Track: "4111=2612"}, Strict()); err != nil {
+		t.Error(err)
+	}
+	type unknown struct {
+		Mti  string
+		Name string `field:"43" class:"q"`
+	}
+	if err := Validate[unknown](); err == nil || !strings.Contains(err.Error(), "Unsupport class q") {
+		t.Errorf("unknown class got %v", err)
+	}
+	if _, err := NewDictionary("bad", map[int]DictionaryField{2: {Type: "llvar", Class: "q"}}); err == nil {
+		t.Error("unknown dictionary class should fail")
+	}
+}
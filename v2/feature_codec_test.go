@@ -0,0 +1,168 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodecMatchesMarshal(t *testing.T) {
+	init := TestBitmapIsoDecode{
+		Mti:         "0800",
+		TransmissDt: "0000123123",
+		TraceNum:    "123456",
+		SendingID:   "004",
+		T: T48{
+			T1: "ทดสอบทดสอบ",
+			T3: 1,
+		},
+		Rrn:         "000908232123",
+		NetworkCode: "080",
+	}
+	want, err := Marshal(init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Compile[TestBitmapIsoDecode]()
+	b, err := c.Marshal(init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, b) {
+		t.Errorf("codec output differs\n%x\n%x", want, b)
+	}
+	iso := TestBitmapIsoDecode{}
+	if err = c.Unmarshal(b, &iso); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(init, iso) {
+		t.Errorf("codec round trip failed\n%+v\n%+v", init, iso)
+	}
+}
+
+func TestCodecPrimaryBitmapOnly(t *testing.T) {
+	init := test3{
+		Mti: "0200",
+		T1:  "test",
+		T3:  "0000000123",
+	}
+	s := "test2"
+	init.T2 = &s
+	c := Compile[test3]()
+	b, err := c.Marshal(init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := Marshal(init)
+	if !bytes.Equal(want, b) {
+		t.Errorf("codec output differs\n%x\n%x", want, b)
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	if _, err := Compile[int]().Marshal(1); err == nil {
+		t.Error("non struct type should fail")
+	}
+	type noMti struct {
+		T1 string `field:"2" type:"llvar"`
+	}
+	if _, err := Compile[noMti]().Marshal(noMti{}); err == nil {
+		t.Error("struct without mti should fail")
+	}
+	type duplicate struct {
+		Mti string
+		T1  string `field:"2" type:"llvar"`
+		T2  string `field:"2" type:"llvar"`
+	}
+	if err := Compile[duplicate]().Unmarshal(nil, &duplicate{}); err == nil {
+		t.Error("duplicate field number should fail")
+	}
+}
+
+func TestCodecOptions(t *testing.T) {
+	v := validClassStruct()
+	v.ProcCode = "00A000"
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Compile[classStruct](Strict()).Marshal(v); err == nil {
+		t.Error("Strict of Compile should apply to Marshal")
+	}
+	if err := Compile[classStruct](Strict()).Unmarshal(b, &classStruct{}); err == nil {
+		t.Error("Strict of Compile should apply to Unmarshal")
+	}
+	if err := Compile[classStruct]().Unmarshal(b, &classStruct{}); err != nil {
+		t.Errorf("without strict got %v", err)
+	}
+
+	res, err := Marshal(presenceStruct{Mti: "0210", Pan: "4111"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Compile[presenceStruct](EnforcePresence(presenceRules)).Unmarshal(res, &presenceStruct{}); err == nil {
+		t.Error("EnforcePresence of Compile should apply to Unmarshal")
+	}
+
+	data := zeroCopyTestData(t)
+	after := zeroCopyTestStruct{}
+	if err := Compile[zeroCopyTestStruct](ZeroCopy()).Unmarshal(data, &after); err != nil {
+		t.Fatal(err)
+	}
+	if &after.Ll[0] != &data[bytes.Index(data, []byte("ll"))] {
+		t.Error("ZeroCopy of Compile should point into data")
+	}
+
+	type invalid struct {
+		Mti  string
+		Name string `field:"43" type:"alpha" length:"4" class:"x"`
+	}
+	if _, err := Compile[invalid]().Marshal(invalid{Mti: "0200"}); err != nil {
+		t.Errorf("without ValidateTags got %v", err)
+	}
+	if _, err := Compile[invalid](ValidateTags()).Marshal(invalid{Mti: "0200"}); err == nil {
+		t.Error("ValidateTags of Compile should reject the invalid class")
+	}
+
+	_, conn := overlayDictionaries(t)
+	msg := overlayStruct{Mti: "0200", Pan: "4111111111111111", Reserved: "ABCDEF"}
+	want, err := Marshal(msg, UseDictionary(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Compile[overlayStruct](UseDictionary(conn))
+	got, err := c.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("UseDictionary of Compile expect %x got %x", want, got)
+	}
+	msgAfter := overlayStruct{}
+	if err := c.Unmarshal(got, &msgAfter); err != nil || msgAfter.Reserved != "ABCDEF" {
+		t.Errorf("UseDictionary of Compile round trip got %+v %v", msgAfter, err)
+	}
+}
+
+func TestCodecUnmarshalPlanOptions(t *testing.T) {
+	v := classStruct{Mti: "0200", ProcCode: "123456", Name: "SHOP"}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, conn := overlayDictionaries(t)
+	c := Compile[classStruct]()
+	for name, opt := range map[string]Option{
+		"UseDictionary":   UseDictionary(conn),
+		"ValidateTags":    ValidateTags(),
+		"IgnoreGenerated": IgnoreGenerated(),
+	} {
+		if err := c.Unmarshal(b, &classStruct{}, opt); err == nil || !strings.Contains(err.Error(), "give them to Compile") {
+			t.Errorf("%s of Codec.Unmarshal should be rejected got %v", name, err)
+		}
+	}
+	if err := c.Unmarshal(b, &classStruct{}, Strict()); err != nil {
+		t.Errorf("Strict of Codec.Unmarshal got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type codepageTestSub struct {
+	Name string `field:"1" length:"6" cp:"ibm037"`
+}
+
+type codepageTestStruct struct {
+	Mti    string
+	Sjis   string          `field:"2" type:"llvar" cp:"shift_jis"`
+	Gb     string          `field:"3" type:"llvar" cp:"GB18030"`
+	Latin  string          `field:"4" length:"6" type:"alpha" cp:"iso-8859-1"`
+	Table  string          `field:"5" type:"llvar" cp:"x-test-table"`
+	Ebcdic codepageTestSub `field:"6" type:"llvar"`
+}
+
+func init() {
+	table := make([][]byte, 256)
+	for i := range table {
+		table[i] = []byte{byte(i)}
+	}
+	table[0x01] = []byte("ก")
+	enc, dec := TableCodepage(table)
+	if err := RegisterCodepage("x-test-table", enc, dec); err != nil {
+		panic(err)
+	}
+}
+
+func TestCodepageLabels(t *testing.T) {
+	before := codepageTestStruct{
+		Mti:    "0200",
+		Sjis:   "日本語",
+		Gb:     "中文",
+		Latin:  "café",
+		Table:  "aก",
+		Ebcdic: codepageTestSub{Name: "ABC"},
+	}
+	b, err := Marshal(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte{'0', '6', 0x93, 0xfa, 0x96, 0x7b, 0x8c, 0xea}) {
+		t.Errorf("shift_jis is not encoded %x", b)
+	}
+	if !bytes.Contains(b, []byte{'0', '2', 'a', 0x01}) {
+		t.Errorf("registered codepage is not encoded %x", b)
+	}
+	if !bytes.Contains(b, []byte{'0', '6', 0xc1, 0xc2, 0xc3, 0x40, 0x40, 0x40}) {
+		t.Errorf("ebcdic subfield is not encoded %x", b)
+	}
+	after := codepageTestStruct{}
+	if err = Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Errorf("codepage round trip failed\n%+v\n%+v", before, after)
+	}
+}
+
+func TestParseCodepage(t *testing.T) {
+	for _, cp := range []string{"", "TIS-620", "windows-874", "hexstring", "Shift_JIS", "IBM1047", "x-test-table"} {
+		if _, err := parseCodepage(cp); err != nil {
+			t.Errorf("codepage %s should be supported %s", cp, err.Error())
+		}
+	}
+	if _, err := parseCodepage("no-such-codepage"); err == nil {
+		t.Error("unknown codepage should fail")
+	}
+	if err := RegisterCodepage("X-TEST-TABLE", func(b []byte) ([]byte, error) { return b, nil }, func(b []byte) ([]byte, error) { return b, nil }); err == nil {
+		t.Error("duplicate codepage should not be registered")
+	}
+}
+
+type codepagePolicyTestStruct struct {
+	Mti     string
+	Fail    string `field:"2" type:"llvar" cp:"tis-620" cppolicy:"fail"`
+	Replace string `field:"3" type:"llvar" cp:"tis-620" cppolicy:"replace"`
+	Drop    string `field:"4" type:"llvar" cp:"tis-620" cppolicy:"drop"`
+}
+
+func TestCodepagePolicyEncode(t *testing.T) {
+	_, err := Marshal(codepagePolicyTestStruct{Mti: "0200", Fail: "ร้าน café"})
+	if err == nil {
+		t.Error("unsupported character should fail with cppolicy fail")
+	}
+	type lenient struct {
+		Mti  string
+		Name string `field:"2" type:"llvar" cp:"tis-620"`
+	}
+	if _, err := Marshal(lenient{Mti: "0200", Name: "café"}, Strict()); err == nil {
+		t.Error("unsupported character should fail in strict mode")
+	}
+	b, err := Marshal(lenient{Mti: "0200", Name: "café"})
+	if err != nil || !bytes.HasSuffix(b, []byte("04caf?")) {
+		t.Errorf("without a policy unsupported characters are replaced, got %q %v", b, err)
+	}
+	b, err = Marshal(codepagePolicyTestStruct{Mti: "0200", Replace: "ร้าน café", Drop: "ร้าน café"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := codepagePolicyTestStruct{}
+	if err = Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Replace != "ร้าน caf?" || after.Drop != "ร้าน caf" {
+		t.Errorf("codepage policy failed %+v", after)
+	}
+}
+
+func TestCodepagePolicyDecode(t *testing.T) {
+	data := []byte{'0', '2', '0', '0', 0x70, 0, 0, 0, 0, 0, 0, 0, '0', '2', 'a', 0xdb, '0', '2', 'a', 0xdb, '0', '2', 'a', 0xdb}
+	after := codepagePolicyTestStruct{}
+	if err := Unmarshal(data, &after); err == nil {
+		t.Error("undefined byte should fail with cppolicy fail")
+	}
+	type lenient struct {
+		Mti  string
+		Name string `field:"2" type:"llvar" cp:"tis-620"`
+	}
+	lenientData := append([]byte("0200\x40\x00\x00\x00\x00\x00\x00\x00"), '0', '2', 'a', 0xdb)
+	l := lenient{}
+	if err := Unmarshal(lenientData, &l); err != nil || l.Name != "a\ufffd" {
+		t.Errorf("without a policy undefined bytes decode as the replacement character, got %q %v", l.Name, err)
+	}
+	if err := Unmarshal(lenientData, &l, Strict()); err == nil {
+		t.Error("undefined byte should fail in strict mode")
+	}
+	data[4] = 0x30
+	after = codepagePolicyTestStruct{}
+	if err := Unmarshal(append(data[:12:12], data[16:]...), &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Replace != "a?" || after.Drop != "a" {
+		t.Errorf("codepage policy failed %+v", after)
+	}
+}
+
+func TestHexstringInvalid(t *testing.T) {
+	if _, err := Marshal(hexTestStruct{Mti: "0200", Emv: "zz"}); err == nil {
+		t.Error("invalid hexstring should fail")
+	}
+}
+
+func TestCodepagePaddingBytes(t *testing.T) {
+	type wide struct {
+		Mti  string
+		Name string `field:"43" type:"alpha" length:"8" cp:"utf-16be"`
+		Odd  string `field:"44" type:"alpha" length:"5" cp:"utf-16be"`
+	}
+	b, err := Marshal(wide{Mti: "0200", Name: "AB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(b, []byte{0x00, 'A', 0x00, 'B', 0x00, ' ', 0x00, ' '}) {
+		t.Errorf("two byte spaces should fill the 4 bytes left, got %x", b)
+	}
+	if _, err := Marshal(wide{Mti: "0200", Odd: "AB"}); err == nil {
+		t.Error("a two byte pad cannot fill 1 byte")
+	}
+}
+
+func TestCodepagePolicyStateful(t *testing.T) {
+	type jis struct {
+		Mti  string
+		Name string `field:"2" type:"llvar" cp:"iso-2022-jp" cppolicy:"replace"`
+	}
+	b, err := Marshal(jis{Mti: "0200", Name: "日本€語"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(b, []byte("\x1b$B")); n != 2 {
+		t.Errorf("expect one shift before 日本 and one before 語, got %d in %q", n, b)
+	}
+	after := jis{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Name != "日本?語" {
+		t.Errorf("expect 日本?語 got %s", after.Name)
+	}
+}
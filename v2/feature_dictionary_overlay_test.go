@@ -0,0 +1,150 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type overlayStruct struct {
+	Mti      string `dict:"x-test-visa"`
+	Pan      string `field:"2"`
+	Amount   int64  `field:"4"`
+	Terminal string `field:"41" type:"alpha" length:"8"`
+	Private  string `field:"48"`
+	Reserved string `field:"62"`
+}
+
+func overlayDictionaries(t *testing.T) (*Dictionary, *Dictionary) {
+	base, _ := LookupDictionary("1987")
+	network, err := base.Extend("x-test-visa", map[int]DictionaryField{
+		48: {Type: "llvar", Length: 30, Class: "ans", Description: "network additional data"},
+		62: {Type: "alpha", Length: 4, Class: "ans", Description: "network reserved"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := LookupDictionary(network.Name()); !ok {
+		if err := RegisterDictionary(network); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn, err := network.Extend("x-test-visa-conn1", map[int]DictionaryField{
+		62: {Type: "alpha", Length: 6, Class: "ans", Description: "connection reserved"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return network, conn
+}
+
+func TestDictionaryOverlay(t *testing.T) {
+	network, conn := overlayDictionaries(t)
+	if !reflect.DeepEqual(conn.Layers(), []string{"x-test-visa-conn1", "x-test-visa", "1987"}) {
+		t.Errorf("unexpected layers %v", conn.Layers())
+	}
+	tests := []struct {
+		d      *Dictionary
+		field  int
+		length int
+		source string
+	}{
+		{network, 48, 30, "x-test-visa"},
+		{network, 62, 4, "x-test-visa"},
+		{conn, 62, 6, "x-test-visa-conn1"},
+		{conn, 48, 30, "x-test-visa"},
+		{conn, 4, 12, "1987"},
+	}
+	for _, tt := range tests {
+		f, source, ok := tt.d.Resolve(tt.field)
+		if !ok || f.Length != tt.length || source != tt.source {
+			t.Errorf("%s field %d expect length %d from %s got %+v from %s", tt.d.Name(), tt.field, tt.length, tt.source, f, source)
+		}
+	}
+	if len(conn.Fields()) != 126 {
+		t.Errorf("overlay should keep the base fields, got %d", len(conn.Fields()))
+	}
+	if _, err := network.Extend("bad", map[int]DictionaryField{129: {Type: "alpha", Length: 1}}); err == nil {
+		t.Error("field 129 should fail")
+	}
+}
+
+func TestUseDictionary(t *testing.T) {
+	_, conn := overlayDictionaries(t)
+	v := overlayStruct{Mti: "0200", Pan: "4111", Amount: 1, Private: "abc", Reserved: "R1", Terminal: "T1"}
+	b, err := Marshal(v, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(b, []byte("03abcR1  ")) {
+		t.Errorf("expect network format got %s", b)
+	}
+	c, err := Marshal(v, UseDictionary(conn), ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(c, []byte("03abcR1    ")) {
+		t.Errorf("expect connection format got %s", c)
+	}
+	after := overlayStruct{}
+	if err := Unmarshal(c, &after, UseDictionary(conn)); err != nil {
+		t.Fatal(err)
+	}
+	if after.Reserved != "R1" || after.Private != "abc" {
+		t.Errorf("unexpected %+v", after)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, UseDictionary(conn)).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), c) {
+		t.Errorf("encoder expect %s got %s", c, buf.Bytes())
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	_, conn := overlayDictionaries(t)
+	defs, err := Describe[overlayStruct](UseDictionary(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []FieldDefinition{
+		{FieldTag: FieldTag{Name: "Mti", Type: "mti", ValueEncode: "ascii"}},
+		{FieldTag: FieldTag{Name: "Pan", Field: 2, Length: 19, Type: "llvar", LengthEncode: "ascii", ValueEncode: "ascii"}, Source: "1987"},
+		{FieldTag: FieldTag{Name: "Amount", Field: 4, Length: 12, Type: "numeric", LengthEncode: "ascii", ValueEncode: "ascii"}, Source: "1987"},
+		{FieldTag: FieldTag{Name: "Terminal", Field: 41, Length: 8, Type: "alpha", LengthEncode: "ascii", ValueEncode: "ascii"}},
+		{FieldTag: FieldTag{Name: "Private", Field: 48, Length: 30, Type: "llvar", LengthEncode: "ascii", ValueEncode: "ascii"}, Source: "x-test-visa"},
+		{FieldTag: FieldTag{Name: "Reserved", Field: 62, Length: 6, Type: "alpha", LengthEncode: "ascii", ValueEncode: "ascii"}, Source: "x-test-visa-conn1"},
+	}
+	if !reflect.DeepEqual(defs, expect) {
+		t.Errorf("expect %+v got %+v", expect, defs)
+	}
+	if _, err := Describe[int](); err == nil {
+		t.Error("not struct type should fail")
+	}
+}
+
+func TestUseDictionaryCache(t *testing.T) {
+	_, conn := overlayDictionaries(t)
+	network, _ := LookupDictionary("x-test-visa")
+	typ := reflect.TypeOf(overlayStruct{})
+	msg := overlayStruct{Mti: "0200", Pan: "4111111111111111", Reserved: "ABCD"}
+	for _, d := range []*Dictionary{network, conn} {
+		b, err := Marshal(msg, UseDictionary(d), ValidateTags())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Unmarshal(b, &overlayStruct{}, UseDictionary(d), ValidateTags()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := dictionaryTagCache.Load(dictionaryKey{typ: typ, dict: network}); !ok {
+		t.Errorf("expect the tags of a registered dictionary to be cached")
+	}
+	if _, ok := dictionaryTagCache.Load(dictionaryKey{typ: typ, dict: conn}); ok {
+		t.Errorf("expect the tags of an unregistered overlay not to be cached")
+	}
+	if _, ok := validatedTypes.Load(dictionaryKey{typ: typ, dict: conn}); ok {
+		t.Errorf("expect the validation with an unregistered overlay not to be cached")
+	}
+}
@@ -0,0 +1,171 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type dictionaryStruct struct {
+	Mti      string
+	Pan      string `field:"2"`
+	ProcCode string `field:"3"`
+	Amount   int64  `field:"4" encode:"bcd"`
+	Stan     int    `field:"11"`
+	Terminal string `field:"41"`
+	Pin      []byte `field:"52"`
+}
+
+type explicitStruct struct {
+	Mti      string
+	Pan      string `field:"2" type:"llvar" length:"19"`
+	ProcCode string `field:"3" type:"numeric" length:"6"`
+	Amount   int64  `field:"4" type:"numeric" length:"12" encode:"bcd"`
+	Stan     int    `field:"11" type:"numeric" length:"6"`
+	Terminal string `field:"41" type:"alpha" length:"8"`
+	Pin      []byte `field:"52" type:"binary" length:"8"`
+}
+
+type dictionary1993Struct struct {
+	Mti    string `iso8583:"mti,dict=1993"`
+	Stan   int    `iso8583:"11"`
+	Action string `iso8583:"39"`
+	Name   string `iso8583:"43"`
+}
+
+func TestDictionary(t *testing.T) {
+	v := dictionaryStruct{
+		Mti:      "0200",
+		Pan:      "4111111111111111",
+		ProcCode: "000000",
+		Amount:   100,
+		Stan:     1,
+		Terminal: "TERM0001",
+		Pin:      []byte{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	expect, err := Marshal(explicitStruct(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Marshal(v, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expect, b) {
+		t.Errorf("expect %x got %x", expect, b)
+	}
+	after := dictionaryStruct{}
+	if err := Unmarshal(b, &after, ValidateTags()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, after) {
+		t.Errorf("expect %+v got %+v", v, after)
+	}
+	if _, err := Marshal(dictionaryStruct{Mti: "0200", ProcCode: "1234567"}); err == nil {
+		t.Error("value longer than the dictionary length should fail")
+	}
+}
+
+func TestDictionaryOverride(t *testing.T) {
+	type override struct {
+		Mti string
+		Pan string `field:"2" length:"4"`
+	}
+	if _, err := Marshal(override{Mti: "0200", Pan: "41111"}); err == nil {
+		t.Error("length on the tag should override the dictionary")
+	}
+	type typed struct {
+		Mti      string
+		ProcCode string `field:"3" type:"llvar"`
+	}
+	b, err := Marshal(typed{Mti: "0200", ProcCode: "12345678"})
+	if err != nil {
+		t.Fatalf("a tag with a type takes nothing from the dictionary, got %s", err.Error())
+	}
+	if !bytes.HasSuffix(b, []byte("0812345678")) {
+		t.Errorf("unexpected %s", b)
+	}
+	type none struct {
+		Mti string `dict:"none"`
+		Pan string `field:"2"`
+	}
+	if err := Validate[none](); err == nil || !strings.Contains(err.Error(), "field:Pan") {
+		t.Errorf("without dictionary a tag needs a type, got %v", err)
+	}
+}
+
+func TestDictionaryVersions(t *testing.T) {
+	v := dictionary1993Struct{Mti: "1200", Stan: 7, Action: "000", Name: "SHOP"}
+	b, err := Marshal(v, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	//1993 action code is n3 and card acceptor name is llvar
+	if !bytes.HasSuffix(b, []byte("00000700004SHOP")) {
+		t.Errorf("unexpected %s", b)
+	}
+	after := dictionary1993Struct{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after != v {
+		t.Errorf("expect %+v got %+v", v, after)
+	}
+
+	d1987, _ := LookupDictionary("1987")
+	d2003, _ := LookupDictionary("2003")
+	if f, _ := d1987.Field(12); f.Type != "numeric" || f.Length != 6 {
+		t.Errorf("1987 field 12 %+v", f)
+	}
+	if f, _ := d2003.Field(12); f.Length != 14 {
+		t.Errorf("2003 field 12 %+v", f)
+	}
+	if len(d1987.Fields()) != 126 {
+		t.Errorf("1987 expect fields 2 to 128 without 65, got %d", len(d1987.Fields()))
+	}
+	for _, name := range []string{"1987", "1993", "2003"} {
+		d, _ := LookupDictionary(name)
+		for _, n := range d.Fields() {
+			f, _ := d.Field(n)
+			if _, err := parseType(f.Type); err != nil || f.Length <= 0 || f.Class == "" || f.Description == "" {
+				t.Errorf("%s field %d is incomplete %+v", name, n, f)
+			}
+		}
+	}
+	type unknown struct {
+		Mti string `dict:"1999"`
+	}
+	if err := Validate[unknown](); err == nil || !strings.Contains(err.Error(), "Unsupport dictionary 1999") {
+		t.Errorf("unknown dictionary got %v", err)
+	}
+}
+
+func TestRegisterDictionary(t *testing.T) {
+	d, err := NewDictionary("x-test-network", map[int]DictionaryField{
+		48: {Type: "lllvar", Length: 200, Class: "ans", Description: "network data"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterDictionary(d); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterDictionary(d); err == nil {
+		t.Error("second register should fail")
+	}
+	type network struct {
+		Mti  string `dict:"x-test-network"`
+		Data string `field:"48"`
+	}
+	b, err := Marshal(network{Mti: "0200", Data: "abc"}, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(b, []byte("003abc")) {
+		t.Errorf("unexpected %s", b)
+	}
+	if _, err := NewDictionary("bad", map[int]DictionaryField{2: {Type: "nope"}}); err == nil {
+		t.Error("unknown type should fail")
+	}
+}
@@ -0,0 +1,100 @@
+package iso8583v2
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+//checksumCodec is a two digit length prefixed field followed by a one byte xor checksum
+type checksumCodec struct{}
+
+func (checksumCodec) Encode(tag FieldTag, value []byte) ([]byte, error) {
+	if len(value) > 99 {
+		return nil, errors.New("value is too long")
+	}
+	var sum byte
+	for _, b := range value {
+		sum ^= b
+	}
+	ret := []byte(fmt.Sprintf("%02d", len(value)))
+	ret = append(ret, value...)
+	return append(ret, sum), nil
+}
+
+func (checksumCodec) Decode(tag FieldTag, data []byte) ([]byte, []byte, error) {
+	if len(data) < 2 {
+		return nil, nil, errors.New("data is too short")
+	}
+	l, err := strconv.Atoi(string(data[:2]))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) < l+3 {
+		return nil, nil, errors.New("data is too short")
+	}
+	value := data[2 : 2+l]
+	var sum byte
+	for _, b := range value {
+		sum ^= b
+	}
+	if sum != data[2+l] {
+		return nil, nil, errors.New("checksum mismatch")
+	}
+	return value, data[3+l:], nil
+}
+
+func init() {
+	if err := RegisterFieldType("llvarsum", checksumCodec{}); err != nil {
+		panic(err)
+	}
+}
+
+type fieldTypeTestStruct struct {
+	Mti   string
+	Data  string            `field:"2" type:"LLVARSUM"`
+	Sub   marshalerSubField `field:"3" type:"llvarsum"`
+	Trace string            `field:"11" length:"6" type:"numeric"`
+}
+
+func TestRegisterFieldType(t *testing.T) {
+	before := fieldTypeTestStruct{
+		Mti:  "0200",
+		Data: "abc",
+		Sub: marshalerSubField{
+			Code: processingCode{"00", "00", "00"},
+			Name: "ab",
+		},
+		Trace: "000001",
+	}
+	b, err := Marshal(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := fieldTypeTestStruct{}
+	if err = Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Errorf("registered type round trip failed\n%+v\n%+v", before, after)
+	}
+
+	b[len(b)-7] ^= 0xff
+	if err = Unmarshal(b, &after); err == nil {
+		t.Error("corrupted checksum should fail")
+	}
+}
+
+func TestRegisterFieldTypeInvalid(t *testing.T) {
+	if err := RegisterFieldType("llvar", checksumCodec{}); err == nil {
+		t.Error("built in type should not be registered")
+	}
+	if err := RegisterFieldType("llvarsum", checksumCodec{}); err == nil {
+		t.Error("duplicate type should not be registered")
+	}
+	if err := RegisterFieldType("nilcodec", nil); err == nil {
+		t.Error("nil codec should not be registered")
+	}
+}
@@ -0,0 +1,219 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"testing"
+)
+
+var (
+	generatedMti = MustGenField("Mti", "")
+	generatedPan = MustGenField("Pan", `field:"2" type:"llvar"`)
+	generatedSub = MustGenField("Sub", `field:"48" type:"llvar" bitmapsize:"1"`)
+	generatedT1  = MustGenSubfield(generatedSub, "T1", `field:"1" length:"3"`)
+	generatedT2  = MustGenSubfield(generatedSub, "T2", `field:"2" length:"4"`)
+)
+
+type generatedSubField struct {
+	T1 string `field:"1" length:"3"`
+	T2 int    `field:"2" length:"4"`
+}
+
+//generatedTestStruct has hand written methods in the shape iso8583gen writes
+type generatedTestStruct struct {
+	Mti    string
+	Pan    string            `field:"2" type:"llvar"`
+	Sub    generatedSubField `field:"48" type:"llvar" bitmapsize:"1"`
+	called *bool
+}
+
+func (v generatedTestStruct) MarshalISO8583() ([]byte, error) {
+	*v.called = true
+	m := NewGenMessage()
+	b, err := generatedMti.EncodeMTI(v.Mti)
+	if err != nil {
+		return nil, err
+	}
+	m.SetMTI(b)
+	if b, err = generatedPan.EncodeString(v.Pan); err != nil {
+		return nil, err
+	}
+	m.Add(generatedPan, b)
+	s := generatedSub.NewStruct()
+	if b, err = generatedT1.EncodeString(v.Sub.T1); err != nil {
+		return nil, err
+	}
+	s.Add(generatedT1, b)
+	if b, err = generatedT2.EncodeInt(int64(v.Sub.T2)); err != nil {
+		return nil, err
+	}
+	s.Add(generatedT2, b)
+	if b, err = s.Bytes(); err != nil {
+		return nil, err
+	}
+	m.Add(generatedSub, b)
+	return m.Bytes()
+}
+
+func (v *generatedTestStruct) UnmarshalISO8583(data []byte) error {
+	*v.called = true
+	r, mti, err := NewGenReader(data, generatedMti)
+	if err != nil {
+		return err
+	}
+	v.Mti = mti
+	val, ok, err := r.Next(generatedPan)
+	if err != nil {
+		return err
+	} else if ok {
+		if v.Pan, err = generatedPan.DecodeString(val); err != nil {
+			return err
+		}
+	}
+	if val, ok, err = r.Next(generatedSub); err != nil {
+		return err
+	} else if ok {
+		s, err := generatedSub.NewStructReader(val)
+		if err != nil {
+			return err
+		}
+		if val, ok, err := s.Next(generatedT1); err != nil {
+			return err
+		} else if ok {
+			if v.Sub.T1, err = generatedT1.DecodeString(val); err != nil {
+				return err
+			}
+		}
+		if val, ok, err := s.Next(generatedT2); err != nil {
+			return err
+		} else if ok {
+			if i, ok, err := generatedT2.DecodeInt(val); err != nil {
+				return err
+			} else if ok {
+				v.Sub.T2 = int(i)
+			}
+		}
+	}
+	return nil
+}
+
+func TestMarshalPrefersGenerated(t *testing.T) {
+	called := false
+	v := generatedTestStruct{
+		Mti:    "0200",
+		Pan:    "1234",
+		Sub:    generatedSubField{T2: 12},
+		called: &called,
+	}
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Fatal("Marshal should call MarshalISO8583")
+	}
+	called = false
+	want, err := Marshal(&v, IgnoreGenerated())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if called {
+		t.Fatal("Marshal with IgnoreGenerated should not call MarshalISO8583")
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("generated %X, reflect %X", got, want)
+	}
+}
+
+func TestUnmarshalPrefersGenerated(t *testing.T) {
+	called := false
+	data, err := Marshal(generatedTestStruct{
+		Mti:    "0200",
+		Pan:    "1234",
+		Sub:    generatedSubField{T1: "abc", T2: 12},
+		called: &called,
+	}, IgnoreGenerated())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		opts      []Option
+		generated bool
+	}{
+		{nil, true},
+		{[]Option{IgnoreGenerated()}, false},
+		{[]Option{ZeroCopy()}, false},
+	} {
+		called = false
+		after := generatedTestStruct{called: &called}
+		if err := Unmarshal(data, &after, tc.opts...); err != nil {
+			t.Fatal(err)
+		}
+		if called != tc.generated {
+			t.Fatalf("UnmarshalISO8583 called %v, expected %v", called, tc.generated)
+		}
+		if after.Pan != "1234" || after.Sub.T1 != "abc" || after.Sub.T2 != 12 {
+			t.Fatalf("unexpected result %#v", after)
+		}
+	}
+}
+
+func TestGenReaderMissingData(t *testing.T) {
+	r, _, err := NewGenReader([]byte("0200\x40\x00\x00\x00\x00\x00\x00\x00"), generatedMti)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := r.Next(generatedPan); err != nil || ok {
+		t.Fatalf("field without data should be skipped ok:%v err:%v", ok, err)
+	}
+	r, _, err = NewGenReader([]byte("0200\x40\x00\x00\x00\x00\x00\x00\x0012"), generatedMti)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.Next(generatedPan); err == nil {
+		t.Fatal("expected error for a short llvar")
+	}
+}
+
+func TestParseGenFieldInvalid(t *testing.T) {
+	if _, err := ParseGenField("Pan", `field:"2" type:"unknown"`); err == nil {
+		t.Fatal("expected error for unknown type")
+	}
+	if _, err := ParseGenSubfield(generatedSub, "T1", `field:"0" length:"3"`); err == nil {
+		t.Fatal("expected error for sub field below 1")
+	}
+}
+
+func TestGenDecodeBytesParity(t *testing.T) {
+	type bytesStruct struct {
+		Mti  string
+		Ref  []byte `field:"37" type:"alpha" length:"8" pad:"left,*"`
+		Name []byte `field:"43" type:"alpha" length:"8" trim:"right"`
+	}
+	ref := MustGenField("Ref", `field:"37" type:"alpha" length:"8" pad:"left,*"`)
+	name := MustGenField("Name", `field:"43" type:"alpha" length:"8" trim:"right"`)
+	v := bytesStruct{Mti: "0200", Ref: []byte("AB"), Name: []byte("SHOP")}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := bytesStruct{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	r, _, err := NewGenReader(b, generatedMti)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		f    *GenField
+		want []byte
+	}{{ref, after.Ref}, {name, after.Name}} {
+		val, ok, err := r.Next(tt.f)
+		if err != nil || !ok {
+			t.Fatalf("read %s ok:%v err:%v", tt.f.tg.name, ok, err)
+		}
+		if got := tt.f.DecodeBytes(val); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s generated %q, reflect %q", tt.f.tg.name, got, tt.want)
+		}
+	}
+}
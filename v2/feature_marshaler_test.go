@@ -0,0 +1,97 @@
+package iso8583v2
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type processingCode struct {
+	Transaction string
+	From        string
+	To          string
+}
+
+func (p processingCode) MarshalISO8583Field(tag FieldTag) ([]byte, error) {
+	return []byte(p.Transaction + p.From + p.To), nil
+}
+
+func (p *processingCode) UnmarshalISO8583Field(tag FieldTag, data []byte) error {
+	if len(data) != tag.Length {
+		return fmt.Errorf("processing code length %d", len(data))
+	}
+	p.Transaction = string(data[0:2])
+	p.From = string(data[2:4])
+	p.To = string(data[4:6])
+	return nil
+}
+
+type pan string
+
+func (p pan) MarshalText() ([]byte, error) {
+	return []byte(strings.Replace(string(p), " ", "", -1)), nil
+}
+
+func (p *pan) UnmarshalText(b []byte) error {
+	*p = pan(string(b[:4]) + " " + string(b[4:]))
+	return nil
+}
+
+type marshalerSubField struct {
+	Code processingCode `field:"1" length:"6"`
+	Name string         `field:"2" length:"5"`
+}
+
+type marshalerTestStruct struct {
+	Mti  string
+	Pan  pan               `field:"2" type:"llvar"`
+	Code processingCode    `field:"3" length:"6" type:"numeric"`
+	Sub  marshalerSubField `field:"48" type:"lllvar"`
+	Ptr  *processingCode   `field:"61" length:"6" type:"alpha"`
+}
+
+func TestFieldMarshaler(t *testing.T) {
+	before := marshalerTestStruct{
+		Mti:  "0200",
+		Pan:  "4111 111111111111",
+		Code: processingCode{"00", "10", "00"},
+		Sub: marshalerSubField{
+			Code: processingCode{"31", "00", "00"},
+			Name: "abc",
+		},
+		Ptr: &processingCode{"01", "02", "03"},
+	}
+	b, err := Marshal(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "164111111111111111001000") {
+		t.Errorf("marshaler output not found %q", b)
+	}
+	if !strings.Contains(string(b), "011310000abc  010203") {
+		t.Errorf("fixed width marshaler output not found %q", b)
+	}
+
+	after := marshalerTestStruct{}
+	if err = Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Errorf("marshaler round trip failed\n%+v\n%+v", before, after)
+	}
+}
+
+func TestFieldMarshalerNilPointer(t *testing.T) {
+	b, err := Marshal(marshalerTestStruct{Mti: "0200", Code: processingCode{"00", "00", "00"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := marshalerTestStruct{}
+	if err = Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Ptr != nil {
+		t.Errorf("nil pointer should not be encoded %+v", after.Ptr)
+	}
+}
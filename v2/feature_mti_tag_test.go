@@ -0,0 +1,162 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type mtiTaggedStruct struct {
+	Type string `field:"mti" encode:"bcd"`
+	Pan  string `field:"2" type:"llvar"`
+}
+
+type mtiNamespacedStruct struct {
+	Type string `iso8583:"mti,enc=bcd"`
+	Pan  string `iso8583:"2,ll"`
+}
+
+//a field named mti with a field number is an ordinary field
+type mtiNamedFieldStruct struct {
+	Header string `field:"mti"`
+	Mti    string `field:"3" type:"numeric" length:"6"`
+}
+
+type mtiIntStruct struct {
+	Mti int    `encode:"ebcdic"`
+	Pan string `field:"2" type:"llvar"`
+}
+
+type mtiUintStruct struct {
+	Mti uint16 `encode:"hex"`
+	Pan string `field:"2" type:"llvar"`
+}
+
+type mtiText struct {
+	class, function byte
+}
+
+func (m mtiText) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("01%c%c", m.class, m.function)), nil
+}
+
+func (m *mtiText) UnmarshalText(b []byte) error {
+	m.class, m.function = b[2], b[3]
+	return nil
+}
+
+type mtiTextStruct struct {
+	Mti mtiText
+	Pan string `field:"2" type:"llvar"`
+}
+
+func TestMtiTag(t *testing.T) {
+	expect, err := Marshal(mtiTaggedStruct{Type: "0200", Pan: "4111"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(expect, []byte{0x02, 0x00}) {
+		t.Errorf("expect bcd mti got %x", expect)
+	}
+	b, err := Marshal(mtiNamespacedStruct{Type: "0200", Pan: "4111"}, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expect, b) {
+		t.Errorf("expect %x got %x", expect, b)
+	}
+	after := mtiTaggedStruct{}
+	if err := Unmarshal(b, &after, ValidateTags()); err != nil {
+		t.Fatal(err)
+	}
+	if after.Type != "0200" || after.Pan != "4111" {
+		t.Errorf("unexpected %+v", after)
+	}
+
+	b, err = Marshal(mtiNamedFieldStruct{Header: "0800", Mti: "123456"}, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:4]) != "0800" || !bytes.HasSuffix(b, []byte("123456")) {
+		t.Errorf("unexpected %s", b)
+	}
+}
+
+func TestMtiEncodings(t *testing.T) {
+	b, err := Marshal(mtiIntStruct{Mti: 200, Pan: "4111"}, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte{0xf0, 0xf2, 0xf0, 0xf0}) {
+		t.Errorf("expect ebcdic mti got %x", b)
+	}
+	intAfter := mtiIntStruct{}
+	if err := Unmarshal(b, &intAfter); err != nil {
+		t.Fatal(err)
+	}
+	if intAfter.Mti != 200 || intAfter.Pan != "4111" {
+		t.Errorf("unexpected %+v", intAfter)
+	}
+
+	b, err = Marshal(mtiUintStruct{Mti: 810, Pan: "4111"}, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte("30383130")) {
+		t.Errorf("expect hex mti got %s", b)
+	}
+	uintAfter := mtiUintStruct{}
+	if err := Compile[mtiUintStruct]().Unmarshal(b, &uintAfter); err != nil {
+		t.Fatal(err)
+	}
+	if uintAfter.Mti != 810 {
+		t.Errorf("unexpected %+v", uintAfter)
+	}
+
+	b, err = Marshal(mtiTextStruct{Mti: mtiText{class: '1', function: '0'}, Pan: "4111"}, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:4]) != "0110" {
+		t.Errorf("expect text mti got %s", b)
+	}
+	textAfter := mtiTextStruct{}
+	if err := Unmarshal(b, &textAfter); err != nil {
+		t.Fatal(err)
+	}
+	if textAfter.Mti != (mtiText{class: '1', function: '0'}) {
+		t.Errorf("unexpected %+v", textAfter)
+	}
+}
+
+func TestMtiInvalid(t *testing.T) {
+	if _, err := Marshal(mtiIntStruct{Mti: -200}); err == nil {
+		t.Error("negative mti should fail")
+	}
+	if _, err := Marshal(mtiIntStruct{Mti: 12345}); err == nil {
+		t.Error("five digit mti should fail")
+	}
+	if err := Unmarshal([]byte{0xf0, 0xc1, 0xf0, 0xf0}, &mtiIntStruct{}); err == nil {
+		t.Error("ebcdic letter in mti should fail")
+	}
+	type twoMti struct {
+		Mti  string
+		Type string `field:"mti"`
+	}
+	if err := Validate[twoMti](); err == nil || !strings.Contains(err.Error(), "field:Type is a second mti after field:Mti") {
+		t.Errorf("second mti got %v", err)
+	}
+	type badEncode struct {
+		Mti string `encode:"rbcd"`
+	}
+	if err := Validate[badEncode](); err == nil || !strings.Contains(err.Error(), "Unsupport mti encode rbcd") {
+		t.Errorf("rbcd mti got %v", err)
+	}
+	type floatMti struct {
+		Mti float64
+	}
+	if err := Validate[floatMti](); err == nil || !strings.Contains(err.Error(), "got float64") {
+		t.Errorf("float mti got %v", err)
+	}
+}
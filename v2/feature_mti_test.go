@@ -0,0 +1,97 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"testing"
+)
+
+type mtiTypeStruct struct {
+	Mti MTI    `encode:"bcd"`
+	Pan string `field:"2" type:"llvar"`
+}
+
+func TestParseMTI(t *testing.T) {
+	m, err := ParseMTI("1201")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := MTI{Version: Version1993, Class: ClassFinancial, Function: FunctionRequest, Origin: OriginAcquirerRepeat}
+	if m != expect {
+		t.Errorf("expect %+v got %+v", expect, m)
+	}
+	if m.String() != "1201" {
+		t.Errorf("expect 1201 got %s", m.String())
+	}
+	if !m.IsRequest() || m.IsResponse() || !m.IsRepeat() || m.IsAdvice() {
+		t.Errorf("unexpected flags of %s", m)
+	}
+	for _, s := range []string{"", "020", "02000", "02a0", "-200"} {
+		if _, err := ParseMTI(s); err == nil {
+			t.Errorf("%q should fail", s)
+		}
+	}
+}
+
+func TestMTIHelpers(t *testing.T) {
+	tests := []struct {
+		mti      string
+		response string
+		reversal string
+		repeat   string
+	}{
+		{"0100", "0110", "0400", "0101"},
+		{"0201", "0210", "0400", "0201"},
+		{"0220", "0230", "0420", "0221"},
+		{"2100", "2110", "2400", "2101"},
+		{"0800", "0810", "", "0801"},
+		{"0210", "", "", ""},
+	}
+	for _, tt := range tests {
+		m := MustParseMTI(tt.mti)
+		check := func(name string, expect string, got MTI, err error) {
+			if expect == "" {
+				if err == nil {
+					t.Errorf("%s of %s should fail, got %s", name, tt.mti, got)
+				}
+				return
+			}
+			if err != nil || got.String() != expect {
+				t.Errorf("%s of %s expect %s got %s %v", name, tt.mti, expect, got, err)
+			}
+		}
+		res, err := m.ResponseMTI()
+		check("response", tt.response, res, err)
+		rev, err := m.ReversalMTI()
+		check("reversal", tt.reversal, rev, err)
+		rep, err := m.RepeatMTI()
+		check("repeat", tt.repeat, rep, err)
+	}
+}
+
+func TestMTIField(t *testing.T) {
+	v := mtiTypeStruct{Mti: MustParseMTI("0200"), Pan: "4111"}
+	b, err := Marshal(v, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte{0x02, 0x00}) {
+		t.Errorf("expect bcd mti got %x", b)
+	}
+	after := mtiTypeStruct{}
+	if err := Unmarshal(b, &after, ValidateTags()); err != nil {
+		t.Fatal(err)
+	}
+	if after != v {
+		t.Errorf("expect %+v got %+v", v, after)
+	}
+	codecAfter := mtiTypeStruct{}
+	if err := Compile[mtiTypeStruct]().Unmarshal(b, &codecAfter); err != nil {
+		t.Fatal(err)
+	}
+	if codecAfter != v {
+		t.Errorf("codec expect %+v got %+v", v, codecAfter)
+	}
+	if _, err := Marshal(mtiTypeStruct{Pan: "4111"}); err == nil {
+		t.Error("zero mti should fail")
+	}
+}
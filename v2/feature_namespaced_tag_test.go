@@ -0,0 +1,169 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type legacyTagSub struct {
+	Terminal string `field:"1" length:"8"`
+	Name     string `field:"2" length:"10" cp:"ibm037"`
+}
+
+type legacyTagStruct struct {
+	Mti    string       `encode:"bcd"`
+	Pan    string       `field:"2" type:"llvar" encode:"bcd,ascii"`
+	Amount int64        `field:"4" type:"numeric" length:"12" encode:"bcd"`
+	Name   string       `field:"43" type:"alpha" length:"40" cp:"ibm037"`
+	Sub    legacyTagSub `field:"48" type:"lllvar" bitmapsize:"1"`
+	Mac    []byte       `field:"64" type:"binary" length:"8"`
+}
+
+type namespacedTagSub struct {
+	Terminal string `iso8583:"1,8" db:"terminal"`
+	Name     string `iso8583:"2,10,cp=ibm037"`
+}
+
+type namespacedTagStruct struct {
+	Mti    string           `iso8583:"enc=bcd"`
+	Pan    string           `iso8583:"2,llvar,lenc=bcd" json:"pan"`
+	Amount int64            `iso8583:"4,n,12,enc=bcd" json:"amount"`
+	Name   string           `iso8583:"43,ans,40,cp=ibm037"`
+	Sub    namespacedTagSub `iso8583:"48,lllvar,bitmapsize=1"`
+	Mac    []byte           `iso8583:"64,b,8"`
+}
+
+//the namespaced tag wins over the legacy keys of another library
+type namespacedPrecedenceStruct struct {
+	Mti  string
+	Name string `iso8583:"43,a,4" field:"5" type:"llvar"`
+}
+
+func TestNamespacedTag(t *testing.T) {
+	legacy := legacyTagStruct{
+		Mti:    "0200",
+		Pan:    "4111111111111111",
+		Amount: 100,
+		Name:   "SHOP",
+		Sub:    legacyTagSub{Terminal: "T1", Name: "N1"},
+		Mac:    []byte{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	namespaced := namespacedTagStruct{
+		Mti:    legacy.Mti,
+		Pan:    legacy.Pan,
+		Amount: legacy.Amount,
+		Name:   legacy.Name,
+		Sub:    namespacedTagSub{Terminal: "T1", Name: "N1"},
+		Mac:    legacy.Mac,
+	}
+	expect, err := Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Marshal(namespaced, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expect, b) {
+		t.Errorf("expect %x got %x", expect, b)
+	}
+	after := namespacedTagStruct{}
+	if err := Unmarshal(b, &after, ValidateTags()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(namespaced, after) {
+		t.Errorf("expect %+v got %+v", namespaced, after)
+	}
+	c, err := Compile[namespacedTagStruct]().Marshal(namespaced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expect, c) {
+		t.Errorf("codec expect %x got %x", expect, c)
+	}
+}
+
+func TestNamespacedTagPrecedence(t *testing.T) {
+	b, err := Marshal(namespacedPrecedenceStruct{Mti: "0800", Name: "ABCD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type legacy struct {
+		Mti  string
+		Name string `field:"43" type:"alpha" length:"4"`
+	}
+	after := legacy{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Name != "ABCD" {
+		t.Errorf("expect field 43 from the iso8583 tag got %+v", after)
+	}
+}
+
+func TestNamespacedTagInvalid(t *testing.T) {
+	type badKey struct {
+		Mti  string
+		Name string `iso8583:"43,a,4,width=4"`
+	}
+	type tooMany struct {
+		Mti  string
+		Name string `iso8583:"43,a,4,5"`
+	}
+	type repeated struct {
+		Mti  string
+		Name string `iso8583:"43,a,4,cp=ibm037,cp=ibm037"`
+	}
+	type badSub struct {
+		Terminal string `iso8583:"1,8,enc=bcd"`
+	}
+	type subKey struct {
+		Mti string
+		Sub badSub `iso8583:"48,lllvar"`
+	}
+	if err := Validate[badKey](); err == nil || !strings.Contains(err.Error(), "Unsupport iso8583 tag key width") {
+		t.Errorf("unknown key got %v", err)
+	}
+	if err := Validate[tooMany](); err == nil || !strings.Contains(err.Error(), "has more than 3 values") {
+		t.Errorf("too many values got %v", err)
+	}
+	if err := Validate[repeated](); err == nil || !strings.Contains(err.Error(), "key cp is repeated") {
+		t.Errorf("repeated key got %v", err)
+	}
+	if err := Validate[subKey](); err == nil || !strings.Contains(err.Error(), "subfield:Terminal Unsupport iso8583 tag key enc") {
+		t.Errorf("sub field key got %v", err)
+	}
+}
+
+//legacy keys the namespaced tag leaves out still apply
+type namespacedMergeStruct struct {
+	Mti  string
+	Name string `iso8583:"43,a,4" length:"8" cp:"ibm037"`
+}
+
+func TestNamespacedTagMerge(t *testing.T) {
+	b, err := Marshal(namespacedMergeStruct{Mti: "0800", Name: "ABCD"}, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	type legacy struct {
+		Mti  string
+		Name string `field:"43" type:"alpha" length:"4" cp:"ibm037"`
+	}
+	expect, err := Marshal(legacy{Mti: "0800", Name: "ABCD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expect, b) {
+		t.Errorf("expect %x got %x", expect, b)
+	}
+	after := namespacedMergeStruct{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Name != "ABCD" {
+		t.Errorf("expect ABCD got %+v", after)
+	}
+}
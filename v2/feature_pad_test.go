@@ -0,0 +1,181 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type padSub struct {
+	Id    string `field:"1" length:"6" pad:"left"`
+	Count int    `field:"2" length:"4" pad:"right,0"`
+	Code  string `iso8583:"3,4,pad=right:*"`
+}
+
+type padStruct struct {
+	Mti       string
+	Pan       string `field:"2" type:"llvar"`
+	ProcCode  string `field:"3" type:"numeric" length:"5" encode:"bcd" pad:"left,F"`
+	Amount    int64  `field:"4" type:"numeric" length:"7" encode:"rbcd" pad:"left,F"`
+	Stan      int    `field:"11" type:"numeric" length:"6" pad:"right,0"`
+	Terminal  string `field:"41" type:"alpha" length:"8" pad:"left"`
+	Merchant  string `field:"42" type:"alpha" length:"6" pad:"left,0"`
+	Name      string `field:"43" type:"alpha" length:"8" cp:"ibm037" pad:"left"`
+	Additions padSub `field:"48" type:"lllvar"`
+}
+
+func TestPad(t *testing.T) {
+	v := padStruct{
+		Mti:       "0200",
+		Pan:       "4111",
+		ProcCode:  "123",
+		Amount:    100,
+		Stan:      12,
+		Terminal:  "T1",
+		Merchant:  "42",
+		Name:      "SHOP",
+		Additions: padSub{Id: "AB", Count: 7, Code: "X"},
+	}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range [][]byte{
+		//5 digits left padded with F then the odd nibble F: FF123F
+		{0xff, 0x12, 0x3f},
+		//7 digits right aligned with F then the odd first nibble F: FFFFF100 -> F FFFF100
+		{0xff, 0xff, 0xf1, 0x00},
+		[]byte("120000"),
+		[]byte("      T1"),
+		[]byte("000042"),
+		{0x40, 0x40, 0x40, 0x40, 0xe2, 0xc8, 0xd6, 0xd7},
+		[]byte("    AB7000X***"),
+	} {
+		if !bytes.Contains(b, part) {
+			t.Errorf("expect %x in %x", part, b)
+		}
+	}
+	//a string keeps its digit padding, only numbers have it stripped
+	want := v
+	want.Merchant = "000042"
+	after := padStruct{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(after, want) {
+		t.Errorf("expect %+v got %+v", want, after)
+	}
+	codecAfter := padStruct{}
+	if err := Compile[padStruct]().Unmarshal(b, &codecAfter); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(codecAfter, want) {
+		t.Errorf("codec expect %+v got %+v", want, codecAfter)
+	}
+	//a value of only zeros is stripped to nothing and read back as 0
+	v.Additions.Count = 0
+	if b, err = Marshal(v); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("    AB0000X***")) {
+		t.Errorf("expect zero count in %x", b)
+	}
+	after = padStruct{Additions: padSub{Count: 1}}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	want.Additions.Count = 0
+	if !reflect.DeepEqual(after, want) {
+		t.Errorf("expect %+v got %+v", want, after)
+	}
+}
+
+func TestPadDigits(t *testing.T) {
+	type digitSub struct {
+		Code string `field:"1" length:"4" pad:"right,0"`
+	}
+	type digits struct {
+		Mti      string
+		ProcCode string   `field:"3" type:"numeric" length:"6" pad:"left,0"`
+		Amount   string   `field:"4" type:"numeric" length:"12" pad:"left,0"`
+		Entry    *int     `field:"22" type:"numeric" length:"3" pad:"left,0"`
+		Merchant string   `field:"42" type:"alpha" length:"6" pad:"right,0"`
+		Sub      digitSub `field:"48" type:"lllvar"`
+	}
+	if err := Validate[digits](); err != nil {
+		t.Fatal(err)
+	}
+	entry := 5
+	v := digits{Mti: "0200", ProcCode: "123", Amount: "100", Entry: &entry, Merchant: "42", Sub: digitSub{Code: "1"}}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{"000123", "000000000100", "005", "420000", "1000"} {
+		if !bytes.Contains(b, []byte(part)) {
+			t.Errorf("expect %s in %s", part, b)
+		}
+	}
+	after := digits{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	want := digits{Mti: "0200", ProcCode: "000123", Amount: "000000000100", Entry: &entry, Merchant: "420000", Sub: digitSub{Code: "1000"}}
+	if !reflect.DeepEqual(after, want) {
+		t.Errorf("expect %+v got %+v", want, after)
+	}
+	codecAfter := digits{}
+	if err := Compile[digits]().Unmarshal(b, &codecAfter); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(codecAfter, want) {
+		t.Errorf("codec expect %+v got %+v", want, codecAfter)
+	}
+}
+
+func TestPadDefault(t *testing.T) {
+	type defaults struct {
+		Mti      string
+		ProcCode string `field:"3" type:"numeric" length:"5" encode:"bcd"`
+		Terminal string `field:"41" type:"alpha" length:"8"`
+	}
+	b, err := Marshal(defaults{Mti: "0200", ProcCode: "123", Terminal: "T1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(b, append([]byte{0x00, 0x12, 0x30}, "T1      "...)) {
+		t.Errorf("without pad tag numeric pads 0 on the left and alpha spaces on the right, got %x", b)
+	}
+	after := defaults{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.ProcCode != "00123" || after.Terminal != "T1" {
+		t.Errorf("without pad tag decode keeps the padding and trims white space, got %+v", after)
+	}
+}
+
+func TestPadTagErrors(t *testing.T) {
+	type side struct {
+		Mti string
+		Id  string `field:"41" type:"alpha" length:"8" pad:"center"`
+	}
+	if err := Validate[side](); err == nil || !strings.Contains(err.Error(), "Unsupport pad center") {
+		t.Errorf("unknown side got %v", err)
+	}
+	type char struct {
+		Mti string
+		Id  string `field:"41" type:"alpha" length:"8" pad:"left,ab"`
+	}
+	if err := Validate[char](); err == nil || !strings.Contains(err.Error(), "Unsupport pad character") {
+		t.Errorf("long character got %v", err)
+	}
+	type llvar struct {
+		Mti string
+		Pan string `field:"2" type:"llvar" pad:"left"`
+	}
+	if err := Validate[llvar](); err == nil || !strings.Contains(err.Error(), "pad is only applied to numeric and alpha fields") {
+		t.Errorf("llvar pad got %v", err)
+	}
+}
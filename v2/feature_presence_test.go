@@ -0,0 +1,148 @@
+package iso8583v2
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type presenceStruct struct {
+	Mti      string
+	Pan      string `field:"2" type:"llvar"`
+	ProcCode string `field:"3" type:"numeric" length:"6"`
+	Amount   int64  `field:"4" type:"numeric" length:"12"`
+	Expiry   string `field:"14" type:"numeric" length:"4"`
+	Entry    int    `field:"22" type:"numeric" length:"3"`
+	Action   string `field:"39" type:"alpha" length:"2"`
+}
+
+var presenceRules = MustPresenceRules(
+	PresenceRule{MTI: "0200", Mandatory: []int{2, 3, 4}},
+	PresenceRule{MTI: "0200", Conditional: []ConditionalField{{Field: 14, When: 22, Prefix: "05"}}},
+	PresenceRule{MTI: "0210", Mandatory: []int{39}},
+)
+
+func TestEnforcePresence(t *testing.T) {
+	v := presenceStruct{Mti: "0200", Pan: "4111", ProcCode: "000000", Amount: 1, Entry: 51}
+	_, err := Marshal(v, EnforcePresence(presenceRules))
+	var presenceErr *PresenceError
+	if !errors.As(err, &presenceErr) || presenceErr.MTI != "0200" || !reflect.DeepEqual(presenceErr.Missing, []int{14}) {
+		t.Fatalf("expect field 14 missing got %v", err)
+	}
+	v.Expiry = "2612"
+	b, err := Marshal(v, EnforcePresence(presenceRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Mti = "0201"
+	v.Pan, v.Amount = "", 0
+	_, err = Marshal(v, EnforcePresence(presenceRules))
+	if !errors.As(err, &presenceErr) || !reflect.DeepEqual(presenceErr.Missing, []int{2, 4}) {
+		t.Errorf("repeat should use the rule of 0200, got %v", err)
+	}
+	v = presenceStruct{Mti: "0200", Pan: "4111", ProcCode: "000000", Amount: 1, Entry: 21}
+	if _, err := Marshal(v, EnforcePresence(presenceRules)); err != nil {
+		t.Errorf("field 22 not 05x needs no field 14, got %v", err)
+	}
+	if _, err := Marshal(presenceStruct{Mti: "0800"}, EnforcePresence(presenceRules)); err != nil {
+		t.Errorf("mti without rule got %v", err)
+	}
+
+	after := presenceStruct{}
+	if err := Unmarshal(b, &after, EnforcePresence(presenceRules)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Marshal(presenceStruct{Mti: "0210", Pan: "4111"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after = presenceStruct{}
+	err = Unmarshal(res, &after, EnforcePresence(presenceRules))
+	if !errors.As(err, &presenceErr) || !reflect.DeepEqual(presenceErr.Missing, []int{39}) {
+		t.Errorf("expect field 39 missing got %v", err)
+	}
+	if after.Pan != "4111" {
+		t.Errorf("Unmarshal should decode the message before it reports, got %+v", after)
+	}
+	codecAfter := presenceStruct{}
+	err = Compile[presenceStruct]().Unmarshal(res, &codecAfter, EnforcePresence(presenceRules))
+	if !errors.As(err, &presenceErr) {
+		t.Errorf("codec expect presence error got %v", err)
+	}
+}
+
+func TestReadPresenceRules(t *testing.T) {
+	r, err := ReadPresenceRules([]byte(`{"name": "Authorization", "fields": [], "rules": [
+		{"mti": "0100", "mandatory": [2, 3], "conditional": [{"field": 52, "when": 3, "prefix": "01"}]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, ok := r.Rule("0101")
+	expect := PresenceRule{MTI: "0100", Mandatory: []int{2, 3}, Conditional: []ConditionalField{{Field: 52, When: 3, Prefix: "01"}}}
+	if !ok || !reflect.DeepEqual(rule, expect) {
+		t.Errorf("expect %+v got %+v", expect, rule)
+	}
+	if _, ok := r.Rule("0110"); ok {
+		t.Error("0110 has no rule")
+	}
+	for _, bad := range []PresenceRule{
+		{MTI: "200"},
+		{MTI: "0200", Mandatory: []int{1}},
+		{MTI: "0200", Conditional: []ConditionalField{{Field: 14, When: 129}}},
+	} {
+		if _, err := NewPresenceRules(bad); err == nil {
+			t.Errorf("%+v should fail", bad)
+		}
+	}
+}
+
+func TestCodecEnforcePresence(t *testing.T) {
+	c := Compile[presenceStruct](EnforcePresence(presenceRules))
+	_, err := c.Marshal(presenceStruct{Mti: "0200", ProcCode: "000000", Amount: 1})
+	var presenceErr *PresenceError
+	if !errors.As(err, &presenceErr) || !reflect.DeepEqual(presenceErr.Missing, []int{2}) {
+		t.Errorf("expect field 2 missing got %v", err)
+	}
+	v := presenceStruct{Mti: "0200", Pan: "4111", ProcCode: "000000", Amount: 1, Entry: 51}
+	if _, err := c.Marshal(v); !errors.As(err, &presenceErr) || !reflect.DeepEqual(presenceErr.Missing, []int{14}) {
+		t.Errorf("expect field 14 missing got %v", err)
+	}
+	v.Expiry = "2612"
+	if _, err := c.Marshal(v); err != nil {
+		t.Error(err)
+	}
+}
+
+type presencePadStruct struct {
+	Mti    string
+	Pan    string `field:"2" type:"llvar"`
+	Entry  int    `field:"22" type:"numeric" length:"3" encode:"bcd" pad:"left,F"`
+	Region string `field:"43" type:"alpha" length:"4" pad:"left,*"`
+}
+
+func TestPresenceValuePad(t *testing.T) {
+	rules := MustPresenceRules(PresenceRule{MTI: "0200", Conditional: []ConditionalField{
+		{Field: 2, When: 22, Prefix: "FF"},
+		{Field: 2, When: 43, Prefix: "**"},
+	}})
+	for _, tt := range []struct {
+		v       presencePadStruct
+		missing bool
+	}{
+		{presencePadStruct{Mti: "0200", Entry: 5}, true},
+		{presencePadStruct{Mti: "0200", Entry: 51}, false},
+		{presencePadStruct{Mti: "0200", Entry: 105}, false},
+		{presencePadStruct{Mti: "0200", Entry: 105, Region: "EU"}, true},
+		{presencePadStruct{Mti: "0200", Entry: 105, Region: "EURO"}, false},
+	} {
+		_, err := Marshal(tt.v, EnforcePresence(rules))
+		if missing := err != nil; missing != tt.missing {
+			t.Errorf("%+v expect missing %v got %v", tt.v, tt.missing, err)
+		}
+		_, err = Compile[presencePadStruct](EnforcePresence(rules)).Marshal(tt.v)
+		if missing := err != nil; missing != tt.missing {
+			t.Errorf("codec %+v expect missing %v got %v", tt.v, tt.missing, err)
+		}
+	}
+}
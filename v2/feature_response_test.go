@@ -0,0 +1,135 @@
+package iso8583v2
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type responseRequest struct {
+	Mti          string
+	Pan          string `field:"2"`
+	ProcCode     string `field:"3"`
+	Amount       int64  `field:"4"`
+	Transmission string `field:"7"`
+	Stan         int    `field:"11"`
+	Pin          []byte `field:"52"`
+}
+
+type responseReply struct {
+	Mti      MTI
+	Pan      string  `field:"2"`
+	ProcCode string  `field:"3"`
+	Amount   *int64  `field:"4"`
+	Stan     int32   `field:"11"`
+	Approval *string `field:"38"`
+	Action   string  `field:"39"`
+}
+
+func TestNewResponse(t *testing.T) {
+	req := responseRequest{
+		Mti:          "0201",
+		Pan:          "4111111111111111",
+		ProcCode:     "000000",
+		Amount:       100,
+		Transmission: "1019120000",
+		Stan:         7,
+		Pin:          []byte{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	res, err := NewResponse(req, map[int]interface{}{4: int64(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := req
+	expect.Mti = "0210"
+	expect.Pin = nil
+	if !reflect.DeepEqual(res, expect) {
+		t.Errorf("expect %+v got %+v", expect, res)
+	}
+	ptr, err := NewResponse(&req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ptr.Mti != "0210" || ptr.Stan != 7 {
+		t.Errorf("unexpected %+v", ptr)
+	}
+
+	reply := responseReply{Action: "stale"}
+	if err := FillResponse(&reply, &req, map[int]interface{}{38: "A1B2C3", 39: "00"}); err != nil {
+		t.Fatal(err)
+	}
+	amount, approval := int64(100), "A1B2C3"
+	expectReply := responseReply{Mti: MustParseMTI("0210"), Pan: req.Pan, ProcCode: req.ProcCode, Amount: &amount, Stan: 7, Approval: &approval, Action: "00"}
+	if !reflect.DeepEqual(reply, expectReply) {
+		t.Errorf("expect %+v got %+v", expectReply, reply)
+	}
+}
+
+func TestNewResponseErrors(t *testing.T) {
+	req := responseRequest{Mti: "0210"}
+	if _, err := NewResponse(req, nil); err == nil {
+		t.Error("a response has no response")
+	}
+	req.Mti = "0200"
+	if _, err := NewResponse(req, map[int]interface{}{39: "00"}); err == nil || !strings.Contains(err.Error(), "no field 39") {
+		t.Errorf("unknown override field got %v", err)
+	}
+	if _, err := NewResponse(req, map[int]interface{}{11: "7"}); err == nil {
+		t.Error("override of another kind should fail")
+	}
+	if err := FillResponse(responseReply{}, req, nil); err == nil {
+		t.Error("response not a pointer should fail")
+	}
+}
+
+func TestEchoFields(t *testing.T) {
+	defer SetEchoFields(ClassNetworkManagement, EchoFields(ClassNetworkManagement)...)
+	if err := SetEchoFields(ClassNetworkManagement, 11); err != nil {
+		t.Fatal(err)
+	}
+	req := responseRequest{Mti: "0800", Transmission: "1019120000", Stan: 3}
+	res, err := NewResponse(req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, responseRequest{Mti: "0810", Stan: 3}) {
+		t.Errorf("unexpected %+v", res)
+	}
+	if err := SetEchoFields(ClassNetworkManagement, 65); err == nil {
+		t.Error("field 65 should fail")
+	}
+}
+
+func TestNewResponseFields(t *testing.T) {
+	req := map[int]interface{}{
+		2:  "4111111111111111",
+		3:  "000000",
+		4:  int64(100),
+		11: 7,
+		52: []byte{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	mti, res, err := NewResponseFields("0200", req, map[int]interface{}{2: nil, 38: "ABC123", 39: "00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mti != "0210" {
+		t.Errorf("expect mti 0210 got %s", mti)
+	}
+	expect := map[int]interface{}{3: "000000", 4: int64(100), 11: 7, 38: "ABC123", 39: "00"}
+	if !reflect.DeepEqual(expect, res) {
+		t.Errorf("expect %v got %v", expect, res)
+	}
+	mti, text, err := NewResponseFields("0420", map[int]string{11: "000007", 90: "0200000007"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mti != "0430" || !reflect.DeepEqual(text, map[int]string{11: "000007", 90: "0200000007"}) {
+		t.Errorf("unexpected reversal response %s %v", mti, text)
+	}
+	if _, _, err := NewResponseFields("0210", req, nil); err == nil {
+		t.Errorf("expect an error for a response mti")
+	}
+	if _, _, err := NewResponseFields("0200", req, map[int]interface{}{1: "x"}); err == nil {
+		t.Errorf("expect an error for an override of the bitmap")
+	}
+}
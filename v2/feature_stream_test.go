@@ -0,0 +1,123 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalAppend(t *testing.T) {
+	init := TestBitmapIso{
+		Mti:         "0800",
+		TransmissDt: "123123",
+		TraceNum:    "123456",
+		SendingID:   "004",
+		T: T48{
+			T1: "ทดสอบทดสอบ",
+			T3: 1,
+		},
+		Rrn:         "908232123",
+		NetworkCode: "80",
+	}
+	want, err := Marshal(init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := make([]byte, 2, 512)
+	dst[0], dst[1] = 0xab, 0xcd
+	b, err := MarshalAppend(dst, init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b[:2], []byte{0xab, 0xcd}) || !bytes.Equal(b[2:], want) {
+		t.Errorf("append output differs %x", b)
+	}
+	if &b[0] != &dst[0] {
+		t.Error("dst with enough capacity should be reused")
+	}
+	c, err := Compile[TestBitmapIso]().MarshalAppend(dst[:2], init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c, b) {
+		t.Errorf("codec append output differs %x", c)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	first := test3{Mti: "0200", T1: "test", T3: "123"}
+	second := hexTestStruct{Mti: "0210", Emv: "ea4709"}
+	if err := enc.Encode(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(&second); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(test3{}); err == nil {
+		t.Error("invalid message should fail")
+	}
+	b1, _ := Marshal(first)
+	b2, _ := Marshal(second)
+	if !bytes.Equal(w.Bytes(), append(b1, b2...)) {
+		t.Errorf("encoder output differs %x", w.Bytes())
+	}
+}
+
+func TestDecoderFraming(t *testing.T) {
+	first := test3{Mti: "0200", T1: "test", T3: "0000000123"}
+	second := test3{Mti: "0210", T1: "resp", T3: "0000000456"}
+	b1, _ := Marshal(first)
+	b2, _ := Marshal(second)
+
+	headers := map[Framing]func(n int) []byte{
+		FramingBinary2: func(n int) []byte { return []byte{byte(n >> 8), byte(n)} },
+		FramingASCII4:  func(n int) []byte { return []byte(fmt.Sprintf("%04d", n)) },
+		FramingBCD2: func(n int) []byte {
+			b, _ := rbcdEncode([]byte(fmt.Sprintf("%04d", n)))
+			return b
+		},
+	}
+	for framing, header := range headers {
+		var stream []byte
+		stream = append(stream, header(len(b1))...)
+		stream = append(stream, b1...)
+		stream = append(stream, header(len(b2))...)
+		stream = append(stream, b2...)
+
+		dec := NewDecoder(bytes.NewReader(stream), framing)
+		var got1, got2 test3
+		if err := dec.Decode(&got1); err != nil {
+			t.Fatalf("framing %d %s", framing, err.Error())
+		}
+		if err := dec.Decode(&got2); err != nil {
+			t.Fatalf("framing %d %s", framing, err.Error())
+		}
+		if !reflect.DeepEqual(first, got1) || !reflect.DeepEqual(second, got2) {
+			t.Errorf("framing %d decoded wrongly %+v %+v", framing, got1, got2)
+		}
+		if err := dec.Decode(&got1); err != io.EOF {
+			t.Errorf("framing %d end of stream should be io.EOF %v", framing, err)
+		}
+	}
+}
+
+func TestDecoderLimits(t *testing.T) {
+	dec := NewDecoder(bytes.NewReader([]byte("0100")), FramingASCII4)
+	dec.SetMaxMessageSize(99)
+	if err := dec.Decode(&test3{}); err == nil {
+		t.Error("message over maximum size should fail")
+	}
+	dec = NewDecoder(bytes.NewReader([]byte("0010abc")), FramingASCII4)
+	if err := dec.Decode(&test3{}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated message should be io.ErrUnexpectedEOF %v", err)
+	}
+	dec = NewDecoder(bytes.NewReader([]byte{0x00}), FramingBinary2)
+	if err := dec.Decode(&test3{}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated header should be io.ErrUnexpectedEOF %v", err)
+	}
+}
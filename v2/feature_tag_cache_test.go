@@ -0,0 +1,89 @@
+package iso8583v2
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/henglory/iso8583/v2/internal/tagcachetest"
+)
+
+func TestTagCacheAnonymousStruct(t *testing.T) {
+	first := struct {
+		Mti string
+		A   string `field:"2" type:"llvar"`
+	}{Mti: "0200", A: "abc"}
+	second := struct {
+		Mti string
+		B   string `field:"3" length:"6" type:"numeric"`
+	}{Mti: "0200", B: "123456"}
+
+	b1, err := Marshal(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, err := Marshal(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.TypeOf(first).Name() != reflect.TypeOf(second).Name() {
+		t.Fatal("anonymous structs should share an empty name")
+	}
+	firstAfter := first
+	firstAfter.A = ""
+	if err = Unmarshal(b1, &firstAfter); err != nil || firstAfter != first {
+		t.Errorf("anonymous struct decoded wrongly %+v %v", firstAfter, err)
+	}
+	secondAfter := second
+	secondAfter.B = ""
+	if err = Unmarshal(b2, &secondAfter); err != nil || secondAfter != second {
+		t.Errorf("anonymous struct decoded wrongly %+v %v", secondAfter, err)
+	}
+	if reflect.ValueOf(cachedTag(reflect.TypeOf(first))).Pointer() == reflect.ValueOf(cachedTag(reflect.TypeOf(second))).Pointer() {
+		t.Error("anonymous structs share a tag map")
+	}
+}
+
+func TestTagCacheSameNameOtherPackage(t *testing.T) {
+	other := tagcachetest.TestIso{
+		Mti: "0800",
+		T:   tagcachetest.T48{T1: "abc"},
+	}
+	local := TestIso{
+		Mti:         "0800",
+		TransmissDt: "0000123123",
+		TraceNum:    "123456",
+		SendingID:   "004",
+		T: T48{
+			T1: "ทดสอบทดสอบ",
+			T2: "123",
+			T3: 1,
+		},
+		Rrn:         "000908232123",
+		NetworkCode: "080",
+	}
+	bo, err := Marshal(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bl, err := Marshal(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherAfter := tagcachetest.TestIso{}
+	if err = Unmarshal(bo, &otherAfter); err != nil || !reflect.DeepEqual(other, otherAfter) {
+		t.Errorf("other package type decoded wrongly %+v %v", otherAfter, err)
+	}
+	localAfter := TestIso{}
+	if err = Unmarshal(bl, &localAfter); err != nil {
+		t.Fatal(err)
+	}
+	if localAfter.T != local.T {
+		t.Errorf("local type decoded wrongly %+v", localAfter)
+	}
+	if reflect.ValueOf(cachedTag(reflect.TypeOf(other))).Pointer() == reflect.ValueOf(cachedTag(reflect.TypeOf(local))).Pointer() {
+		t.Error("same named structs share a tag map")
+	}
+	if reflect.ValueOf(cachedFixedwidthTag(reflect.TypeOf(other.T))).Pointer() == reflect.ValueOf(cachedFixedwidthTag(reflect.TypeOf(local.T))).Pointer() {
+		t.Error("same named sub field structs share a tag map")
+	}
+}
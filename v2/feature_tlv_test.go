@@ -0,0 +1,178 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type issuerScript struct {
+	Id       []byte `tlv:"9F18"`
+	Commands []byte `tlv:"86"`
+}
+
+type iccData struct {
+	AppLabel   string        `tlv:"50,an"`
+	Pan        string        `tlv:"5A,cn"`
+	Currency   string        `tlv:"5F2A,n3"`
+	Amount     int64         `tlv:"9F02,n12"`
+	Other      *int64        `tlv:"9F03,n12"`
+	Cryptogram string        `tlv:"9F26,b"`
+	Script     *issuerScript `tlv:"71"`
+	Ignored    string
+}
+
+type tlvStruct struct {
+	Mti  string
+	Icc  iccData `field:"55" type:"tlv"`
+	Raw  []byte  `field:"56" type:"tlv" encode:"bcd,ascii"`
+	List TLVList `field:"57" type:"tlv"`
+}
+
+func TestTLVStruct(t *testing.T) {
+	zero := int64(0)
+	v := tlvStruct{
+		Mti: "0200",
+		Icc: iccData{
+			AppLabel:   "VISA",
+			Pan:        "4111111111111",
+			Currency:   "978",
+			Amount:     1000,
+			Other:      &zero,
+			Cryptogram: "0102030405060708",
+			Script:     &issuerScript{Id: []byte{0x01, 0x02}, Commands: bytes.Repeat([]byte{0xaa}, 130)},
+		},
+		Raw:  []byte{0x9f, 0x36, 0x02, 0x00, 0x01},
+		List: TLVList{{Tag: "9F10", Value: []byte{0x06}}, {Tag: "BF0C", Children: TLVList{{Tag: "9F4D", Value: []byte{0x0b, 0x0a}}}}},
+	}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range [][]byte{
+		{0x50, 0x04, 'V', 'I', 'S', 'A'},
+		//13 digits left justified with F
+		{0x5a, 0x07, 0x41, 0x11, 0x11, 0x11, 0x11, 0x11, 0x1f},
+		{0x5f, 0x2a, 0x02, 0x09, 0x78},
+		{0x9f, 0x02, 0x06, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00},
+		//a set pointer is written even when zero
+		{0x9f, 0x03, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		{0x9f, 0x26, 0x08, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+		//a constructed tag of 138 bytes has a long form length
+		{0x71, 0x81, 0x8a, 0x9f, 0x18, 0x02, 0x01, 0x02, 0x86, 0x81, 0x82, 0xaa},
+		{0x00, 0x05, 0x9f, 0x36, 0x02, 0x00, 0x01},
+		[]byte("012\x9f\x10\x01\x06\xbf\x0c\x05\x9f\x4d\x02\x0b\x0a"),
+	} {
+		if !bytes.Contains(b, part) {
+			t.Errorf("expect %x in %x", part, b)
+		}
+	}
+	after := tlvStruct{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	expect := v
+	expect.List[1].Value = []byte{0x9f, 0x4d, 0x02, 0x0b, 0x0a}
+	if !reflect.DeepEqual(after, expect) {
+		t.Errorf("expect %+v got %+v", expect, after)
+	}
+	codecAfter := tlvStruct{}
+	codec := Compile[tlvStruct]()
+	cb, err := codec.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cb, b) {
+		t.Errorf("codec encodes %x expect %x", cb, b)
+	}
+	if err := codec.Unmarshal(b, &codecAfter); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(codecAfter, expect) {
+		t.Errorf("codec expect %+v got %+v", expect, codecAfter)
+	}
+	empty, err := Marshal(tlvStruct{Mti: "0200"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(empty, []byte("0200\x00\x00\x00\x00\x00\x00\x00\x00")) {
+		t.Errorf("an empty tlv struct should leave the field out, got %x", empty)
+	}
+}
+
+func TestParseTLV(t *testing.T) {
+	l, err := ParseTLV([]byte{0x00, 0x9f, 0x1f, 0x81, 0x01, 0x31, 0x00, 0x70, 0x03, 0x5f, 0x20, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := TLVList{
+		{Tag: "9F1F", Value: []byte{0x31}},
+		{Tag: "70", Value: []byte{0x5f, 0x20, 0x00}, Children: TLVList{{Tag: "5F20", Value: []byte{}}}},
+	}
+	if !reflect.DeepEqual(l, expect) {
+		t.Errorf("expect %+v got %+v", expect, l)
+	}
+	if found, ok := l.Find("5f20"); !ok || found.Tag != "5F20" {
+		t.Errorf("5F20 should be found in the children of 70, got %+v", found)
+	}
+	if b, err := l.Bytes(); err != nil || !bytes.Equal(b, []byte{0x9f, 0x1f, 0x01, 0x31, 0x70, 0x03, 0x5f, 0x20, 0x00}) {
+		t.Errorf("unexpected encode %x %v", b, err)
+	}
+	if !l[1].Constructed() || l[0].Constructed() {
+		t.Error("70 is constructed and 9F1F is not")
+	}
+	for _, bad := range [][]byte{
+		{0x9f},
+		{0x9f, 0x02},
+		{0x9f, 0x02, 0x06, 0x00},
+		{0x9f, 0x02, 0x80},
+		{0x70, 0x02, 0x9f, 0x02},
+	} {
+		if _, err := ParseTLV(bad); err == nil {
+			t.Errorf("%x should fail", bad)
+		}
+	}
+	if _, err := Marshal(tlvStruct{Mti: "0200", Raw: []byte{0x9f, 0x02, 0x06}}); err == nil {
+		t.Error("invalid tlv bytes should fail")
+	}
+}
+
+func TestTLVTagErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  func() error
+		err  string
+	}{
+		{"incomplete tag", Validate[struct {
+			Mti string
+			Icc struct {
+				Amount int64 `tlv:"9F"`
+			} `field:"55" type:"tlv"`
+		}], "Unsupport tlv tag 9F"},
+		{"primitive struct", Validate[struct {
+			Mti string
+			Icc struct {
+				Script issuerScript `tlv:"9F18"`
+			} `field:"55" type:"tlv"`
+		}], "is not constructed"},
+		{"format", Validate[struct {
+			Mti string
+			Icc struct {
+				Amount int64 `tlv:"9F02,b"`
+			} `field:"55" type:"tlv"`
+		}], "format does not support int64"},
+		{"shared tag", Validate[struct {
+			Mti string
+			Icc struct {
+				Amount int64  `tlv:"9F02"`
+				Text   string `tlv:"9f02,n12"`
+			} `field:"55" type:"tlv"`
+		}], "shares tlv tag 9F02 with subfield:Amount"},
+	}
+	for _, tt := range tests {
+		if err := tt.typ(); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s expect %q got %v", tt.name, tt.err, err)
+		}
+	}
+}
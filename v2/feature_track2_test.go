@@ -0,0 +1,120 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type track2Struct struct {
+	Mti   string
+	Track Track2 `field:"35" type:"track2"`
+}
+
+type track2BcdStruct struct {
+	Mti   string
+	Track Track2 `field:"35" type:"track2" encode:"bcd,bcd"`
+}
+
+type track2StringStruct struct {
+	Mti   string
+	Track string `iso8583:"35,track2,enc=bcd"`
+}
+
+var testTrack2 = Track2{PAN: "4111111111111111", Expiry: "2612", ServiceCode: "101", Discretionary: "12345"}
+
+func TestTrack2Ascii(t *testing.T) {
+	b, err := Marshal(track2Struct{Mti: "0200", Track: testTrack2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(b, []byte("294111111111111111=261210112345")) {
+		t.Errorf("unexpected ascii track 2 %q", b)
+	}
+	after := track2Struct{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Track != testTrack2 {
+		t.Errorf("expect %+v got %+v", testTrack2, after.Track)
+	}
+}
+
+func TestTrack2Bcd(t *testing.T) {
+	b, err := Marshal(track2BcdStruct{Mti: "0200", Track: testTrack2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//29 characters, the separator is the nibble D and the odd length is padded with F
+	expect := []byte{0x29, 0x41, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0xd2, 0x61, 0x21, 0x01, 0x12, 0x34, 0x5f}
+	if !bytes.HasSuffix(b, expect) {
+		t.Errorf("expect %x in %x", expect, b)
+	}
+	after := track2BcdStruct{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Track != testTrack2 {
+		t.Errorf("expect %+v got %+v", testTrack2, after.Track)
+	}
+	codecAfter := track2BcdStruct{}
+	if err := Compile[track2BcdStruct]().Unmarshal(b, &codecAfter); err != nil {
+		t.Fatal(err)
+	}
+	if codecAfter.Track != testTrack2 {
+		t.Errorf("codec expect %+v got %+v", testTrack2, codecAfter.Track)
+	}
+}
+
+func TestTrack2String(t *testing.T) {
+	b, err := Marshal(track2StringStruct{Mti: "0200", Track: "4111111111111111=2612101"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//enc sets the value encode only, the length stays ascii
+	expect := append([]byte("24"), 0x41, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0xd2, 0x61, 0x21, 0x01)
+	if !bytes.HasSuffix(b, expect) {
+		t.Errorf("unexpected bcd track 2 %x", b)
+	}
+	after := track2StringStruct{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Track != "4111111111111111=2612101" {
+		t.Errorf("a string keeps = as the separator, got %s", after.Track)
+	}
+	if _, err := Marshal(track2StringStruct{Mti: "0200", Track: "4111^2612"}); err == nil || !strings.Contains(err.Error(), "is not a digit or separator") {
+		t.Errorf("expect separator error got %v", err)
+	}
+}
+
+func TestParseTrack2(t *testing.T) {
+	tests := []struct {
+		text   string
+		expect Track2
+	}{
+		{"4111D2612101", Track2{PAN: "4111", Expiry: "2612", ServiceCode: "101"}},
+		{"4111==101999", Track2{PAN: "4111", ServiceCode: "101", Discretionary: "999"}},
+		{"4111=2612=999", Track2{PAN: "4111", Expiry: "2612", Discretionary: "999"}},
+		{"4111=", Track2{PAN: "4111"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseTrack2(tt.text)
+		if err != nil {
+			t.Errorf("%s: %s", tt.text, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expect) {
+			t.Errorf("%s: expect %+v got %+v", tt.text, tt.expect, got)
+		}
+		if back, _ := ParseTrack2(got.String()); back != got {
+			t.Errorf("%s: String %s does not parse back", tt.text, got.String())
+		}
+	}
+	for _, bad := range []string{"4111", "=2612", "4111=26", "41A1=2612", "4111=2612101X"} {
+		if _, err := ParseTrack2(bad); err == nil {
+			t.Errorf("%s should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package iso8583v2
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type trimSub struct {
+	Id   string `field:"1" length:"6"`
+	Name string `field:"2" length:"6" trim:"none"`
+	Code string `iso8583:"3,6,trim=left"`
+}
+
+type trimStruct struct {
+	Mti      string
+	Pan      string  `field:"2" type:"llvar"`
+	Terminal string  `field:"41" type:"alpha" length:"8"`
+	Merchant string  `field:"42" type:"alpha" length:"8" trim:"right"`
+	Name     string  `field:"43" type:"alpha" length:"8" trim:"both"`
+	Private  string  `field:"44" type:"llvar" trim:"right"`
+	Sub      trimSub `field:"48" type:"lllvar"`
+}
+
+func TestTrim(t *testing.T) {
+	v := trimStruct{
+		Mti:      "0200",
+		Pan:      "4111 ",
+		Terminal: "T1",
+		Merchant: " M1",
+		Name:     " SHOP",
+		Private:  "P1  ",
+		Sub:      trimSub{Id: " A", Name: " B", Code: " C"},
+	}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := trimStruct{
+		Mti:      "0200",
+		Pan:      "4111 ",
+		Terminal: "T1",
+		Merchant: " M1",
+		Name:     "SHOP",
+		Private:  "P1",
+		Sub:      trimSub{Id: "A", Name: " B    ", Code: "C    "},
+	}
+	after := trimStruct{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(after, expect) {
+		t.Errorf("expect %+v got %+v", expect, after)
+	}
+	codecAfter := trimStruct{}
+	if err := Compile[trimStruct]().Unmarshal(b, &codecAfter); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(codecAfter, expect) {
+		t.Errorf("codec expect %+v got %+v", expect, codecAfter)
+	}
+}
+
+func TestSetDefaultTrim(t *testing.T) {
+	if p := DefaultTrim(); p != TrimBoth {
+		t.Fatalf("expect both before SetDefaultTrim, got %d", p)
+	}
+	defer SetDefaultTrim(TrimBoth)
+	if err := SetDefaultTrim(TrimRight); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Marshal(trimStruct{Mti: "0200", Pan: "4111 ", Terminal: "T1", Sub: trimSub{Id: " A"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := trimStruct{}
+	if err := Unmarshal(b, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Terminal != "T1" || after.Sub.Id != " A" || after.Pan != "4111 " {
+		t.Errorf("default right trims alpha fields and sub fields but not llvar, got %+v", after)
+	}
+	if p := DefaultTrim(); p != TrimRight {
+		t.Errorf("expect right got %d", p)
+	}
+	if err := SetDefaultTrim(TrimPolicy(9)); err == nil {
+		t.Error("unknown policy should fail")
+	}
+}
+
+func TestTrimTagErrors(t *testing.T) {
+	type unknown struct {
+		Mti  string
+		Name string `field:"43" type:"alpha" length:"8" trim:"all"`
+	}
+	if err := Validate[unknown](); err == nil || !strings.Contains(err.Error(), "Unsupport trim all") {
+		t.Errorf("unknown trim got %v", err)
+	}
+	type bin struct {
+		Mti string
+		Mac []byte `field:"64" type:"binary" length:"8" trim:"right"`
+	}
+	if err := Validate[bin](); err == nil || !strings.Contains(err.Error(), "trim is not applied to a binary field") {
+		t.Errorf("binary trim got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type validateSubField struct {
+	Terminal string `field:"1" length:"8"`
+	Merchant string `field:"2" length:"15"`
+}
+
+type validateGoodStruct struct {
+	Mti    string
+	Pan    string           `field:"2" type:"llvar"`
+	Amount int64            `field:"4" length:"12" type:"numeric" encode:"bcd"`
+	Name   string           `field:"43" length:"40" type:"alpha" cp:"ibm037"`
+	Sub    validateSubField `field:"48" type:"lllvar" bitmapsize:"1"`
+	Mac    []byte           `field:"64" length:"8" type:"binary"`
+}
+
+type validateBadSubField struct {
+	Terminal string `field:"1" length:"8"`
+	Merchant string `field:"1" length:"15"`
+	Extra    string `field:"9" length:"2"`
+}
+
+type validateBadStruct struct {
+	Mti      string
+	Pan      string              `field:"2" type:"llvr"`
+	Amount   int64               `field:"4" type:"numeric"`
+	Currency string              `field:"4" length:"3" type:"numeric"`
+	Track    string              `field:"35" type:"llvar" encode:"bcd,bcd"`
+	Name     string              `field:"43" length:"40" type:"alpha" cp:"no-such-cp"`
+	Sub      validateBadSubField `field:"48" type:"lllvar" bitmapsize:"1"`
+	Mac      string              `field:"64" length:"8" type:"binary"`
+}
+
+type validateNoMtiStruct struct {
+	Pan string `field:"2" type:"llvar"`
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate[validateGoodStruct](); err != nil {
+		t.Errorf("valid struct got %s", err.Error())
+	}
+	err := Validate[validateBadStruct]()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expect ValidationError got %v", err)
+	}
+	expect := []string{
+		"field:Pan type \"llvr\" is not a built in or registered type",
+		"field:Amount numeric field requires a length",
+		"field:Currency shares field number 4 with field:Amount",
+		"field:Track llvar value encode must be ascii, got bcd",
+		"field:Name Unsupport codepage no-such-cp",
+		"field:Sub subfield:Merchant shares field number 1 with subfield:Terminal",
+		"field:Sub subfield:Extra field number 9 does not fit bitmapsize 1",
+		"field:Mac binary field must be []byte, got string",
+	}
+	if len(verr.Errors) != len(expect) {
+		t.Errorf("expect %d errors got %d: %s", len(expect), len(verr.Errors), err.Error())
+	}
+	for _, e := range expect {
+		if !strings.Contains(err.Error(), e) {
+			t.Errorf("expect %q in %s", e, err.Error())
+		}
+	}
+	if err := Validate[validateNoMtiStruct](); err == nil || !strings.Contains(err.Error(), "field:mti field is required") {
+		t.Errorf("struct without mti got %v", err)
+	}
+	if err := Validate[string](); err == nil {
+		t.Error("not struct type should fail")
+	}
+}
+
+func TestMustRegister(t *testing.T) {
+	MustRegister[validateGoodStruct]()
+	defer func() {
+		if recover() == nil {
+			t.Error("MustRegister of invalid struct should panic")
+		}
+	}()
+	MustRegister[validateBadStruct]()
+}
+
+func TestValidateTagsOption(t *testing.T) {
+	bad := validateBadStruct{Mti: "0200", Currency: "764"}
+	b, err := Marshal(bad)
+	if err != nil {
+		t.Fatalf("without ValidateTags invalid fields are skipped, got %s", err.Error())
+	}
+	var verr *ValidationError
+	if _, err := Marshal(bad, ValidateTags()); !errors.As(err, &verr) {
+		t.Errorf("Marshal expect ValidationError got %v", err)
+	}
+	if err := Unmarshal(b, &validateBadStruct{}, ValidateTags()); !errors.As(err, &verr) {
+		t.Errorf("Unmarshal expect ValidationError got %v", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, ValidateTags()).Encode(bad); !errors.As(err, &verr) {
+		t.Errorf("Encoder expect ValidationError got %v", err)
+	}
+
+	good := validateGoodStruct{
+		Mti:    "0200",
+		Pan:    "4111111111111111",
+		Amount: 100,
+		Name:   "SHOP",
+		Sub:    validateSubField{Terminal: "T1", Merchant: "M1"},
+		Mac:    []byte{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	b, err = Marshal(good, ValidateTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := validateGoodStruct{}
+	if err := Unmarshal(b, &after, ValidateTags()); err != nil {
+		t.Fatal(err)
+	}
+	if after.Pan != good.Pan || after.Amount != good.Amount || !bytes.Equal(after.Mac, good.Mac) {
+		t.Errorf("expect %+v got %+v", good, after)
+	}
+}
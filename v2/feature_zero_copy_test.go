@@ -0,0 +1,91 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"testing"
+)
+
+type zeroCopySubField struct {
+	Raw []byte `field:"1" length:"4"`
+}
+
+type zeroCopyTestStruct struct {
+	Mti    string
+	Ll     []byte           `field:"2" type:"llvar"`
+	Lll    []byte           `field:"3" type:"lllvar"`
+	Binary []byte           `field:"4" length:"4" type:"binary"`
+	Sub    zeroCopySubField `field:"5" type:"llvar"`
+}
+
+func zeroCopyTestData(t *testing.T) []byte {
+	b, err := Marshal(zeroCopyTestStruct{
+		Mti:    "0200",
+		Ll:     []byte("ll"),
+		Lll:    []byte("lll"),
+		Binary: []byte{1, 2, 3, 4},
+		Sub:    zeroCopySubField{Raw: []byte("abcd")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func zeroCopyFields(v zeroCopyTestStruct) [][]byte {
+	return [][]byte{v.Ll, v.Lll, v.Binary, v.Sub.Raw}
+}
+
+func TestUnmarshalCopiesBytes(t *testing.T) {
+	data := zeroCopyTestData(t)
+	after := zeroCopyTestStruct{}
+	if err := Unmarshal(data, &after); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{[]byte("ll"), []byte("lll"), {1, 2, 3, 4}, []byte("abcd")}
+	for i := range data {
+		data[i] = 0xff
+	}
+	for i, b := range zeroCopyFields(after) {
+		if !bytes.Equal(b, want[i]) {
+			t.Errorf("field %d should not alias the input %x", i, b)
+		}
+	}
+}
+
+func TestUnmarshalZeroCopy(t *testing.T) {
+	data := zeroCopyTestData(t)
+	after := zeroCopyTestStruct{}
+	if err := Unmarshal(data, &after, ZeroCopy()); err != nil {
+		t.Fatal(err)
+	}
+	for i := range data {
+		data[i] = 0xff
+	}
+	for i, b := range zeroCopyFields(after) {
+		if len(b) == 0 || !bytes.Equal(b, bytes.Repeat([]byte{0xff}, len(b))) {
+			t.Errorf("field %d should alias the input %x", i, b)
+		}
+	}
+}
+
+func TestDecoderReusesBufferSafely(t *testing.T) {
+	first := zeroCopyTestData(t)
+	second, _ := Marshal(zeroCopyTestStruct{Mti: "0200", Ll: []byte("xy")})
+	var stream []byte
+	stream = append(stream, byte(len(first)>>8), byte(len(first)))
+	stream = append(stream, first...)
+	stream = append(stream, byte(len(second)>>8), byte(len(second)))
+	stream = append(stream, second...)
+
+	dec := NewDecoder(bytes.NewReader(stream), FramingBinary2)
+	var a, b zeroCopyTestStruct
+	if err := dec.Decode(&a); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&b); err != nil {
+		t.Fatal(err)
+	}
+	if string(a.Ll) != "ll" || string(b.Ll) != "xy" {
+		t.Errorf("reused buffer corrupted decoded fields %q %q", a.Ll, b.Ll)
+	}
+}
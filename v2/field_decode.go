@@ -1,32 +1,27 @@
 package iso8583v2
 
 import (
+	"encoding/hex"
 	"fmt"
 	"reflect"
+	"strconv"
 )
 
 type fieldDecoder struct {
 	getBitmap func() []byte
 	v         reflect.Value
 	tg        iso8583Tag
+	opt       options
 }
 
 func (f *fieldDecoder) decode(data []byte) ([]byte, error) {
-	bitmap := f.getBitmap()
-	if bitmap == nil {
-		return nil, fmt.Errorf("decode failed field:%s bitmap data not found", f.tg.name)
-	}
-	maxField := len(bitmap) * 8
-	if f.tg.field > maxField || len(data) == 0 {
-		return data, nil
+	on, err := f.isOn(data)
+	if err != nil {
+		return nil, err
 	}
-	byteIndex := (f.tg.field - 1) / 8
-	bitIndex := (f.tg.field - 1) % 8
-	if (bitmap[byteIndex] & (0x80 >> uint(bitIndex))) != (0x80 >> uint(bitIndex)) {
-		//offbit
+	if !on {
 		return data, nil
 	}
-	//onbit
 	switch f.tg.fieldType {
 	case numeric:
 		return f.numericDecode(data)
@@ -39,10 +34,53 @@ func (f *fieldDecoder) decode(data []byte) ([]byte, error) {
 	case lllvar:
 		return f.lllvarDecode(data)
 	default:
+		if c, ok := lookupFieldCodec(f.tg.fieldType); ok {
+			return f.customDecode(c, data)
+		}
 		return nil, fmt.Errorf("decode failed field:%s unknown field type %s", f.tg.name, f.tg.fieldType.value())
 	}
 }
 
+//isOn reports whether the bitmap has the field and data is left for it
+func (f *fieldDecoder) isOn(data []byte) (bool, error) {
+	bitmap := f.getBitmap()
+	if bitmap == nil {
+		return false, fmt.Errorf("decode failed field:%s bitmap data not found", f.tg.name)
+	}
+	maxField := len(bitmap) * 8
+	if f.tg.field > maxField || len(data) == 0 {
+		return false, nil
+	}
+	byteIndex := (f.tg.field - 1) / 8
+	bitIndex := (f.tg.field - 1) % 8
+	return (bitmap[byteIndex] & (0x80 >> uint(bitIndex))) == (0x80 >> uint(bitIndex)), nil
+}
+
+//read returns the value at the front of data without loading it into the field
+func (f *fieldDecoder) read(data []byte) ([]byte, []byte, error) {
+	switch f.tg.fieldType {
+	case numeric, alpha, binary:
+		val, leftByte, err := f.getValueEncoderFn()(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s decode %s", f.tg.fieldType.value(), err.Error())
+		}
+		return val, leftByte, nil
+	case llvar:
+		return f.readLlvar(data)
+	case lllvar:
+		return f.readLllvar(data)
+	default:
+		if c, ok := lookupFieldCodec(f.tg.fieldType); ok {
+			val, leftByte, err := c.codec.Decode(f.tg.export(), data)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s decode %s", c.name, err.Error())
+			}
+			return val, leftByte, nil
+		}
+		return nil, nil, fmt.Errorf("decode failed field:%s unknown field type %s", f.tg.name, f.tg.fieldType.value())
+	}
+}
+
 func (f *fieldDecoder) getValueEncoderFn() func(data []byte) ([]byte, []byte, error) {
 	switch f.tg.valEncode {
 	case bcd:
@@ -109,24 +147,78 @@ type mtiDecoder struct {
 }
 
 func (m *mtiDecoder) decode(data []byte) ([]byte, error) {
-	if m.v.Type().Kind() != reflect.String {
-		return nil, fmt.Errorf("mti should be string %v", m.v.Type().Kind())
+	mti, leftByte, err := decodeMti(data, m.tg)
+	if err != nil {
+		return nil, err
+	}
+	if err := setMti(m.v, mti, m.tg); err != nil {
+		return nil, err
+	}
+	return leftByte, nil
+}
+
+//setMti stores mti into a string, an integer or a type implementing FieldUnmarshaler or encoding.TextUnmarshaler
+func setMti(v reflect.Value, mti string, t iso8583Tag) error {
+	if u := unmarshalerValue(v); u != nil {
+		return unmarshalValue(u, t.export(), []byte(mti))
 	}
-	switch m.tg.valEncode {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(mti)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(mti, 10, 64)
+		if err != nil || v.OverflowInt(n) {
+			return fmt.Errorf("mti %s does not fit %v", mti, v.Type())
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(mti, 10, 64)
+		if err != nil || v.OverflowUint(n) {
+			return fmt.Errorf("mti %s does not fit %v", mti, v.Type())
+		}
+		v.SetUint(n)
+		return nil
+	}
+	return fmt.Errorf("mti should be string, integer or encoding.TextUnmarshaler %v", v.Type().Kind())
+}
+
+func decodeMti(data []byte, t iso8583Tag) (string, []byte, error) {
+	switch t.valEncode {
 	case ascii:
 		if len(data) < 4 {
-			return nil, fmt.Errorf("decode ascii failed: mti data length too small (%d)", len(data))
+			return "", nil, fmt.Errorf("decode ascii failed: mti data length too small (%d)", len(data))
 		}
-		m.v.SetString(string(data[:4]))
-		return data[4:], nil
+		return string(data[:4]), data[4:], nil
 	case bcd:
 		if len(data) < 2 {
-			return nil, fmt.Errorf("decode bcd failed: mti data length too small (%d)", len(data))
+			return "", nil, fmt.Errorf("decode bcd failed: mti data length too small (%d)", len(data))
+		}
+		return string(bcd2Ascii(data[:2])), data[2:], nil
+	case ebcdic:
+		if len(data) < 4 {
+			return "", nil, fmt.Errorf("decode ebcdic failed: mti data length too small (%d)", len(data))
+		}
+		mti := make([]byte, 4)
+		for i, b := range data[:4] {
+			if b < 0xf0 || b > 0xf9 {
+				return "", nil, fmt.Errorf("decode ebcdic failed: mti byte %02x is not a digit", b)
+			}
+			mti[i] = '0' + b&0x0f
+		}
+		return string(mti), data[4:], nil
+	case hexEncode:
+		if len(data) < 8 {
+			return "", nil, fmt.Errorf("decode hex failed: mti data length too small (%d)", len(data))
+		}
+		mti, err := hex.DecodeString(string(data[:8]))
+		if err != nil || !isDigits(string(mti)) {
+			return "", nil, fmt.Errorf("decode hex failed: mti %s is not hex of 4 digits", string(data[:8]))
 		}
-		m.v.SetString(string(bcd2Ascii(data[:2])))
-		return data[2:], nil
+		return string(mti), data[8:], nil
 	}
-	return nil, fmt.Errorf("decode failed: mti field encode value not supported, %s", m.tg.valEncode.value())
+	return "", nil, fmt.Errorf("decode failed: mti field encode value not supported, %s", t.valEncode.value())
 }
 
 type decoder struct {
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
-	"sync/atomic"
 )
 
 func (f *fieldDecoder) numericDecode(data []byte) ([]byte, error) {
@@ -46,12 +45,21 @@ func (f *fieldDecoder) binaryDecode(data []byte) (leftByte []byte, err error) {
 		err = fmt.Errorf("binary decode %s", err.Error())
 		return
 	}
-	f.v.SetBytes(val)
+	f.v.SetBytes(f.opt.bytesValue(val))
 	return
 }
 
 func (f *fieldDecoder) llvarDecode(data []byte) (leftByte []byte, err error) {
+	var val []byte
+	val, leftByte, err = f.readLlvar(data)
+	if err != nil {
+		return
+	}
+	err = f.loadValue(val)
+	return
+}
 
+func (f *fieldDecoder) readLlvar(data []byte) (val []byte, leftByte []byte, err error) {
 	var contentLen int
 	switch f.tg.lenEncode {
 	case ascii:
@@ -82,13 +90,26 @@ func (f *fieldDecoder) llvarDecode(data []byte) (leftByte []byte, err error) {
 		err = fmt.Errorf("llvar, length encoder is invalid")
 		return
 	}
-	val := data[:contentLen]
+	if contentLen > len(data) {
+		err = fmt.Errorf("llvar content length(%d) is larger than data(%d)", contentLen, len(data))
+		return
+	}
+	val = data[:contentLen]
 	leftByte = data[contentLen:]
-	err = f.loadValue(val)
 	return
 }
 
 func (f *fieldDecoder) lllvarDecode(data []byte) (leftByte []byte, err error) {
+	var val []byte
+	val, leftByte, err = f.readLllvar(data)
+	if err != nil {
+		return
+	}
+	err = f.loadValue(val)
+	return
+}
+
+func (f *fieldDecoder) readLllvar(data []byte) (val []byte, leftByte []byte, err error) {
 	var contentLen int
 	switch f.tg.lenEncode {
 	case ascii:
@@ -119,12 +140,27 @@ func (f *fieldDecoder) lllvarDecode(data []byte) (leftByte []byte, err error) {
 		err = fmt.Errorf("lllvar, length encoder is invalid")
 		return
 	}
-	val := data[:contentLen]
+	if contentLen > len(data) {
+		err = fmt.Errorf("lllvar content length(%d) is larger than data(%d)", contentLen, len(data))
+		return
+	}
+	val = data[:contentLen]
 	leftByte = data[contentLen:]
-	err = f.loadValue(val)
 	return
 }
 
+func (f *fieldDecoder) customDecode(c customFieldType, data []byte) ([]byte, error) {
+	val, leftByte, err := c.codec.Decode(f.tg.export(), data)
+	if err != nil {
+		return nil, fmt.Errorf("%s decode %s", c.name, err.Error())
+	}
+	err = f.loadValue(val)
+	if err != nil {
+		return nil, fmt.Errorf("%s decode %s", c.name, err.Error())
+	}
+	return leftByte, nil
+}
+
 //////////////////////////////////////////////////////////////
 func (f *fieldDecoder) loadPointer(val []byte) (err error) {
 
@@ -138,21 +174,52 @@ func (f *fieldDecoder) loadPointer(val []byte) (err error) {
 	return f.loadValue(val)
 }
 
+func (f *fieldDecoder) loadUnmarshaler(u interface{}, val []byte) error {
+	val, err := f.decodeCodepage(val)
+	if err != nil {
+		return err
+	}
+	if val, err = f.valueText(val); err != nil {
+		return err
+	}
+	if err := unmarshalValue(u, f.tg.export(), val); err != nil {
+		return fmt.Errorf("field:%s unmarshaler %s", f.tg.name, err.Error())
+	}
+	return nil
+}
+
+//valueText trims val, strips the padding of a pad tag and checks its class in strict mode,
+//val is the text after the code page is decoded
+func (f *fieldDecoder) valueText(val []byte) ([]byte, error) {
+	val = f.tg.stripPad(f.tg.trimValue(val), isPadNumberKind(f.v.Kind()))
+	if f.opt.strict {
+		if err := checkClass(f.tg, val, true); err != nil {
+			return nil, err
+		}
+	}
+	return val, nil
+}
+
+func (f *fieldDecoder) decodeCodepage(val []byte) ([]byte, error) {
+	cp := f.tg.codePage.value()
+	if cp == "" {
+		return val, nil
+	}
+	val, err := decodeUTF8(cp, f.tg.cpPolicy.under(f.opt.strict), val)
+	if err != nil {
+		return nil, fmt.Errorf("field:%s %s", f.tg.name, err.Error())
+	}
+	return val, nil
+}
+
 func (f *fieldDecoder) loadStruct(val []byte) (err error) {
-	structKey := f.v.Type().PkgPath() + f.v.Type().Name()
-	fixedTagLock.RLock()
-	mpTag := fixedTagCache[structKey]
-	fixedTagLock.RUnlock()
-	tag, ok := mpTag.Load().(map[string]*fixedwidthTag)
-	if !ok {
-		var tagValue atomic.Value
-		tag = loadFixedwidthTag(f.v)
-		tagValue.Store(tag)
-		fixedTagLock.Lock()
-		fixedTagCache[structKey] = tagValue
-		fixedTagLock.Unlock()
+	if f.tg.fieldType == tlvFieldType {
+		if err = decodeTLVStruct(f.v, val); err != nil {
+			err = fmt.Errorf("field:%s %s", f.tg.name, err.Error())
+		}
+		return
 	}
-	err = f.loadStructSubFieldWithTag(val, tag)
+	err = f.loadStructSubFieldWithTag(val, cachedFixedwidthTag(f.v.Type()))
 	return
 }
 
@@ -187,7 +254,7 @@ func (f *fieldDecoder) loadStructSubFieldWithTag(val []byte, tag map[string]*fix
 		if idx+fixedTag.length > len(val) {
 			return fmt.Errorf("field:%s data is not enough accumulate length(%d) data(%d)", fixedTag.name, idx+fixedTag.length, len(val))
 		}
-		errDecode := getFixedwidthDecoder(f.v.Field(i), *fixedTag)(val[idx : idx+fixedTag.length])
+		errDecode := getFixedwidthDecoder(f.v.Field(i), *fixedTag, f.opt)(val[idx : idx+fixedTag.length])
 		if errDecode != nil {
 			return fmt.Errorf("decode failed field:%s subfield:%s %s", f.tg.name, fixedTag.name, errDecode.Error())
 		}
@@ -218,6 +285,10 @@ func (f *fieldDecoder) loadValue(val []byte) (err error) {
 			err = fmt.Errorf("field:%s load value failed cannot set value %v", f.tg.name, r)
 		}
 	}()
+	if u := unmarshalerValue(f.v); u != nil {
+		err = f.loadUnmarshaler(u, val)
+		return
+	}
 	switch f.v.Type().Kind() {
 	case reflect.Ptr:
 		err = f.loadPointer(val)
@@ -226,15 +297,30 @@ func (f *fieldDecoder) loadValue(val []byte) (err error) {
 		err = f.loadStruct(val)
 		return
 	case reflect.Slice:
-		f.v.SetBytes(val)
+		if val, err = f.valueText(val); err != nil {
+			return
+		}
+		f.v.SetBytes(f.opt.bytesValue(val))
 		return
 	case reflect.String:
-		if cp := f.tg.codePage.value(); cp != "" {
-			val = decodeUTF8(cp, val)
+		val, err = f.decodeCodepage(val)
+		if err != nil {
+			return
+		}
+		if val, err = f.valueText(val); err != nil {
+			return
 		}
 		f.v.SetString(string(val))
 		return
 	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		if val, err = f.valueText(val); err != nil {
+			return
+		}
+		if len(val) == 0 && f.tg.pad.isSet() {
+			//a value of only padding
+			f.v.SetInt(0)
+			return
+		}
 		var i int
 		i, err = strconv.Atoi(string(val))
 		if err != nil {
@@ -243,6 +329,13 @@ func (f *fieldDecoder) loadValue(val []byte) (err error) {
 		f.v.SetInt(int64(i))
 		return
 	case reflect.Float64:
+		if val, err = f.valueText(val); err != nil {
+			return
+		}
+		if len(val) == 0 && f.tg.pad.isSet() {
+			f.v.SetFloat(0)
+			return
+		}
 		var fval float64
 		fval, err = strconv.ParseFloat(string(val), 64)
 		if err != nil {
@@ -251,6 +344,13 @@ func (f *fieldDecoder) loadValue(val []byte) (err error) {
 		f.v.SetFloat(fval)
 		return
 	case reflect.Float32:
+		if val, err = f.valueText(val); err != nil {
+			return
+		}
+		if len(val) == 0 && f.tg.pad.isSet() {
+			f.v.SetFloat(0)
+			return
+		}
 		var fval float64
 		fval, err = strconv.ParseFloat(string(val), 32)
 		if err != nil {
@@ -5,25 +5,62 @@ import (
 	"reflect"
 	"sort"
 	"strconv"
-	"sync/atomic"
 )
 
 type fieldEncoder struct {
 	typ reflect.Type
 	tg  iso8583Tag
+	sub []compiledSubField
+	//strict checks the class of values and sub field values
+	strict bool
+	//marshaler is set when typ implements FieldMarshaler or encoding.TextMarshaler
+	marshaler bool
 }
 
-type fieldEncoderFunc func(v reflect.Value) ([]byte, error)
+//fieldEncoderFunc appends the encoded value of v to dst, a field that appends nothing is left out of the message
+type fieldEncoderFunc func(dst []byte, v reflect.Value) ([]byte, error)
 
-func (f fieldEncoder) nilFunc(v reflect.Value) ([]byte, error) {
-	return nil, nil
+func newFieldEncoder(typ reflect.Type, tg iso8583Tag, strict bool) fieldEncoder {
+	return fieldEncoder{
+		typ:       typ,
+		tg:        tg,
+		strict:    strict,
+		marshaler: typ != nil && (implements(typ, fieldMarshalerType) || implements(typ, textMarshalerType)),
+	}
 }
 
-func (f fieldEncoder) unknownFunc(v reflect.Value) ([]byte, error) {
+//encode appends the encoded value of v to dst by the type of the field
+func (f fieldEncoder) encode(dst []byte, v reflect.Value) ([]byte, error) {
+	if f.typ == nil {
+		return dst, nil
+	}
+	if f.marshaler {
+		return f.marshalerEncodeFunc(dst, v)
+	}
+	switch f.typ.Kind() {
+	case reflect.Slice:
+		return f.sliceByteEncodeFunc(dst, v)
+	case reflect.Ptr:
+		return f.ptrEncodeFunc(dst, v)
+	case reflect.Struct:
+		return f.structEncodeFunc(dst, v)
+	case reflect.String:
+		return f.stringEncodeFunc(dst, v)
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		return f.intEncodeFunc(dst, v)
+	case reflect.Float32:
+		return f.floatEncodeFunc(dst, v, 32)
+	case reflect.Float64:
+		return f.floatEncodeFunc(dst, v, 64)
+	}
+	return f.unknownFunc(dst, v)
+}
+
+func (f fieldEncoder) unknownFunc(dst []byte, v reflect.Value) ([]byte, error) {
 	return nil, fmt.Errorf("field type %s is not support for this library value(%#v)", f.typ.Kind(), v)
 }
 
-func (f fieldEncoder) sliceByteEncodeFunc(v reflect.Value) (bret []byte, err error) {
+func (f fieldEncoder) sliceByteEncodeFunc(dst []byte, v reflect.Value) (bret []byte, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("encode failed field:%s slice supported only byte array", f.tg.name)
@@ -31,17 +68,25 @@ func (f fieldEncoder) sliceByteEncodeFunc(v reflect.Value) (bret []byte, err err
 	}()
 	byts := v.Bytes()
 	if len(byts) <= 0 {
-		bret = []byte{}
-		err = nil
-		return
+		return dst, nil
+	}
+	return f.appendValue(append(dst, byts...), len(dst))
+}
+
+func (f fieldEncoder) marshalerEncodeFunc(dst []byte, v reflect.Value) ([]byte, error) {
+	b, ok, err := marshalValue(v, f.tg.export())
+	if err != nil {
+		return nil, fmt.Errorf("encode failed field:%s marshaler %s", f.tg.name, err.Error())
 	}
-	bret, err = f.parseValue(byts)
-	return
+	if !ok || len(b) <= 0 {
+		return dst, nil
+	}
+	return f.appendValue(append(dst, b...), len(dst))
 }
 
-func (f fieldEncoder) ptrEncodeFunc(v reflect.Value) ([]byte, error) {
+func (f fieldEncoder) ptrEncodeFunc(dst []byte, v reflect.Value) ([]byte, error) {
 	if v.IsNil() {
-		return nil, nil
+		return dst, nil
 	}
 	for v.Kind() == reflect.Ptr {
 		v = v.Elem()
@@ -49,64 +94,128 @@ func (f fieldEncoder) ptrEncodeFunc(v reflect.Value) ([]byte, error) {
 
 	switch v.Kind() {
 	case reflect.Slice:
-		return f.sliceByteEncodeFunc(v)
+		return f.sliceByteEncodeFunc(dst, v)
 	case reflect.Struct:
-		return f.structEncodeFunc(v)
+		return f.structEncodeFunc(dst, v)
 	case reflect.String:
-		return f.stringEncodeFunc(v)
+		return f.stringEncodeFunc(dst, v)
 	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
-		return f.intEncodeFunc(v)
+		return f.intEncodeFunc(dst, v)
 	case reflect.Float32:
-		return f.getFloatEncoder(0, 32)(v)
+		return f.floatEncodeFunc(dst, v, 32)
 	case reflect.Float64:
-		return f.getFloatEncoder(0, 64)(v)
+		return f.floatEncodeFunc(dst, v, 64)
 	default:
 		return nil, fmt.Errorf("pointer field:%s type is not supported %v", f.tg.name, v.Kind())
 	}
 }
 
-func (f fieldEncoder) structEncodeFunc(v reflect.Value) ([]byte, error) {
-	structKey := v.Type().PkgPath() + v.Type().Name()
-	fixedTagLock.RLock()
-	mpTag := fixedTagCache[structKey]
-	fixedTagLock.RUnlock()
-	tag, ok := mpTag.Load().(map[string]*fixedwidthTag)
-	if !ok {
-		var tagValue atomic.Value
-		tag = loadFixedwidthTag(v)
-		tagValue.Store(tag)
-		fixedTagLock.Lock()
-		fixedTagCache[structKey] = tagValue
-		fixedTagLock.Unlock()
+func (f fieldEncoder) structEncodeFunc(dst []byte, v reflect.Value) ([]byte, error) {
+	start := len(dst)
+	if f.tg.fieldType == tlvFieldType {
+		structByte, err := encodeTLVStruct(v)
+		if err != nil {
+			return nil, fmt.Errorf("tlv field:%s %s", f.tg.name, err.Error())
+		}
+		if len(structByte) == 0 {
+			return dst, nil
+		}
+		return f.appendStructValue(append(dst, structByte...), start)
+	}
+	var err error
+	if f.sub != nil {
+		dst, err = f.appendCompiledFixedwidth(dst, v)
+	} else {
+		dst, err = f.appendFixedwidthWithTag(dst, v, cachedFixedwidthTag(v.Type()))
 	}
-
-	structByte, err := f.encodeFixedwidthWithTag(v, tag)
 	if err != nil {
 		return nil, err
 	}
-	if f.tg.fieldType != llvar && f.tg.fieldType != lllvar {
-		return nil, fmt.Errorf("struct field:%s encoding will support only llvar or lllvar type %s", f.tg.name, f.tg.fieldType.value())
+	return f.appendStructValue(dst, start)
+}
+
+//appendStructValue encodes the sub fields at dst[start:] as the value of the field
+func (f fieldEncoder) appendStructValue(dst []byte, start int) ([]byte, error) {
+	if _, custom := lookupFieldCodec(f.tg.fieldType); !custom && f.tg.fieldType != llvar && f.tg.fieldType != lllvar {
+		return nil, fmt.Errorf("struct field:%s encoding will support only llvar, lllvar or registered type %s", f.tg.name, f.tg.fieldType.value())
 	}
-	return f.parseValue(structByte)
+	//the sub fields are checked on their own
+	f.strict = false
+	return f.appendValue(dst, start)
 }
 
-func (f fieldEncoder) encodeFixedwidthWithTag(v reflect.Value, tag map[string]*fixedwidthTag) ([]byte, error) {
-	dataMap := make(map[int]([]byte))
+func (f fieldEncoder) parseStructValue(structByte []byte) ([]byte, error) {
+	return f.appendStructValue(append([]byte(nil), structByte...), 0)
+}
+
+//appendFixedwidthWithTag appends the sub fields of v in field number order, a sub field
+//that shares its number with an earlier one replaces it
+func (f fieldEncoder) appendFixedwidthWithTag(dst []byte, v reflect.Value, tag map[string]*fixedwidthTag) ([]byte, error) {
+	order := make([]int, 0, len(tag))
 	for i := 0; i < v.Type().NumField(); i++ {
+		if tag[v.Type().Field(i).Name] != nil {
+			order = append(order, i)
+		}
+	}
+	number := func(i int) int {
+		return tag[v.Type().Field(order[i]).Name].field
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return number(i) < number(j)
+	})
+	start := len(dst)
+	dst = appendZeros(dst, f.tg.bitmapSize)
+	for k, i := range order {
+		if k+1 < len(order) && number(k+1) == number(k) {
+			continue
+		}
 		subField := v.Type().Field(i)
 		fixedTag := tag[subField.Name]
-		if fixedTag == nil {
-			continue
+		if fixedTag.field < 1 {
+			return nil, fmt.Errorf("field:%s has sub field below 1", f.tg.name)
 		}
-		b, err := getFixedwidthEncoder(subField.Type, *fixedTag, f.tg.bitmapSize > 0)(v.Field(i))
+		before := len(dst)
+		var err error
+		dst, err = newFixedwidthEncoder(subField.Type, *fixedTag, f.tg.bitmapSize > 0, f.strict).encode(dst, v.Field(i))
 		if err != nil {
 			return nil, err
 		}
-		if b != nil {
-			dataMap[fixedTag.field] = b
+		if err := f.setSubfieldBit(dst, start, fixedTag.field, len(dst) > before); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+//appendCompiledFixedwidth appends the sub fields of v with the compiled plan
+func (f fieldEncoder) appendCompiledFixedwidth(dst []byte, v reflect.Value) ([]byte, error) {
+	start := len(dst)
+	dst = appendZeros(dst, f.tg.bitmapSize)
+	for _, sf := range f.sub {
+		before := len(dst)
+		var err error
+		dst, err = sf.enc(dst, v.Field(sf.index))
+		if err != nil {
+			return nil, err
+		}
+		if err := f.setSubfieldBit(dst, start, sf.tg.field, len(dst) > before); err != nil {
+			return nil, err
 		}
 	}
-	return f.encodeFixedwidthStructValue(dataMap)
+	return dst, nil
+}
+
+//setSubfieldBit turns on the bit of a present sub field in the bitmap at dst[start:]
+func (f fieldEncoder) setSubfieldBit(dst []byte, start int, field int, present bool) error {
+	if f.tg.bitmapSize <= 0 || !present {
+		return nil
+	}
+	slot := (field - 1) / 8
+	if slot >= f.tg.bitmapSize {
+		return fmt.Errorf("field:%s is using bitmap(%d) but field count is moreover %d", f.tg.name, f.tg.bitmapSize, field)
+	}
+	dst[start+slot] |= 0x80 >> uint((field-1)%8)
+	return nil
 }
 
 func (f fieldEncoder) encodeFixedwidthStructValue(dataMap map[int]([]byte)) ([]byte, error) {
@@ -147,70 +256,58 @@ func (f fieldEncoder) encodeFixedwidthStructValue(dataMap map[int]([]byte)) ([]b
 	return data, nil
 }
 
-func (f fieldEncoder) stringEncodeFunc(v reflect.Value) ([]byte, error) {
+func (f fieldEncoder) stringEncodeFunc(dst []byte, v reflect.Value) ([]byte, error) {
 	if v.String() == "" {
-		return []byte{}, nil
+		return dst, nil
 	}
-	return f.parseValue([]byte(v.String()))
+	return f.appendValue(append(dst, v.String()...), len(dst))
 }
 
-func (f fieldEncoder) intEncodeFunc(v reflect.Value) ([]byte, error) {
+func (f fieldEncoder) intEncodeFunc(dst []byte, v reflect.Value) ([]byte, error) {
 	if v.Int() == 0 {
-		return []byte{}, nil
+		return dst, nil
 	}
-	return f.parseValue([]byte(strconv.Itoa(int(v.Int()))))
+	return f.appendValue(strconv.AppendInt(dst, v.Int(), 10), len(dst))
 }
 
-func (f fieldEncoder) getFloatEncoder(perc, bitsize int) fieldEncoderFunc {
-	return func(v reflect.Value) ([]byte, error) {
-		if v.Float() == 0 {
-			return []byte{}, nil
-		}
-		return f.parseValue([]byte(strconv.FormatFloat(v.Float(), 'f', perc, bitsize)))
+func (f fieldEncoder) floatEncodeFunc(dst []byte, v reflect.Value, bitsize int) ([]byte, error) {
+	if v.Float() == 0 {
+		return dst, nil
 	}
+	return f.appendValue(strconv.AppendFloat(dst, v.Float(), 'f', 0, bitsize), len(dst))
 }
 
+//parseValue encodes b on its own, b is left unchanged
 func (f fieldEncoder) parseValue(b []byte) ([]byte, error) {
+	return f.appendValue(append(make([]byte, 0, len(b)+8), b...), 0)
+}
+
+//appendValue encodes the text at dst[start:] in place as the value of the field
+func (f fieldEncoder) appendValue(dst []byte, start int) ([]byte, error) {
+	if f.strict {
+		if err := checkClass(f.tg, dst[start:], false); err != nil {
+			return nil, err
+		}
+	}
 	switch f.tg.fieldType {
 	case numeric:
-		return f.numericParse(b)
+		return f.numericParse(dst, start)
 	case alpha:
-		return f.alphaParse(b)
+		return f.alphaParse(dst, start)
 	case binary:
-		return f.binaryParse(b)
+		return f.binaryParse(dst, start)
 	case llvar:
-		return f.llvarParse(b)
+		return f.llvarParse(dst, start)
 	case lllvar:
-		return f.lllvarParse(b)
+		return f.lllvarParse(dst, start)
+	default:
+		if c, ok := lookupFieldCodec(f.tg.fieldType); ok {
+			return f.customParse(c, dst, start)
+		}
 	}
 	return nil, fmt.Errorf("Field:%s type is invalid(%s)", f.tg.name, f.tg.fieldType.value())
 }
 
-func getFieldEncoder(typ reflect.Type, tg iso8583Tag) fieldEncoderFunc {
-	fEnc := fieldEncoder{
-		typ: typ,
-		tg:  tg,
-	}
-	if typ == nil {
-		return fEnc.nilFunc
-	}
-
-	switch typ.Kind() {
-	case reflect.Slice:
-		return fEnc.sliceByteEncodeFunc
-	case reflect.Ptr:
-		return fEnc.ptrEncodeFunc
-	case reflect.Struct:
-		return fEnc.structEncodeFunc
-	case reflect.String:
-		return fEnc.stringEncodeFunc
-	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
-		return fEnc.intEncodeFunc
-	case reflect.Float32:
-		return fEnc.getFloatEncoder(0, 32)
-	case reflect.Float64:
-		return fEnc.getFloatEncoder(0, 64)
-	}
-
-	return fEnc.unknownFunc
+func getFieldEncoder(typ reflect.Type, tg iso8583Tag, strict bool) fieldEncoderFunc {
+	return newFieldEncoder(typ, tg, strict).encode
 }
@@ -2,77 +2,82 @@ package iso8583v2
 
 import (
 	"fmt"
-	"strings"
+	"strconv"
 )
 
-func (f fieldEncoder) numericParse(b []byte) ([]byte, error) {
+//the parsers encode the text at dst[start:] in place and return dst with the encoded value from start
+
+func (f fieldEncoder) numericParse(dst []byte, start int) ([]byte, error) {
 	if f.tg.length <= 0 {
 		return nil, fmt.Errorf("numeric field:%s length must be specified", f.tg.name)
 	}
 	if f.tg.valEncode == rbcd &&
-		len(b) == (f.tg.length+1) &&
-		string(b[0:1]) == "0" {
-		b = b[1:len(b)]
+		len(dst)-start == (f.tg.length+1) &&
+		dst[start] == '0' {
+		dst = append(dst[:start], dst[start+1:]...)
 	}
-	if len(b) > f.tg.length {
-		return nil, fmt.Errorf("numeric field:%s length (%d) is larger than defined length (%d)", f.tg.name, len(b), f.tg.length)
+	if len(dst)-start > f.tg.length {
+		return nil, fmt.Errorf("numeric field:%s length (%d) is larger than defined length (%d)", f.tg.name, len(dst)-start, f.tg.length)
 	}
-	if len(b) < f.tg.length {
-		b = append([]byte(strings.Repeat("0", f.tg.length-len(b))), b...)
+	pad := f.tg.pad.or(numericPad)
+	dst, err := pad.appendFill(dst, start, f.tg.length, defaultCp)
+	if err != nil {
+		return nil, fmt.Errorf("numeric field:%s padding %s", f.tg.name, err.Error())
 	}
 	switch f.tg.valEncode {
 	case bcd:
-		return lbcdEncode(b)
+		return appendBcd(dst, start, pad.char, false)
 	case rbcd:
-		return rbcdEncode(b)
+		return appendBcd(dst, start, pad.char, true)
 	case ascii:
-		return b, nil
+		return dst, nil
 	//default will be ascii
 	default:
-		return b, nil
+		return dst, nil
 	}
 }
 
-func (f fieldEncoder) alphaParse(b []byte) ([]byte, error) {
+func (f fieldEncoder) alphaParse(dst []byte, start int) ([]byte, error) {
 	if f.tg.length <= 0 {
 		return nil, fmt.Errorf("alpha field:%s length must be specified", f.tg.name)
 	}
-	if cp := f.tg.codePage.value(); cp != "" {
-		b = encodeUTF8(cp, b)
+	dst, err := f.encodeCodepage(dst, start)
+	if err != nil {
+		return nil, fmt.Errorf("alpha field:%s %s", f.tg.name, err.Error())
 	}
-	if len(b) > f.tg.length {
-		return nil, fmt.Errorf("alpha field:%s data %s length (%d) is larger than defined length (%d)", f.tg.name, string(b), len(b), f.tg.length)
+	if len(dst)-start > f.tg.length {
+		return nil, fmt.Errorf("alpha field:%s data %s length (%d) is larger than defined length (%d)", f.tg.name, string(dst[start:]), len(dst)-start, f.tg.length)
 	}
-	if len(b) < f.tg.length {
-		b = append(b, []byte(strings.Repeat(" ", f.tg.length-len(b)))...)
+	dst, err = f.tg.pad.or(alphaPad).appendFill(dst, start, f.tg.length, f.tg.codePage)
+	if err != nil {
+		return nil, fmt.Errorf("alpha field:%s padding %s", f.tg.name, err.Error())
 	}
-	return b, nil
+	return dst, nil
 }
 
-func (f fieldEncoder) binaryParse(b []byte) ([]byte, error) {
+func (f fieldEncoder) binaryParse(dst []byte, start int) ([]byte, error) {
 	if f.tg.length <= 0 {
 		return nil, fmt.Errorf("binary field:%s length must be specified", f.tg.name)
 	}
-	if len(b) > f.tg.length {
-		return nil, fmt.Errorf("binary field:%s length (%d) is larger than defined length (%d)", f.tg.name, len(b), f.tg.length)
-	}
-	if len(b) < f.tg.length {
-		return append(b, make([]byte, f.tg.length-len(b))...), nil
+	if len(dst)-start > f.tg.length {
+		return nil, fmt.Errorf("binary field:%s length (%d) is larger than defined length (%d)", f.tg.name, len(dst)-start, f.tg.length)
 	}
-	return b, nil
+	return appendZeros(dst, f.tg.length-(len(dst)-start)), nil
 }
 
-func (f fieldEncoder) llvarParse(b []byte) ([]byte, error) {
-	if cp := f.tg.codePage.value(); cp != "" {
-		b = encodeUTF8(cp, b)
+func (f fieldEncoder) llvarParse(dst []byte, start int) ([]byte, error) {
+	dst, err := f.encodeCodepage(dst, start)
+	if err != nil {
+		return nil, fmt.Errorf("llvar field:%s %s", f.tg.name, err.Error())
 	}
-	if f.tg.length != -1 && len(b) > f.tg.length {
-		return nil, fmt.Errorf("llvar field:%s length is defined but value(%d) is larger than defined(%d)", f.tg.name, len(b), f.tg.length)
+	if f.tg.length != -1 && len(dst)-start > f.tg.length {
+		return nil, fmt.Errorf("llvar field:%s length is defined but value(%d) is larger than defined(%d)", f.tg.name, len(dst)-start, f.tg.length)
 	}
 	if f.tg.valEncode != ascii {
 		return nil, fmt.Errorf("llvar field:%s value encode must be ascii", f.tg.name)
 	}
-	contentLen := []byte(fmt.Sprintf("%02d", len(b)))
+	var buf [8]byte
+	contentLen := appendLength(buf[:0], len(dst)-start, 2)
 	var lenVal []byte
 	switch f.tg.lenEncode {
 	case ascii:
@@ -83,31 +88,33 @@ func (f fieldEncoder) llvarParse(b []byte) ([]byte, error) {
 	case rbcd:
 		fallthrough
 	case bcd:
-		var err error
-		lenVal, err = rbcdEncode(contentLen)
+		digits := len(contentLen)
+		lenVal, err = appendBcd(contentLen, 0, '0', true)
 		if err != nil {
 			return nil, fmt.Errorf("llvar field:%s rbcd encode failed %s", f.tg.name, err.Error())
 		}
-		if len(lenVal) > 1 || len(contentLen) > 3 {
-			return nil, fmt.Errorf("llvar field:%s bcd length value is invalid(%d) content length(%d)", f.tg.name, len(lenVal), len(contentLen))
+		if len(lenVal) > 1 || digits > 3 {
+			return nil, fmt.Errorf("llvar field:%s bcd length value is invalid(%d) content length(%d)", f.tg.name, len(lenVal), digits)
 		}
 	default:
 		return nil, fmt.Errorf("llvar field:%s length encode is not valid %s", f.tg.name, f.tg.lenEncode.value())
 	}
-	return append(lenVal, b...), nil
+	return insertBytes(dst, start, lenVal), nil
 }
 
-func (f fieldEncoder) lllvarParse(b []byte) ([]byte, error) {
-	if cp := f.tg.codePage.value(); cp != "" {
-		b = encodeUTF8(cp, b)
+func (f fieldEncoder) lllvarParse(dst []byte, start int) ([]byte, error) {
+	dst, err := f.encodeCodepage(dst, start)
+	if err != nil {
+		return nil, fmt.Errorf("lllvar field:%s %s", f.tg.name, err.Error())
 	}
-	if f.tg.length != -1 && len(b) > f.tg.length {
-		return nil, fmt.Errorf("lllvar field:%s length is defined but value(%d) is larger than defined(%d)", f.tg.name, len(b), f.tg.length)
+	if f.tg.length != -1 && len(dst)-start > f.tg.length {
+		return nil, fmt.Errorf("lllvar field:%s length is defined but value(%d) is larger than defined(%d)", f.tg.name, len(dst)-start, f.tg.length)
 	}
 	if f.tg.valEncode != ascii {
 		return nil, fmt.Errorf("lllvar field:%s value encode must be ascii", f.tg.name)
 	}
-	contentLen := []byte(fmt.Sprintf("%03d", len(b)))
+	var buf [8]byte
+	contentLen := appendLength(buf[:0], len(dst)-start, 3)
 	var lenVal []byte
 	switch f.tg.lenEncode {
 	case ascii:
@@ -118,16 +125,52 @@ func (f fieldEncoder) lllvarParse(b []byte) ([]byte, error) {
 	case rbcd:
 		fallthrough
 	case bcd:
-		var err error
-		lenVal, err = rbcdEncode(contentLen)
+		digits := len(contentLen)
+		lenVal, err = appendBcd(contentLen, 0, '0', true)
 		if err != nil {
 			return nil, fmt.Errorf("lllvar field:%s rbcd encode failed %s", f.tg.name, err.Error())
 		}
-		if len(lenVal) > 2 || len(contentLen) > 3 {
-			return nil, fmt.Errorf("lllvar field:%s bcd length value is invalid(%d) content length(%d)", f.tg.name, len(lenVal), len(contentLen))
+		if len(lenVal) > 2 || digits > 3 {
+			return nil, fmt.Errorf("lllvar field:%s bcd length value is invalid(%d) content length(%d)", f.tg.name, len(lenVal), digits)
 		}
 	default:
 		return nil, fmt.Errorf("lllvar field:%s length encode is not valid %s", f.tg.name, f.tg.lenEncode.value())
 	}
-	return append(lenVal, b...), nil
+	return insertBytes(dst, start, lenVal), nil
+}
+
+func (f fieldEncoder) customParse(c customFieldType, dst []byte, start int) ([]byte, error) {
+	dst, err := f.encodeCodepage(dst, start)
+	if err != nil {
+		return nil, fmt.Errorf("%s field:%s %s", c.name, f.tg.name, err.Error())
+	}
+	ret, err := c.codec.Encode(f.tg.export(), dst[start:len(dst):len(dst)])
+	if err != nil {
+		return nil, fmt.Errorf("%s field:%s encode failed %s", c.name, f.tg.name, err.Error())
+	}
+	return append(dst[:start], ret...), nil
+}
+
+//encodeCodepage converts the text at dst[start:] to the code page of the field
+func (f fieldEncoder) encodeCodepage(dst []byte, start int) ([]byte, error) {
+	cp := f.tg.codePage.value()
+	if cp == "" {
+		return dst, nil
+	}
+	b, err := encodeUTF8(cp, f.tg.cpPolicy.under(f.strict), dst[start:])
+	if err != nil {
+		return nil, err
+	}
+	return append(dst[:start], b...), nil
+}
+
+//appendLength appends n in decimal with leading zeros to at least width digits
+func appendLength(dst []byte, n int, width int) []byte {
+	for d := 10; width > 1; width-- {
+		if n < d {
+			dst = append(dst, '0')
+		}
+		d *= 10
+	}
+	return strconv.AppendInt(dst, int64(n), 10)
 }
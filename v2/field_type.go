@@ -0,0 +1,71 @@
+package iso8583v2
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//FieldCodec is the wire format of a field type registered by RegisterFieldType
+type FieldCodec interface {
+	//Encode returns the wire bytes of value, including any length prefix
+	Encode(tag FieldTag, value []byte) ([]byte, error)
+	//Decode reads one field from the front of data and returns its value and the bytes left
+	Decode(tag FieldTag, data []byte) (value []byte, left []byte, err error)
+}
+
+const customFieldTypeBase iso8583FieldType = 1000
+
+type customFieldType struct {
+	name  string
+	codec FieldCodec
+}
+
+var (
+	fieldTypeLock   sync.RWMutex
+	fieldTypeNames  = make(map[string]iso8583FieldType)
+	fieldTypeCodecs = make(map[iso8583FieldType]customFieldType)
+)
+
+//RegisterFieldType makes codec available to struct tags as type:"name"
+func RegisterFieldType(name string, codec FieldCodec) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return fmt.Errorf("field type name must be specified")
+	}
+	if codec == nil {
+		return fmt.Errorf("field type %s codec must not be nil", name)
+	}
+	if _, err := parseBuiltinType(name); err == nil {
+		return fmt.Errorf("field type %s is built in", name)
+	}
+	fieldTypeLock.Lock()
+	defer fieldTypeLock.Unlock()
+	if _, ok := fieldTypeNames[name]; ok {
+		return fmt.Errorf("field type %s is already registered", name)
+	}
+	t := customFieldTypeBase + iso8583FieldType(len(fieldTypeNames))
+	fieldTypeNames[name] = t
+	fieldTypeCodecs[t] = customFieldType{
+		name:  name,
+		codec: codec,
+	}
+	return nil
+}
+
+func lookupFieldType(name string) (iso8583FieldType, bool) {
+	fieldTypeLock.RLock()
+	t, ok := fieldTypeNames[name]
+	fieldTypeLock.RUnlock()
+	return t, ok
+}
+
+func lookupFieldCodec(t iso8583FieldType) (customFieldType, bool) {
+	if t < customFieldTypeBase {
+		return customFieldType{}, false
+	}
+	fieldTypeLock.RLock()
+	c, ok := fieldTypeCodecs[t]
+	fieldTypeLock.RUnlock()
+	return c, ok
+}
@@ -1,15 +1,15 @@
 package iso8583v2
 
 import (
-	"bytes"
 	"fmt"
 	"reflect"
 	"strconv"
 )
 
 type fixedwidthDecoder struct {
-	v  reflect.Value
-	tg fixedwidthTag
+	v   reflect.Value
+	tg  fixedwidthTag
+	opt options
 }
 
 func (f *fixedwidthDecoder) ptrDecodeFunc(data []byte) error {
@@ -20,8 +20,13 @@ func (f *fixedwidthDecoder) ptrDecodeFunc(data []byte) error {
 	for f.v.Kind() == reflect.Ptr {
 		f.v = reflect.Indirect(f.v)
 	}
+	if u := unmarshalerValue(f.v); u != nil {
+		return f.unmarshalerDecodeFunc(u)(data)
+	}
 
 	switch f.v.Kind() {
+	case reflect.Slice:
+		return f.bytesDecodeFunc(data)
 	case reflect.String:
 		return f.stringDecodeFunc(data)
 	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
@@ -40,14 +45,78 @@ func (f *fixedwidthDecoder) stringDecodeFunc(data []byte) (err error) {
 			err = fmt.Errorf("field:%s string decode failed %v", f.tg.name, r)
 		}
 	}()
-	if c := f.tg.codePage.value(); c != "" {
-		data = decodeUTF8(c, data)
+	if data, err = f.decodeCodepage(data); err != nil {
+		return
+	}
+	data = f.tg.trimValue(data)
+	if data, err = f.valueText(data); err != nil {
+		return
 	}
-	data = bytes.TrimSpace(data)
 	f.v.SetString(string(data))
 	return
 }
 
+func (f *fixedwidthDecoder) bytesDecodeFunc(data []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("field:%s bytes decode failed %v", f.tg.name, r)
+		}
+	}()
+	if data, err = f.decodeCodepage(data); err != nil {
+		return
+	}
+	data = f.tg.trimValue(data)
+	if data, err = f.valueText(data); err != nil {
+		return
+	}
+	f.v.SetBytes(f.opt.bytesValue(data))
+	return
+}
+
+func (f *fixedwidthDecoder) unmarshalerDecodeFunc(u interface{}) func(data []byte) error {
+	return func(data []byte) error {
+		data, err := f.decodeCodepage(data)
+		if err != nil {
+			return err
+		}
+		data = f.tg.trimValue(data)
+		if len(data) < 1 {
+			return nil
+		}
+		if data, err = f.valueText(data); err != nil {
+			return err
+		}
+		if err := unmarshalValue(u, f.tg.export(), data); err != nil {
+			return fmt.Errorf("field:%s unmarshaler %s", f.tg.name, err.Error())
+		}
+		return nil
+	}
+}
+
+//valueText strips the padding of a pad tag from data and checks its class in strict mode,
+//data is the text after the code page is decoded and trimmed
+func (f *fixedwidthDecoder) valueText(data []byte) ([]byte, error) {
+	data = f.tg.pad.strip(data, subfieldPad(f.v.Kind()), false, isPadNumberKind(f.v.Kind()))
+	if f.opt.strict {
+		if err := checkSubfieldClass(f.tg, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func (f *fixedwidthDecoder) decodeCodepage(data []byte) ([]byte, error) {
+	c := f.tg.codePage.value()
+	if c == "" {
+		return data, nil
+	}
+	data, err := decodeUTF8(c, f.tg.cpPolicy.under(f.opt.strict), data)
+	if err != nil {
+		return nil, fmt.Errorf("field:%s %s", f.tg.name, err.Error())
+	}
+	return data, nil
+}
+
 func (f *fixedwidthDecoder) intDecodeFunc(data []byte) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -57,7 +126,15 @@ func (f *fixedwidthDecoder) intDecodeFunc(data []byte) (err error) {
 	if len(data) < 1 {
 		return
 	}
-	data = bytes.TrimSpace(data)
+	data = f.tg.trimValue(data)
+	if data, err = f.valueText(data); err != nil {
+		return
+	}
+	if len(data) == 0 && f.tg.pad.isSet() {
+		//a value of only padding
+		f.v.SetInt(0)
+		return
+	}
 	i, err := strconv.Atoi(string(data))
 	if err != nil {
 		return
@@ -77,7 +154,14 @@ func (f *fixedwidthDecoder) getFloatDecoder(bitSize int) func(data []byte) error
 			return
 		}
 		var fval float64
-		data = bytes.TrimSpace(data)
+		data = f.tg.trimValue(data)
+		if data, err = f.valueText(data); err != nil {
+			return
+		}
+		if len(data) == 0 && f.tg.pad.isSet() {
+			f.v.SetFloat(0)
+			return
+		}
 		fval, err = strconv.ParseFloat(string(data), bitSize)
 		if err != nil {
 			return
@@ -91,14 +175,20 @@ func (f *fixedwidthDecoder) unknownFunc(data []byte) error {
 	return fmt.Errorf("field:%s unsupported decoder %s", f.tg.name, f.v.Type().Kind().String())
 }
 
-func getFixedwidthDecoder(v reflect.Value, tg fixedwidthTag) func(data []byte) error {
+func getFixedwidthDecoder(v reflect.Value, tg fixedwidthTag, opt options) func(data []byte) error {
 	fEnc := &fixedwidthDecoder{
-		v:  v,
-		tg: tg,
+		v:   v,
+		tg:  tg,
+		opt: opt,
+	}
+	if u := unmarshalerValue(v); u != nil {
+		return fEnc.unmarshalerDecodeFunc(u)
 	}
 	switch v.Kind() {
 	case reflect.Ptr:
 		return fEnc.ptrDecodeFunc
+	case reflect.Slice:
+		return fEnc.bytesDecodeFunc
 	case reflect.String:
 		return fEnc.stringDecodeFunc
 	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
@@ -4,118 +4,192 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
-	"strings"
 )
 
 type fixedwidthEncoder struct {
 	typ         reflect.Type
 	tg          fixedwidthTag
 	usingBitmap bool
+	strict      bool
+	marshaler   bool
 }
 
-type fixedwidthEncoderFunc func(v reflect.Value) ([]byte, error)
+//fixedwidthEncoderFunc appends the encoded sub field value of v to dst, a sub field that appends nothing
+//is left out of the bitmap
+type fixedwidthEncoderFunc func(dst []byte, v reflect.Value) ([]byte, error)
 
-func (f fixedwidthEncoder) nilFunc(v reflect.Value) ([]byte, error) {
-	return nil, nil
+func newFixedwidthEncoder(typ reflect.Type, tg fixedwidthTag, usingBitmap bool, strict bool) fixedwidthEncoder {
+	return fixedwidthEncoder{
+		typ:         typ,
+		tg:          tg,
+		usingBitmap: usingBitmap,
+		strict:      strict,
+		marshaler:   typ != nil && (implements(typ, fieldMarshalerType) || implements(typ, textMarshalerType)),
+	}
+}
+
+//encode appends the encoded sub field value of v to dst by the type of the sub field
+func (f fixedwidthEncoder) encode(dst []byte, v reflect.Value) ([]byte, error) {
+	if f.typ == nil {
+		return dst, nil
+	}
+	if f.marshaler {
+		return f.marshalerEncodeFunc(dst, v)
+	}
+	switch f.typ.Kind() {
+	case reflect.Ptr:
+		return f.ptrEncodeFunc(dst, v)
+	case reflect.Slice:
+		return f.bytesEncodeFunc(dst, v)
+	case reflect.String:
+		return f.stringEncodeFunc(dst, v)
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		return f.intEncodeFunc(dst, v)
+	case reflect.Float32:
+		return f.floatEncodeFunc(dst, v, 32)
+	case reflect.Float64:
+		return f.floatEncodeFunc(dst, v, 64)
+	}
+	return f.unknownFunc(dst, v)
 }
 
-func (f fixedwidthEncoder) unknownFunc(v reflect.Value) ([]byte, error) {
+func (f fixedwidthEncoder) unknownFunc(dst []byte, v reflect.Value) ([]byte, error) {
 	return nil, fmt.Errorf("fixedwidth type %s is not support for this library value(%#v)", f.typ.Kind(), v)
 }
 
-func (f fixedwidthEncoder) ptrEncodeFunc(v reflect.Value) ([]byte, error) {
+func (f fixedwidthEncoder) marshalerEncodeFunc(dst []byte, v reflect.Value) ([]byte, error) {
+	b, ok, err := marshalValue(v, f.tg.export())
+	if err != nil {
+		return nil, fmt.Errorf("fixed width field:%s marshaler %s", f.tg.name, err.Error())
+	}
+	if !ok || (len(b) <= 0 && f.usingBitmap) {
+		return dst, nil
+	}
+	return f.appendStringValue(append(dst, b...), len(dst))
+}
+
+func (f fixedwidthEncoder) ptrEncodeFunc(dst []byte, v reflect.Value) ([]byte, error) {
 	if v.IsNil() {
-		return nil, nil
+		return dst, nil
 	}
 	for v.Kind() == reflect.Ptr {
 		v = v.Elem()
 	}
 
 	switch v.Kind() {
+	case reflect.Slice:
+		return f.bytesEncodeFunc(dst, v)
 	case reflect.String:
-		return f.stringEncodeFunc(v)
+		return f.stringEncodeFunc(dst, v)
 	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
-		return f.intEncodeFunc(v)
+		return f.intEncodeFunc(dst, v)
 	case reflect.Float32:
-		return f.getFloatEncoder(0, 32)(v)
+		return f.floatEncodeFunc(dst, v, 32)
 	case reflect.Float64:
-		return f.getFloatEncoder(0, 64)(v)
+		return f.floatEncodeFunc(dst, v, 64)
 	default:
 		return nil, fmt.Errorf("fixedwidth pointer field:%s type is not supported %v", f.tg.name, v.Kind())
 	}
 }
 
-func (f fixedwidthEncoder) stringEncodeFunc(v reflect.Value) ([]byte, error) {
+func (f fixedwidthEncoder) stringEncodeFunc(dst []byte, v reflect.Value) ([]byte, error) {
 	if v.String() == "" && f.usingBitmap {
-		return []byte{}, nil
+		return dst, nil
+	}
+	return f.appendStringValue(append(dst, v.String()...), len(dst))
+}
+
+func (f fixedwidthEncoder) bytesEncodeFunc(dst []byte, v reflect.Value) (bret []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("encode failed fixed width field:%s slice supported only byte array", f.tg.name)
+		}
+	}()
+	if v.Len() == 0 && f.usingBitmap {
+		return dst, nil
 	}
-	return f.parseStringValue([]byte(v.String()))
+	return f.appendStringValue(append(dst, v.Bytes()...), len(dst))
 }
 
-func (f fixedwidthEncoder) intEncodeFunc(v reflect.Value) ([]byte, error) {
+func (f fixedwidthEncoder) intEncodeFunc(dst []byte, v reflect.Value) ([]byte, error) {
 	if v.Int() == 0 && f.usingBitmap {
-		return []byte{}, nil
+		return dst, nil
 	}
-	return f.parseNumericValue([]byte(strconv.Itoa(int(v.Int()))))
+	return f.appendNumericValue(strconv.AppendInt(dst, v.Int(), 10), len(dst))
 }
 
-func (f fixedwidthEncoder) getFloatEncoder(perc, bitsize int) fixedwidthEncoderFunc {
-	return func(v reflect.Value) ([]byte, error) {
-		if v.Float() == 0 && f.usingBitmap {
-			return []byte{}, nil
-		}
-		return f.parseNumericValue([]byte(strconv.FormatFloat(v.Float(), 'f', perc, bitsize)))
+func (f fixedwidthEncoder) floatEncodeFunc(dst []byte, v reflect.Value, bitsize int) ([]byte, error) {
+	if v.Float() == 0 && f.usingBitmap {
+		return dst, nil
 	}
+	return f.appendNumericValue(strconv.AppendFloat(dst, v.Float(), 'f', 0, bitsize), len(dst))
 }
 
+//parseNumericValue encodes b on its own, b is left unchanged
 func (f fixedwidthEncoder) parseNumericValue(b []byte) ([]byte, error) {
-	if cp := f.tg.codePage.value(); cp != "" {
-		b = encodeUTF8(cp, b)
-	}
-	if len(b) > f.tg.length {
-		return nil, fmt.Errorf("fixed width field:%s numberic is larger than configure %d, actual %d", f.tg.name, f.tg.length, len(b))
-	}
-	if len(b) < f.tg.length {
-		b = append([]byte(strings.Repeat("0", f.tg.length-len(b))), b...)
-	}
-	return b, nil
+	return f.appendNumericValue(append(make([]byte, 0, f.tg.length), b...), 0)
 }
 
+//parseStringValue encodes b on its own, b is left unchanged
 func (f fixedwidthEncoder) parseStringValue(b []byte) ([]byte, error) {
-	if cp := f.tg.codePage.value(); cp != "" {
-		b = encodeUTF8(cp, b)
+	return f.appendStringValue(append(make([]byte, 0, f.tg.length), b...), 0)
+}
+
+//appendNumericValue encodes the text at dst[start:] in place as a numeric sub field value
+func (f fixedwidthEncoder) appendNumericValue(dst []byte, start int) ([]byte, error) {
+	if f.strict {
+		if err := checkSubfieldClass(f.tg, dst[start:]); err != nil {
+			return nil, err
+		}
+	}
+	dst, err := f.encodeCodepage(dst, start)
+	if err != nil {
+		return nil, err
 	}
-	if len(b) > f.tg.length {
-		b = b[:f.tg.length]
+	if len(dst)-start > f.tg.length {
+		return nil, fmt.Errorf("fixed width field:%s numberic is larger than configure %d, actual %d", f.tg.name, f.tg.length, len(dst)-start)
 	}
-	if len(b) < f.tg.length {
-		b = append(b, []byte(strings.Repeat(" ", f.tg.length-len(b)))...)
+	dst, err = f.tg.pad.or(numericPad).appendFill(dst, start, f.tg.length, f.tg.codePage)
+	if err != nil {
+		return nil, fmt.Errorf("fixed width field:%s padding %s", f.tg.name, err.Error())
 	}
-	return b, nil
+	return dst, nil
 }
 
-func getFixedwidthEncoder(typ reflect.Type, tg fixedwidthTag, usingBitmap bool) fixedwidthEncoderFunc {
-	fEnc := fixedwidthEncoder{
-		typ:         typ,
-		tg:          tg,
-		usingBitmap: usingBitmap,
+//appendStringValue encodes the text at dst[start:] in place as a sub field value, a longer value is cut
+func (f fixedwidthEncoder) appendStringValue(dst []byte, start int) ([]byte, error) {
+	if f.strict {
+		if err := checkSubfieldClass(f.tg, dst[start:]); err != nil {
+			return nil, err
+		}
+	}
+	dst, err := f.encodeCodepage(dst, start)
+	if err != nil {
+		return nil, err
+	}
+	if len(dst)-start > f.tg.length {
+		dst = dst[:start+f.tg.length]
 	}
-	if typ == nil {
-		return fEnc.nilFunc
+	dst, err = f.tg.pad.or(alphaPad).appendFill(dst, start, f.tg.length, f.tg.codePage)
+	if err != nil {
+		return nil, fmt.Errorf("fixed width field:%s padding %s", f.tg.name, err.Error())
 	}
+	return dst, nil
+}
 
-	switch typ.Kind() {
-	case reflect.Ptr:
-		return fEnc.ptrEncodeFunc
-	case reflect.String:
-		return fEnc.stringEncodeFunc
-	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
-		return fEnc.intEncodeFunc
-	case reflect.Float32:
-		return fEnc.getFloatEncoder(0, 32)
-	case reflect.Float64:
-		return fEnc.getFloatEncoder(0, 64)
+//encodeCodepage converts the text at dst[start:] to the code page of the sub field
+func (f fixedwidthEncoder) encodeCodepage(dst []byte, start int) ([]byte, error) {
+	cp := f.tg.codePage.value()
+	if cp == "" {
+		return dst, nil
 	}
+	b, err := encodeUTF8(cp, f.tg.cpPolicy.under(f.strict), dst[start:])
+	if err != nil {
+		return nil, fmt.Errorf("fixed width field:%s %s", f.tg.name, err.Error())
+	}
+	return append(dst[:start], b...), nil
+}
 
-	return fEnc.unknownFunc
+func getFixedwidthEncoder(typ reflect.Type, tg fixedwidthTag, usingBitmap bool, strict bool) fixedwidthEncoderFunc {
+	return newFixedwidthEncoder(typ, tg, usingBitmap, strict).encode
 }
@@ -0,0 +1,446 @@
+package iso8583v2
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+//Marshaler is implemented by types with a MarshalISO8583 method generated by iso8583gen,
+//Marshal and MarshalAppend call it instead of using reflection
+type Marshaler interface {
+	MarshalISO8583() ([]byte, error)
+}
+
+//Unmarshaler is implemented by types with an UnmarshalISO8583 method generated by iso8583gen,
+//Unmarshal calls it instead of using reflection
+type Unmarshaler interface {
+	UnmarshalISO8583(data []byte) error
+}
+
+//GenField is a message field parsed from its struct tag,
+//it is used by code generated by iso8583gen and is safe for concurrent use
+type GenField struct {
+	tg iso8583Tag
+}
+
+//GenSubfield is a fixed width sub field parsed from its struct tag,
+//it is used by code generated by iso8583gen and is safe for concurrent use
+type GenSubfield struct {
+	tg          fixedwidthTag
+	parent      string
+	usingBitmap bool
+}
+
+//ParseGenField parses the struct tag of the field name the same way Marshal does
+func ParseGenField(name string, tag string) (*GenField, error) {
+	t, err := parseIso8583Tag(reflect.StructField{Name: name, Tag: reflect.StructTag(tag)})
+	if err != nil {
+		return nil, fmt.Errorf("field:%s %s", name, err.Error())
+	}
+	return &GenField{tg: t}, nil
+}
+
+//MustGenField is like ParseGenField but panics when the tag is invalid
+func MustGenField(name string, tag string) *GenField {
+	f, err := ParseGenField(name, tag)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+//ParseGenFieldDict parses the struct tag of a field of a message whose mti names the dictionary dict
+func ParseGenFieldDict(dict string, name string, tag string) (*GenField, error) {
+	d, err := parseDictionary(dict)
+	if err != nil {
+		return nil, fmt.Errorf("field:%s %s", name, err.Error())
+	}
+	t, err := parseMessageTag(reflect.StructField{Name: name, Tag: reflect.StructTag(tag)}, d)
+	if err != nil {
+		return nil, fmt.Errorf("field:%s %s", name, err.Error())
+	}
+	return &GenField{tg: t}, nil
+}
+
+//MustGenFieldDict is like ParseGenFieldDict but panics when the tag is invalid
+func MustGenFieldDict(dict string, name string, tag string) *GenField {
+	f, err := ParseGenFieldDict(dict, name, tag)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+//Dictionary returns the dictionary an mti field names for its message, none when it is turned off
+func (f *GenField) Dictionary() string {
+	if f.tg.dict == nil {
+		return noDictionary
+	}
+	return f.tg.dict.Name()
+}
+
+//ParseGenSubfield parses the struct tag of the sub field name of parent
+func ParseGenSubfield(parent *GenField, name string, tag string) (*GenSubfield, error) {
+	t, err := parseFixedLengthTag(reflect.StructField{Name: name, Tag: reflect.StructTag(tag)})
+	if err != nil {
+		return nil, fmt.Errorf("field:%s subfield:%s %s", parent.tg.name, name, err.Error())
+	}
+	if t.field < 1 {
+		return nil, fmt.Errorf("field:%s has sub field below 1", parent.tg.name)
+	}
+	return &GenSubfield{tg: t, parent: parent.tg.name, usingBitmap: parent.tg.bitmapSize > 0}, nil
+}
+
+//MustGenSubfield is like ParseGenSubfield but panics when the tag is invalid
+func MustGenSubfield(parent *GenField, name string, tag string) *GenSubfield {
+	s, err := ParseGenSubfield(parent, name, tag)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+//IsMTI reports whether the field is the message type indicator
+func (f *GenField) IsMTI() bool {
+	return f.tg.isMti
+}
+
+//Tag describes the field
+func (f *GenField) Tag() FieldTag {
+	return f.tg.export()
+}
+
+//Tag describes the sub field
+func (s *GenSubfield) Tag() FieldTag {
+	return s.tg.export()
+}
+
+func (f *GenField) encoder() fieldEncoder {
+	return fieldEncoder{tg: f.tg}
+}
+
+//EncodeMTI encodes the message type indicator
+func (f *GenField) EncodeMTI(mti string) ([]byte, error) {
+	return encodeMtiString(mti, f.tg)
+}
+
+//EncodeString encodes a string field, an empty string is an empty field
+func (f *GenField) EncodeString(s string) ([]byte, error) {
+	if s == "" {
+		return []byte{}, nil
+	}
+	return f.encoder().parseValue([]byte(s))
+}
+
+//EncodeInt encodes an integer field, zero is an empty field
+func (f *GenField) EncodeInt(i int64) ([]byte, error) {
+	if i == 0 {
+		return []byte{}, nil
+	}
+	return f.encoder().parseValue([]byte(strconv.Itoa(int(i))))
+}
+
+//EncodeFloat encodes a float field of bitSize, zero is an empty field
+func (f *GenField) EncodeFloat(fl float64, bitSize int) ([]byte, error) {
+	if fl == 0 {
+		return []byte{}, nil
+	}
+	return f.encoder().parseValue([]byte(strconv.FormatFloat(fl, 'f', 0, bitSize)))
+}
+
+//EncodeBytes encodes a []byte field, an empty slice is an empty field
+func (f *GenField) EncodeBytes(b []byte) ([]byte, error) {
+	if len(b) <= 0 {
+		return []byte{}, nil
+	}
+	return f.encoder().parseValue(b)
+}
+
+//NewStruct starts the fixed width value of a struct field
+func (f *GenField) NewStruct() *GenStruct {
+	return &GenStruct{f: f, dataMap: make(map[int][]byte)}
+}
+
+//DecodeString converts a field value to a string
+func (f *GenField) DecodeString(val []byte) (string, error) {
+	val, err := f.decoder().decodeCodepage(val)
+	if err != nil {
+		return "", f.decodeError(err)
+	}
+	return string(f.tg.stripPad(f.tg.trimValue(val), false)), nil
+}
+
+//DecodeInt converts a field value to an integer
+func (f *GenField) DecodeInt(val []byte) (int64, error) {
+	val = f.tg.stripPad(f.tg.trimValue(val), true)
+	if len(val) == 0 && f.tg.pad.isSet() {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(string(val))
+	if err != nil {
+		return 0, f.decodeError(err)
+	}
+	return int64(i), nil
+}
+
+//DecodeFloat converts a field value to a float of bitSize
+func (f *GenField) DecodeFloat(val []byte, bitSize int) (float64, error) {
+	val = f.tg.stripPad(f.tg.trimValue(val), true)
+	if len(val) == 0 && f.tg.pad.isSet() {
+		return 0, nil
+	}
+	fl, err := strconv.ParseFloat(string(val), bitSize)
+	if err != nil {
+		return 0, f.decodeError(err)
+	}
+	return fl, nil
+}
+
+//DecodeBytes copies a field value without its trim and padding
+func (f *GenField) DecodeBytes(val []byte) []byte {
+	return options{}.bytesValue(f.tg.stripPad(f.tg.trimValue(val), false))
+}
+
+//NewStructReader starts reading the sub fields of a struct field value
+func (f *GenField) NewStructReader(val []byte) (*GenStructReader, error) {
+	r := &GenStructReader{f: f, data: val}
+	if f.tg.bitmapSize > 0 {
+		if f.tg.bitmapSize > len(val) {
+			return nil, f.decodeError(fmt.Errorf("field:%s bitmap size(%d) is too big than data(%d)", f.tg.name, f.tg.bitmapSize, len(val)))
+		}
+		r.bitmap = val[:f.tg.bitmapSize]
+		r.idx = f.tg.bitmapSize
+	}
+	return r, nil
+}
+
+func (f *GenField) decoder() *fieldDecoder {
+	return &fieldDecoder{tg: f.tg}
+}
+
+func (f *GenField) decodeError(err error) error {
+	return fmt.Errorf("decode field:%s failed %s", f.tg.name, err.Error())
+}
+
+func (s *GenSubfield) encoder() fixedwidthEncoder {
+	return fixedwidthEncoder{tg: s.tg, usingBitmap: s.usingBitmap}
+}
+
+//EncodeString encodes a string sub field
+func (s *GenSubfield) EncodeString(v string) ([]byte, error) {
+	if v == "" && s.usingBitmap {
+		return []byte{}, nil
+	}
+	return s.encoder().parseStringValue([]byte(v))
+}
+
+//EncodeInt encodes an integer sub field
+func (s *GenSubfield) EncodeInt(i int64) ([]byte, error) {
+	if i == 0 && s.usingBitmap {
+		return []byte{}, nil
+	}
+	return s.encoder().parseNumericValue([]byte(strconv.Itoa(int(i))))
+}
+
+//EncodeFloat encodes a float sub field of bitSize
+func (s *GenSubfield) EncodeFloat(fl float64, bitSize int) ([]byte, error) {
+	if fl == 0 && s.usingBitmap {
+		return []byte{}, nil
+	}
+	return s.encoder().parseNumericValue([]byte(strconv.FormatFloat(fl, 'f', 0, bitSize)))
+}
+
+//EncodeBytes encodes a []byte sub field
+func (s *GenSubfield) EncodeBytes(b []byte) ([]byte, error) {
+	if len(b) == 0 && s.usingBitmap {
+		return []byte{}, nil
+	}
+	return s.encoder().parseStringValue(append([]byte(nil), b...))
+}
+
+func (s *GenSubfield) decode(val []byte) ([]byte, error) {
+	d := fixedwidthDecoder{tg: s.tg}
+	val, err := d.decodeCodepage(val)
+	if err != nil {
+		return nil, s.decodeError(err)
+	}
+	return s.tg.pad.strip(s.tg.trimValue(val), alphaPad, false, false), nil
+}
+
+func (s *GenSubfield) decodeError(err error) error {
+	return fmt.Errorf("decode field:%s subfield:%s failed %s", s.parent, s.tg.name, err.Error())
+}
+
+//DecodeString converts a sub field value to a string without the padding
+func (s *GenSubfield) DecodeString(val []byte) (string, error) {
+	val, err := s.decode(val)
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+//DecodeInt converts a sub field value to an integer,
+//ok is false for an empty value which leaves the field unchanged
+func (s *GenSubfield) DecodeInt(val []byte) (i int64, ok bool, err error) {
+	if len(val) < 1 {
+		return 0, false, nil
+	}
+	val = s.tg.pad.strip(s.tg.trimValue(val), numericPad, false, true)
+	if len(val) == 0 && s.tg.pad.isSet() {
+		return 0, true, nil
+	}
+	n, err := strconv.Atoi(string(val))
+	if err != nil {
+		return 0, false, s.decodeError(err)
+	}
+	return int64(n), true, nil
+}
+
+//DecodeFloat converts a sub field value to a float of bitSize,
+//ok is false for an empty value which leaves the field unchanged
+func (s *GenSubfield) DecodeFloat(val []byte, bitSize int) (fl float64, ok bool, err error) {
+	if len(val) < 1 {
+		return 0, false, nil
+	}
+	val = s.tg.pad.strip(s.tg.trimValue(val), numericPad, false, true)
+	if len(val) == 0 && s.tg.pad.isSet() {
+		return 0, true, nil
+	}
+	fl, err = strconv.ParseFloat(string(val), bitSize)
+	if err != nil {
+		return 0, false, s.decodeError(err)
+	}
+	return fl, true, nil
+}
+
+//DecodeBytes copies a sub field value without the padding
+func (s *GenSubfield) DecodeBytes(val []byte) ([]byte, error) {
+	val, err := s.decode(val)
+	if err != nil {
+		return nil, err
+	}
+	return options{}.bytesValue(val), nil
+}
+
+//GenMessage collects encoded fields for a generated MarshalISO8583 method
+type GenMessage struct {
+	mti     []byte
+	dataMap map[int][]byte
+}
+
+//NewGenMessage returns an empty message
+func NewGenMessage() *GenMessage {
+	return &GenMessage{dataMap: make(map[int][]byte)}
+}
+
+//SetMTI sets the encoded message type indicator
+func (m *GenMessage) SetMTI(b []byte) {
+	m.mti = b
+}
+
+//Add sets the encoded value of f, a nil value leaves the field out
+func (m *GenMessage) Add(f *GenField, b []byte) {
+	if b != nil {
+		m.dataMap[f.tg.field] = b
+	}
+}
+
+//Bytes returns the message with its bitmap
+func (m *GenMessage) Bytes() ([]byte, error) {
+	if len(m.mti) == 0 {
+		return nil, fmt.Errorf("Encode failed because mti is required")
+	}
+	return encodeStructValue(nil, m.dataMap, m.mti)
+}
+
+//GenStruct collects encoded sub fields of a struct field
+type GenStruct struct {
+	f       *GenField
+	dataMap map[int][]byte
+}
+
+//Add sets the encoded value of s, a nil value leaves the sub field out
+func (g *GenStruct) Add(s *GenSubfield, b []byte) {
+	if b != nil {
+		g.dataMap[s.tg.field] = b
+	}
+}
+
+//Bytes returns the encoded struct field
+func (g *GenStruct) Bytes() ([]byte, error) {
+	enc := g.f.encoder()
+	b, err := enc.encodeFixedwidthStructValue(g.dataMap)
+	if err != nil {
+		return nil, err
+	}
+	return enc.parseStructValue(b)
+}
+
+//GenReader reads the fields of a message for a generated UnmarshalISO8583 method,
+//fields must be read in struct declaration order
+type GenReader struct {
+	data   []byte
+	bitmap bitmapDecoder
+	fd     fieldDecoder
+}
+
+//NewGenReader decodes the message type indicator and bitmap at the head of data
+func NewGenReader(data []byte, mti *GenField) (*GenReader, string, error) {
+	m, data, err := decodeMti(data, mti.tg)
+	if err != nil {
+		return nil, "", fmt.Errorf("decode mti failed %s", err.Error())
+	}
+	r := &GenReader{}
+	if r.data, err = r.bitmap.decode(data); err != nil {
+		return nil, "", fmt.Errorf("decode bitmap failed %s", err.Error())
+	}
+	r.fd.getBitmap = r.bitmap.getBitmap
+	return r, m, nil
+}
+
+//Next returns the value of f, ok is false when the field is not in the message
+func (r *GenReader) Next(f *GenField) (val []byte, ok bool, err error) {
+	r.fd.tg = f.tg
+	on, err := r.fd.isOn(r.data)
+	if err != nil {
+		return nil, false, f.decodeError(err)
+	}
+	if !on {
+		return nil, false, nil
+	}
+	val, r.data, err = r.fd.read(r.data)
+	if err != nil {
+		return nil, false, f.decodeError(err)
+	}
+	return val, true, nil
+}
+
+//GenStructReader reads the sub fields of a struct field value,
+//sub fields must be read in struct declaration order
+type GenStructReader struct {
+	f      *GenField
+	data   []byte
+	bitmap []byte
+	idx    int
+}
+
+//Next returns the value of s, ok is false when the bitmap leaves it out
+func (r *GenStructReader) Next(s *GenSubfield) (val []byte, ok bool, err error) {
+	if r.bitmap != nil {
+		on, err := isBitOn(r.bitmap, s.tg.field)
+		if err != nil {
+			return nil, false, r.f.decodeError(fmt.Errorf("field%s subfield:%s %s", r.f.tg.name, s.tg.name, err.Error()))
+		}
+		if !on {
+			return nil, false, nil
+		}
+	}
+	if r.idx+s.tg.length > len(r.data) {
+		return nil, false, r.f.decodeError(fmt.Errorf("field:%s data is not enough accumulate length(%d) data(%d)", s.tg.name, r.idx+s.tg.length, len(r.data)))
+	}
+	val = r.data[r.idx : r.idx+s.tg.length]
+	r.idx += s.tg.length
+	return val, true, nil
+}
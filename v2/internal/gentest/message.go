@@ -0,0 +1,52 @@
+//Package gentest holds messages marshaled by code generated with iso8583gen,
+//the generated test checks it against the reflective path
+package gentest
+
+//go:generate go run github.com/henglory/iso8583/v2/cmd/iso8583gen -type Message,Reversal
+
+//Code is a named string field type
+type Code string
+
+//Additional is a fixed width field using a bitmap
+type Additional struct {
+	Terminal string   `field:"1" length:"8"`
+	Batch    int      `field:"2" length:"6"`
+	Rate     *float64 `field:"3" length:"5"`
+	Raw      []byte   `field:"4" length:"4"`
+}
+
+//Private is a fixed width field without a bitmap
+type Private struct {
+	Name    string `field:"1" length:"10" cp:"ibm037"`
+	Counter int16  `field:"2" length:"3"`
+}
+
+//Message covers the field types and encodings the generator supports
+type Message struct {
+	Mti         string      `encode:"bcd"`
+	Pan         string      `field:"2" type:"llvar" length:"19"`
+	ProcCode    Code        `field:"3" type:"numeric" encode:"bcd" length:"6"`
+	Amount      int64       `field:"4" type:"numeric" encode:"rbcd" length:"12"`
+	Rate        float64     `field:"9" type:"numeric" length:"8"`
+	Stan        *int        `field:"11" type:"numeric" length:"6"`
+	Track       *string     `field:"35" type:"llvar" encode:"bcd,ascii"`
+	Terminal    string      `field:"41" type:"alpha" length:"8"`
+	Merchant    string      `field:"43" type:"alpha" length:"40" cp:"ibm037"`
+	Additional  Additional  `field:"48" type:"lllvar" bitmapsize:"1"`
+	Pin         []byte      `field:"52" type:"binary" length:"8"`
+	Private     *Private    `field:"62" type:"llvar"`
+	Network     int32       `field:"70" type:"numeric" length:"3"`
+	Reserved    string      `field:"120" type:"lllvar" cp:"hexstring"`
+	Ignored     string
+	NotAnIsoTag string `json:"note"`
+}
+
+//Reversal is a small message with an ascii mti, fields without a type come from the 1993 dictionary
+type Reversal struct {
+	MTI      string `dict:"1993"`
+	Pan      string `field:"2" type:"llvar"`
+	Stan     int    `field:"11"`
+	Action   string `field:"39"`
+	Ref      []byte `field:"37" type:"alpha" length:"8" pad:"left,*"`
+	Original []byte `field:"90" type:"lllvar"`
+}
@@ -0,0 +1,405 @@
+// Code generated by iso8583gen -type Message,Reversal; DO NOT EDIT.
+
+package gentest
+
+import iso8583v2 "github.com/henglory/iso8583/v2"
+
+var (
+	_iso8583Message_Mti                 = iso8583v2.MustGenField("Mti", "encode:\"bcd\"")
+	_iso8583Message_Pan                 = iso8583v2.MustGenField("Pan", "field:\"2\" type:\"llvar\" length:\"19\"")
+	_iso8583Message_ProcCode            = iso8583v2.MustGenField("ProcCode", "field:\"3\" type:\"numeric\" encode:\"bcd\" length:\"6\"")
+	_iso8583Message_Amount              = iso8583v2.MustGenField("Amount", "field:\"4\" type:\"numeric\" encode:\"rbcd\" length:\"12\"")
+	_iso8583Message_Rate                = iso8583v2.MustGenField("Rate", "field:\"9\" type:\"numeric\" length:\"8\"")
+	_iso8583Message_Stan                = iso8583v2.MustGenField("Stan", "field:\"11\" type:\"numeric\" length:\"6\"")
+	_iso8583Message_Track               = iso8583v2.MustGenField("Track", "field:\"35\" type:\"llvar\" encode:\"bcd,ascii\"")
+	_iso8583Message_Terminal            = iso8583v2.MustGenField("Terminal", "field:\"41\" type:\"alpha\" length:\"8\"")
+	_iso8583Message_Merchant            = iso8583v2.MustGenField("Merchant", "field:\"43\" type:\"alpha\" length:\"40\" cp:\"ibm037\"")
+	_iso8583Message_Additional          = iso8583v2.MustGenField("Additional", "field:\"48\" type:\"lllvar\" bitmapsize:\"1\"")
+	_iso8583Message_Additional_Terminal = iso8583v2.MustGenSubfield(_iso8583Message_Additional, "Terminal", "field:\"1\" length:\"8\"")
+	_iso8583Message_Additional_Batch    = iso8583v2.MustGenSubfield(_iso8583Message_Additional, "Batch", "field:\"2\" length:\"6\"")
+	_iso8583Message_Additional_Rate     = iso8583v2.MustGenSubfield(_iso8583Message_Additional, "Rate", "field:\"3\" length:\"5\"")
+	_iso8583Message_Additional_Raw      = iso8583v2.MustGenSubfield(_iso8583Message_Additional, "Raw", "field:\"4\" length:\"4\"")
+	_iso8583Message_Pin                 = iso8583v2.MustGenField("Pin", "field:\"52\" type:\"binary\" length:\"8\"")
+	_iso8583Message_Private             = iso8583v2.MustGenField("Private", "field:\"62\" type:\"llvar\"")
+	_iso8583Message_Private_Name        = iso8583v2.MustGenSubfield(_iso8583Message_Private, "Name", "field:\"1\" length:\"10\" cp:\"ibm037\"")
+	_iso8583Message_Private_Counter     = iso8583v2.MustGenSubfield(_iso8583Message_Private, "Counter", "field:\"2\" length:\"3\"")
+	_iso8583Message_Network             = iso8583v2.MustGenField("Network", "field:\"70\" type:\"numeric\" length:\"3\"")
+	_iso8583Message_Reserved            = iso8583v2.MustGenField("Reserved", "field:\"120\" type:\"lllvar\" cp:\"hexstring\"")
+)
+
+// MarshalISO8583 encodes v without reflection
+func (v Message) MarshalISO8583() ([]byte, error) {
+	m := iso8583v2.NewGenMessage()
+	b, err := _iso8583Message_Mti.EncodeMTI(string(v.Mti))
+	if err != nil {
+		return nil, err
+	}
+	m.SetMTI(b)
+	if b, err = _iso8583Message_Pan.EncodeString(string(v.Pan)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Message_Pan, b)
+	if b, err = _iso8583Message_ProcCode.EncodeString(string(v.ProcCode)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Message_ProcCode, b)
+	if b, err = _iso8583Message_Amount.EncodeInt(int64(v.Amount)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Message_Amount, b)
+	if b, err = _iso8583Message_Rate.EncodeFloat(float64(v.Rate), 64); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Message_Rate, b)
+	if v.Stan != nil {
+		if b, err = _iso8583Message_Stan.EncodeInt(int64(*v.Stan)); err != nil {
+			return nil, err
+		}
+		m.Add(_iso8583Message_Stan, b)
+	}
+	if v.Track != nil {
+		if b, err = _iso8583Message_Track.EncodeString(string(*v.Track)); err != nil {
+			return nil, err
+		}
+		m.Add(_iso8583Message_Track, b)
+	}
+	if b, err = _iso8583Message_Terminal.EncodeString(string(v.Terminal)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Message_Terminal, b)
+	if b, err = _iso8583Message_Merchant.EncodeString(string(v.Merchant)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Message_Merchant, b)
+	{
+		s := _iso8583Message_Additional.NewStruct()
+		if b, err = _iso8583Message_Additional_Terminal.EncodeString(string(v.Additional.Terminal)); err != nil {
+			return nil, err
+		}
+		s.Add(_iso8583Message_Additional_Terminal, b)
+		if b, err = _iso8583Message_Additional_Batch.EncodeInt(int64(v.Additional.Batch)); err != nil {
+			return nil, err
+		}
+		s.Add(_iso8583Message_Additional_Batch, b)
+		if v.Additional.Rate != nil {
+			if b, err = _iso8583Message_Additional_Rate.EncodeFloat(float64(*v.Additional.Rate), 64); err != nil {
+				return nil, err
+			}
+			s.Add(_iso8583Message_Additional_Rate, b)
+		}
+		if b, err = _iso8583Message_Additional_Raw.EncodeBytes([]byte(v.Additional.Raw)); err != nil {
+			return nil, err
+		}
+		s.Add(_iso8583Message_Additional_Raw, b)
+		if b, err = s.Bytes(); err != nil {
+			return nil, err
+		}
+		m.Add(_iso8583Message_Additional, b)
+	}
+	if b, err = _iso8583Message_Pin.EncodeBytes([]byte(v.Pin)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Message_Pin, b)
+	if v.Private != nil {
+		s := _iso8583Message_Private.NewStruct()
+		if b, err = _iso8583Message_Private_Name.EncodeString(string(v.Private.Name)); err != nil {
+			return nil, err
+		}
+		s.Add(_iso8583Message_Private_Name, b)
+		if b, err = _iso8583Message_Private_Counter.EncodeInt(int64(v.Private.Counter)); err != nil {
+			return nil, err
+		}
+		s.Add(_iso8583Message_Private_Counter, b)
+		if b, err = s.Bytes(); err != nil {
+			return nil, err
+		}
+		m.Add(_iso8583Message_Private, b)
+	}
+	if b, err = _iso8583Message_Network.EncodeInt(int64(v.Network)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Message_Network, b)
+	if b, err = _iso8583Message_Reserved.EncodeString(string(v.Reserved)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Message_Reserved, b)
+	return m.Bytes()
+}
+
+// UnmarshalISO8583 decodes data into v without reflection
+func (v *Message) UnmarshalISO8583(data []byte) error {
+	r, mti, err := iso8583v2.NewGenReader(data, _iso8583Message_Mti)
+	if err != nil {
+		return err
+	}
+	v.Mti = string(mti)
+	var val []byte
+	var ok bool
+	if val, ok, err = r.Next(_iso8583Message_Pan); err != nil {
+		return err
+	} else if ok {
+		x, err := _iso8583Message_Pan.DecodeString(val)
+		if err != nil {
+			return err
+		}
+		v.Pan = string(x)
+	}
+	if val, ok, err = r.Next(_iso8583Message_ProcCode); err != nil {
+		return err
+	} else if ok {
+		x, err := _iso8583Message_ProcCode.DecodeString(val)
+		if err != nil {
+			return err
+		}
+		v.ProcCode = Code(x)
+	}
+	if val, ok, err = r.Next(_iso8583Message_Amount); err != nil {
+		return err
+	} else if ok {
+		i, err := _iso8583Message_Amount.DecodeInt(val)
+		if err != nil {
+			return err
+		}
+		v.Amount = int64(i)
+	}
+	if val, ok, err = r.Next(_iso8583Message_Rate); err != nil {
+		return err
+	} else if ok {
+		fl, err := _iso8583Message_Rate.DecodeFloat(val, 64)
+		if err != nil {
+			return err
+		}
+		v.Rate = float64(fl)
+	}
+	if val, ok, err = r.Next(_iso8583Message_Stan); err != nil {
+		return err
+	} else if ok {
+		if v.Stan == nil {
+			v.Stan = new(int)
+		}
+		i, err := _iso8583Message_Stan.DecodeInt(val)
+		if err != nil {
+			return err
+		}
+		*v.Stan = int(i)
+	}
+	if val, ok, err = r.Next(_iso8583Message_Track); err != nil {
+		return err
+	} else if ok {
+		if v.Track == nil {
+			v.Track = new(string)
+		}
+		x, err := _iso8583Message_Track.DecodeString(val)
+		if err != nil {
+			return err
+		}
+		*v.Track = string(x)
+	}
+	if val, ok, err = r.Next(_iso8583Message_Terminal); err != nil {
+		return err
+	} else if ok {
+		x, err := _iso8583Message_Terminal.DecodeString(val)
+		if err != nil {
+			return err
+		}
+		v.Terminal = string(x)
+	}
+	if val, ok, err = r.Next(_iso8583Message_Merchant); err != nil {
+		return err
+	} else if ok {
+		x, err := _iso8583Message_Merchant.DecodeString(val)
+		if err != nil {
+			return err
+		}
+		v.Merchant = string(x)
+	}
+	if val, ok, err = r.Next(_iso8583Message_Additional); err != nil {
+		return err
+	} else if ok {
+		s, err := _iso8583Message_Additional.NewStructReader(val)
+		if err != nil {
+			return err
+		}
+		if val, ok, err := s.Next(_iso8583Message_Additional_Terminal); err != nil {
+			return err
+		} else if ok {
+			x, err := _iso8583Message_Additional_Terminal.DecodeString(val)
+			if err != nil {
+				return err
+			}
+			v.Additional.Terminal = string(x)
+		}
+		if val, ok, err := s.Next(_iso8583Message_Additional_Batch); err != nil {
+			return err
+		} else if ok {
+			if i, ok, err := _iso8583Message_Additional_Batch.DecodeInt(val); err != nil {
+				return err
+			} else if ok {
+				v.Additional.Batch = int(i)
+			}
+		}
+		if val, ok, err := s.Next(_iso8583Message_Additional_Rate); err != nil {
+			return err
+		} else if ok {
+			if v.Additional.Rate == nil {
+				v.Additional.Rate = new(float64)
+			}
+			if fl, ok, err := _iso8583Message_Additional_Rate.DecodeFloat(val, 64); err != nil {
+				return err
+			} else if ok {
+				*v.Additional.Rate = float64(fl)
+			}
+		}
+		if val, ok, err := s.Next(_iso8583Message_Additional_Raw); err != nil {
+			return err
+		} else if ok {
+			x, err := _iso8583Message_Additional_Raw.DecodeBytes(val)
+			if err != nil {
+				return err
+			}
+			v.Additional.Raw = []byte(x)
+		}
+	}
+	if val, ok, err = r.Next(_iso8583Message_Pin); err != nil {
+		return err
+	} else if ok {
+		v.Pin = []byte(_iso8583Message_Pin.DecodeBytes(val))
+	}
+	if val, ok, err = r.Next(_iso8583Message_Private); err != nil {
+		return err
+	} else if ok {
+		if v.Private == nil {
+			v.Private = new(Private)
+		}
+		s, err := _iso8583Message_Private.NewStructReader(val)
+		if err != nil {
+			return err
+		}
+		if val, ok, err := s.Next(_iso8583Message_Private_Name); err != nil {
+			return err
+		} else if ok {
+			x, err := _iso8583Message_Private_Name.DecodeString(val)
+			if err != nil {
+				return err
+			}
+			v.Private.Name = string(x)
+		}
+		if val, ok, err := s.Next(_iso8583Message_Private_Counter); err != nil {
+			return err
+		} else if ok {
+			if i, ok, err := _iso8583Message_Private_Counter.DecodeInt(val); err != nil {
+				return err
+			} else if ok {
+				v.Private.Counter = int16(i)
+			}
+		}
+	}
+	if val, ok, err = r.Next(_iso8583Message_Network); err != nil {
+		return err
+	} else if ok {
+		i, err := _iso8583Message_Network.DecodeInt(val)
+		if err != nil {
+			return err
+		}
+		v.Network = int32(i)
+	}
+	if val, ok, err = r.Next(_iso8583Message_Reserved); err != nil {
+		return err
+	} else if ok {
+		x, err := _iso8583Message_Reserved.DecodeString(val)
+		if err != nil {
+			return err
+		}
+		v.Reserved = string(x)
+	}
+	return nil
+}
+
+var (
+	_iso8583Reversal_MTI      = iso8583v2.MustGenField("MTI", "dict:\"1993\"")
+	_iso8583Reversal_Pan      = iso8583v2.MustGenFieldDict("1993", "Pan", "field:\"2\" type:\"llvar\"")
+	_iso8583Reversal_Stan     = iso8583v2.MustGenFieldDict("1993", "Stan", "field:\"11\"")
+	_iso8583Reversal_Action   = iso8583v2.MustGenFieldDict("1993", "Action", "field:\"39\"")
+	_iso8583Reversal_Ref      = iso8583v2.MustGenFieldDict("1993", "Ref", "field:\"37\" type:\"alpha\" length:\"8\" pad:\"left,*\"")
+	_iso8583Reversal_Original = iso8583v2.MustGenFieldDict("1993", "Original", "field:\"90\" type:\"lllvar\"")
+)
+
+// MarshalISO8583 encodes v without reflection
+func (v Reversal) MarshalISO8583() ([]byte, error) {
+	m := iso8583v2.NewGenMessage()
+	b, err := _iso8583Reversal_MTI.EncodeMTI(string(v.MTI))
+	if err != nil {
+		return nil, err
+	}
+	m.SetMTI(b)
+	if b, err = _iso8583Reversal_Pan.EncodeString(string(v.Pan)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Reversal_Pan, b)
+	if b, err = _iso8583Reversal_Stan.EncodeInt(int64(v.Stan)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Reversal_Stan, b)
+	if b, err = _iso8583Reversal_Action.EncodeString(string(v.Action)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Reversal_Action, b)
+	if b, err = _iso8583Reversal_Ref.EncodeBytes([]byte(v.Ref)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Reversal_Ref, b)
+	if b, err = _iso8583Reversal_Original.EncodeBytes([]byte(v.Original)); err != nil {
+		return nil, err
+	}
+	m.Add(_iso8583Reversal_Original, b)
+	return m.Bytes()
+}
+
+// UnmarshalISO8583 decodes data into v without reflection
+func (v *Reversal) UnmarshalISO8583(data []byte) error {
+	r, mti, err := iso8583v2.NewGenReader(data, _iso8583Reversal_MTI)
+	if err != nil {
+		return err
+	}
+	v.MTI = string(mti)
+	var val []byte
+	var ok bool
+	if val, ok, err = r.Next(_iso8583Reversal_Pan); err != nil {
+		return err
+	} else if ok {
+		x, err := _iso8583Reversal_Pan.DecodeString(val)
+		if err != nil {
+			return err
+		}
+		v.Pan = string(x)
+	}
+	if val, ok, err = r.Next(_iso8583Reversal_Stan); err != nil {
+		return err
+	} else if ok {
+		i, err := _iso8583Reversal_Stan.DecodeInt(val)
+		if err != nil {
+			return err
+		}
+		v.Stan = int(i)
+	}
+	if val, ok, err = r.Next(_iso8583Reversal_Action); err != nil {
+		return err
+	} else if ok {
+		x, err := _iso8583Reversal_Action.DecodeString(val)
+		if err != nil {
+			return err
+		}
+		v.Action = string(x)
+	}
+	if val, ok, err = r.Next(_iso8583Reversal_Ref); err != nil {
+		return err
+	} else if ok {
+		v.Ref = []byte(_iso8583Reversal_Ref.DecodeBytes(val))
+	}
+	if val, ok, err = r.Next(_iso8583Reversal_Original); err != nil {
+		return err
+	} else if ok {
+		v.Original = []byte(_iso8583Reversal_Original.DecodeBytes(val))
+	}
+	return nil
+}
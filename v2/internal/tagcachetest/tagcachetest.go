@@ -0,0 +1,14 @@
+//Package tagcachetest declares types named like the iso8583v2 test types
+//but with another layout, so the tag cache can be tested across packages
+package tagcachetest
+
+//T48 shares its name with the sub field struct of the iso8583v2 tests
+type T48 struct {
+	T1 string `field:"1" length:"3"`
+}
+
+//TestIso shares its name with the message struct of the iso8583v2 tests
+type TestIso struct {
+	Mti string
+	T   T48 `field:"48" type:"llvar"`
+}
@@ -1,19 +1,78 @@
 package iso8583v2
 
 import (
+	"reflect"
 	"sync"
-	"sync/atomic"
 )
 
 var (
-	tagCache      map[string]atomic.Value
-	fixedTagCache map[string]atomic.Value
-
-	tagLock      sync.RWMutex
-	fixedTagLock sync.RWMutex
+	//reflect.Type -> map[string]*iso8583Tag
+	tagCache sync.Map
+	//reflect.Type -> map[string]*fixedwidthTag
+	fixedTagCache sync.Map
+	//dictionaryKey -> map[string]*iso8583Tag
+	dictionaryTagCache sync.Map
+	//reflect.Type -> map[string]*tlvTag
+	tlvTagCache sync.Map
 )
 
-func init() {
-	tagCache = make(map[string]atomic.Value)
-	fixedTagCache = make(map[string]atomic.Value)
+type dictionaryKey struct {
+	typ  reflect.Type
+	dict *Dictionary
+}
+
+func cachedTag(t reflect.Type) map[string]*iso8583Tag {
+	if tag, ok := tagCache.Load(t); ok {
+		return tag.(map[string]*iso8583Tag)
+	}
+	tag, _ := tagCache.LoadOrStore(t, loadTag(t))
+	return tag.(map[string]*iso8583Tag)
+}
+
+//cachedOptionTag returns the tags of t with the dictionary of opt, if any.
+//Only the tags of a registered dictionary are cached, an overlay built per connection would never be released
+func cachedOptionTag(t reflect.Type, opt options) map[string]*iso8583Tag {
+	if opt.dict == nil {
+		return cachedTag(t)
+	}
+	if !opt.dict.registered() {
+		return loadTagWith(t, opt.dict)
+	}
+	key := dictionaryKey{typ: t, dict: opt.dict}
+	if tag, ok := dictionaryTagCache.Load(key); ok {
+		return tag.(map[string]*iso8583Tag)
+	}
+	tag, _ := dictionaryTagCache.LoadOrStore(key, loadTagWith(t, opt.dict))
+	return tag.(map[string]*iso8583Tag)
+}
+
+func cachedFixedwidthTag(t reflect.Type) map[string]*fixedwidthTag {
+	if tag, ok := fixedTagCache.Load(t); ok {
+		return tag.(map[string]*fixedwidthTag)
+	}
+	tag, _ := fixedTagCache.LoadOrStore(t, loadFixedwidthTag(t))
+	return tag.(map[string]*fixedwidthTag)
+}
+
+//maxPooledBuffer keeps one very large message from pinning its buffer in the pool
+const maxPooledBuffer = 64 * 1024
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+func getBuffer() *[]byte {
+	bp := bufferPool.Get().(*[]byte)
+	*bp = (*bp)[:0]
+	return bp
+}
+
+func putBuffer(bp *[]byte) {
+	if cap(*bp) > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(bp)
 }
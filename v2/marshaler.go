@@ -0,0 +1,133 @@
+package iso8583v2
+
+import (
+	"encoding"
+	"reflect"
+)
+
+//FieldMarshaler is implemented by types that can produce their own field value.
+//The returned bytes are the raw value, the library still applies the type,
+//length, padding and code page configured on the tag
+type FieldMarshaler interface {
+	MarshalISO8583Field(tag FieldTag) ([]byte, error)
+}
+
+//FieldUnmarshaler is implemented by types that can load themselves from a field value.
+//data is the field value after the length prefix and code page are removed,
+//it must be copied if it is kept after returning
+type FieldUnmarshaler interface {
+	UnmarshalISO8583Field(tag FieldTag, data []byte) error
+}
+
+//FieldTag describes the field being marshaled or unmarshaled,
+//Type is mti for the message type indicator
+type FieldTag struct {
+	Name         string
+	Field        int
+	Length       int
+	Type         string
+	LengthEncode string
+	ValueEncode  string
+	Codepage     string
+	Fixedwidth   bool
+}
+
+var (
+	fieldMarshalerType   = reflect.TypeOf((*FieldMarshaler)(nil)).Elem()
+	fieldUnmarshalerType = reflect.TypeOf((*FieldUnmarshaler)(nil)).Elem()
+	textMarshalerType    = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func (t iso8583Tag) export() FieldTag {
+	if t.isMti {
+		return FieldTag{Name: t.name, Type: mtiWord, ValueEncode: t.valEncode.value()}
+	}
+	return FieldTag{
+		Name:         t.name,
+		Field:        t.field,
+		Length:       t.length,
+		Type:         t.fieldType.value(),
+		LengthEncode: t.lenEncode.value(),
+		ValueEncode:  t.valEncode.value(),
+		Codepage:     t.codePage.value(),
+	}
+}
+
+func (t fixedwidthTag) export() FieldTag {
+	return FieldTag{
+		Name:       t.name,
+		Field:      t.field,
+		Length:     t.length,
+		Codepage:   t.codePage.value(),
+		Fixedwidth: true,
+	}
+}
+
+//implements reports whether typ or a pointer to typ implements iface
+func implements(typ reflect.Type, iface reflect.Type) bool {
+	if typ.Implements(iface) {
+		return true
+	}
+	return typ.Kind() != reflect.Ptr && reflect.PtrTo(typ).Implements(iface)
+}
+
+//marshalerValue returns v as iface, taking the address of a copy when the
+//method has a pointer receiver and v is not addressable
+func marshalerValue(v reflect.Value, iface reflect.Type) (interface{}, bool) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, false
+	}
+	if !v.CanInterface() {
+		return nil, false
+	}
+	if v.Type().Implements(iface) {
+		return v.Interface(), true
+	}
+	if v.Kind() == reflect.Ptr || !reflect.PtrTo(v.Type()).Implements(iface) {
+		return nil, false
+	}
+	if !v.CanAddr() {
+		pv := reflect.New(v.Type())
+		pv.Elem().Set(v)
+		v = pv.Elem()
+	}
+	return v.Addr().Interface(), true
+}
+
+//unmarshalerValue returns the address of v when it implements
+//FieldUnmarshaler or encoding.TextUnmarshaler
+func unmarshalerValue(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Ptr || !v.CanAddr() || !v.CanInterface() {
+		return nil
+	}
+	pv := v.Addr()
+	if pv.Type().Implements(fieldUnmarshalerType) || pv.Type().Implements(textUnmarshalerType) {
+		return pv.Interface()
+	}
+	return nil
+}
+
+//marshalValue calls the field or text marshaler of v, ok is false for nil pointers
+func marshalValue(v reflect.Value, tag FieldTag) (b []byte, ok bool, err error) {
+	if m, isField := marshalerValue(v, fieldMarshalerType); isField {
+		b, err = m.(FieldMarshaler).MarshalISO8583Field(tag)
+		return b, true, err
+	}
+	if m, isText := marshalerValue(v, textMarshalerType); isText {
+		b, err = m.(encoding.TextMarshaler).MarshalText()
+		return b, true, err
+	}
+	return nil, false, nil
+}
+
+//unmarshalValue calls the field or text unmarshaler of u
+func unmarshalValue(u interface{}, tag FieldTag, data []byte) error {
+	switch um := u.(type) {
+	case FieldUnmarshaler:
+		return um.UnmarshalISO8583Field(tag, data)
+	case encoding.TextUnmarshaler:
+		return um.UnmarshalText(data)
+	}
+	return nil
+}
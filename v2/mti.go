@@ -0,0 +1,171 @@
+package iso8583v2
+
+import (
+	"fmt"
+)
+
+//MTIVersion is the first digit of a message type indicator
+type MTIVersion uint8
+
+//MTIClass is the second digit of a message type indicator
+type MTIClass uint8
+
+//MTIFunction is the third digit of a message type indicator
+type MTIFunction uint8
+
+//MTIOrigin is the fourth digit of a message type indicator
+type MTIOrigin uint8
+
+//ISO 8583 versions, 8 and 9 are for national and private use
+const (
+	Version1987     MTIVersion = 0
+	Version1993     MTIVersion = 1
+	Version2003     MTIVersion = 2
+	VersionNational MTIVersion = 8
+	VersionPrivate  MTIVersion = 9
+)
+
+//Message classes
+const (
+	ClassAuthorization     MTIClass = 1
+	ClassFinancial         MTIClass = 2
+	ClassFileActions       MTIClass = 3
+	ClassReversal          MTIClass = 4
+	ClassReconciliation    MTIClass = 5
+	ClassAdministrative    MTIClass = 6
+	ClassFeeCollection     MTIClass = 7
+	ClassNetworkManagement MTIClass = 8
+)
+
+//Message functions, even functions expect an answer and odd ones answer them
+const (
+	FunctionRequest         MTIFunction = 0
+	FunctionRequestResponse MTIFunction = 1
+	FunctionAdvice          MTIFunction = 2
+	FunctionAdviceResponse  MTIFunction = 3
+	FunctionNotification    MTIFunction = 4
+	FunctionNotificationAck MTIFunction = 5
+	FunctionInstruction     MTIFunction = 6
+	FunctionInstructionAck  MTIFunction = 7
+)
+
+//Message origins, odd origins are repeats
+const (
+	OriginAcquirer       MTIOrigin = 0
+	OriginAcquirerRepeat MTIOrigin = 1
+	OriginIssuer         MTIOrigin = 2
+	OriginIssuerRepeat   MTIOrigin = 3
+	OriginOther          MTIOrigin = 4
+	OriginOtherRepeat    MTIOrigin = 5
+)
+
+//MTI is a message type indicator split into its four digits.
+//It can be the mti field of a message in place of a string,
+//the zero MTI is treated as an empty mti by Marshal
+type MTI struct {
+	Version  MTIVersion
+	Class    MTIClass
+	Function MTIFunction
+	Origin   MTIOrigin
+}
+
+//ParseMTI parses four digits such as 0200
+func ParseMTI(s string) (MTI, error) {
+	if len(s) != 4 || !isDigits(s) {
+		return MTI{}, fmt.Errorf("MTI %q must be 4 digits", s)
+	}
+	return MTI{
+		Version:  MTIVersion(s[0] - '0'),
+		Class:    MTIClass(s[1] - '0'),
+		Function: MTIFunction(s[2] - '0'),
+		Origin:   MTIOrigin(s[3] - '0'),
+	}, nil
+}
+
+//MustParseMTI is ParseMTI for constants, it panics when s is not 4 digits
+func MustParseMTI(s string) MTI {
+	m, err := ParseMTI(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (m MTI) String() string {
+	return string([]byte{'0' + byte(m.Version), '0' + byte(m.Class), '0' + byte(m.Function), '0' + byte(m.Origin)})
+}
+
+//IsRequest reports whether m expects a response or acknowledgement,
+//that is a request, advice, notification or instruction
+func (m MTI) IsRequest() bool {
+	return m.Function%2 == 0
+}
+
+//IsResponse reports whether m answers a request
+func (m MTI) IsResponse() bool {
+	return m.Function%2 == 1
+}
+
+//IsAdvice reports whether m is an advice or an advice response
+func (m MTI) IsAdvice() bool {
+	return m.Function == FunctionAdvice || m.Function == FunctionAdviceResponse
+}
+
+//IsRepeat reports whether m is sent again after a missing response
+func (m MTI) IsRepeat() bool {
+	return m.Origin%2 == 1
+}
+
+//RepeatMTI returns the repeat of m, 0200 becomes 0201
+func (m MTI) RepeatMTI() (MTI, error) {
+	if !m.IsRequest() {
+		return m, fmt.Errorf("MTI %s is a response and cannot be repeated", m)
+	}
+	m.Origin |= 1
+	return m, nil
+}
+
+//ResponseMTI returns the response to m, 0200 and 0201 become 0210
+func (m MTI) ResponseMTI() (MTI, error) {
+	if !m.IsRequest() {
+		return m, fmt.Errorf("MTI %s is already a response", m)
+	}
+	m.Function++
+	m.Origin &^= 1
+	return m, nil
+}
+
+//ReversalMTI returns the reversal of an authorization or financial request,
+//0100 and 0200 become 0400, 0120 and 0220 become 0420
+func (m MTI) ReversalMTI() (MTI, error) {
+	if m.Class != ClassAuthorization && m.Class != ClassFinancial {
+		return m, fmt.Errorf("MTI %s is not an authorization or financial message", m)
+	}
+	if !m.IsRequest() {
+		return m, fmt.Errorf("MTI %s is a response and cannot be reversed", m)
+	}
+	m.Class = ClassReversal
+	m.Origin &^= 1
+	return m, nil
+}
+
+//MarshalText returns the four digits, the zero MTI is empty
+func (m MTI) MarshalText() ([]byte, error) {
+	if m == (MTI{}) {
+		return nil, nil
+	}
+	if m.Version > 9 || m.Class > 9 || m.Function > 9 || m.Origin > 9 {
+		return nil, fmt.Errorf("MTI digits must be between 0 and 9, got %d%d%d%d", m.Version, m.Class, m.Function, m.Origin)
+	}
+	return []byte(m.String()), nil
+}
+
+//UnmarshalText parses the four digits of text
+func (m *MTI) UnmarshalText(text []byte) error {
+	parsed, err := ParseMTI(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,93 @@
+package iso8583v2
+
+//Option changes how a message is marshaled or unmarshaled
+type Option func(*options)
+
+type options struct {
+	zeroCopy        bool
+	ignoreGenerated bool
+	validateTags    bool
+	dict            *Dictionary
+	presence        *PresenceRules
+	strict          bool
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+//ZeroCopy makes Unmarshal point []byte fields into the input data instead of copying it.
+//The fields are only valid while data is neither modified nor reused,
+//values converted on decode such as bcd or code page fields are still new slices
+func ZeroCopy() Option {
+	return func(o *options) {
+		o.zeroCopy = true
+	}
+}
+
+//IgnoreGenerated makes Marshal and Unmarshal use reflection even when the value
+//has MarshalISO8583 or UnmarshalISO8583 methods generated by iso8583gen
+func IgnoreGenerated() Option {
+	return func(o *options) {
+		o.ignoreGenerated = true
+	}
+}
+
+//ValidateTags makes Marshal and Unmarshal fail with a *ValidationError when the message type
+//has tags they would otherwise ignore, the type is checked once and the result is cached
+func ValidateTags() Option {
+	return func(o *options) {
+		o.validateTags = true
+	}
+}
+
+//UseDictionary makes Marshal and Unmarshal resolve untyped tags with d instead of the dictionary
+//the mti tag names, so each connection can use its own overlay of the same message struct.
+//Generated methods are not used since they are built with the dictionary of the tag.
+//The tags are cached only for a registered dictionary, an unregistered overlay loads them on every call
+func UseDictionary(d *Dictionary) Option {
+	return func(o *options) {
+		o.dict = d
+	}
+}
+
+//EnforcePresence makes Marshal fail and Unmarshal report a *PresenceError when a message lacks
+//the fields its mti requires by r. Unmarshal still decodes the whole message before it reports.
+//Generated methods are not used since they do not build a bitmap to check
+func EnforcePresence(r *PresenceRules) Option {
+	return func(o *options) {
+		o.presence = r
+	}
+}
+
+//Strict makes Marshal and Unmarshal check the characters of field values against their ISO attribute class,
+//given by the class tag or the dictionary, numeric fields are n and alpha fields ans otherwise.
+//Fixed width sub fields are checked when their tag has a class.
+//Code page conversion of fields without a cppolicy tag fails instead of replacing characters.
+//Generated methods are not used since they do not check classes
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+//useGenerated reports whether generated methods may replace reflection
+func (o options) useGenerated() bool {
+	return !o.ignoreGenerated && o.dict == nil && o.presence == nil && !o.strict
+}
+
+//bytesValue returns b itself in zero copy mode and a copy of b otherwise
+func (o options) bytesValue(b []byte) []byte {
+	if o.zeroCopy {
+		return b
+	}
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
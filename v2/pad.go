@@ -0,0 +1,137 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"reflect"
+)
+
+var (
+	numericPad = padRule{side: padLeft, char: '0'}
+	alphaPad   = padRule{side: padRight, char: ' '}
+)
+
+func (p padRule) isSet() bool {
+	return p.side != padDefault
+}
+
+//or returns p with the side and character of def where p leaves them out
+func (p padRule) or(def padRule) padRule {
+	if p.side == padDefault {
+		p.side = def.side
+	}
+	if p.char == 0 {
+		p.char = def.char
+	}
+	return p
+}
+
+//fill pads b to length with the character of p in code page cp
+func (p padRule) fill(b []byte, length int, cp codepageType) ([]byte, error) {
+	return p.appendFill(b, 0, length, cp)
+}
+
+//appendFill pads the value at dst[start:] in place to length with the character of p in code page cp
+func (p padRule) appendFill(dst []byte, start int, length int, cp codepageType) ([]byte, error) {
+	n := length - (len(dst) - start)
+	if n <= 0 {
+		return dst, nil
+	}
+	if v := cp.value(); v != "" && v != string(hexstring) {
+		pad, err := padding(v, string(p.char), n)
+		if err != nil {
+			return nil, err
+		}
+		if p.side == padLeft {
+			return insertBytes(dst, start, pad), nil
+		}
+		return append(dst, pad...), nil
+	}
+	end := len(dst)
+	for i := 0; i < n; i++ {
+		dst = append(dst, p.char)
+	}
+	if p.side == padLeft {
+		copy(dst[start+n:], dst[start:end])
+		for i := start; i < start+n; i++ {
+			dst[i] = p.char
+		}
+	}
+	return dst, nil
+}
+
+//insertBytes inserts b at dst[start]
+func insertBytes(dst []byte, start int, b []byte) []byte {
+	end := len(dst)
+	dst = append(dst, b...)
+	copy(dst[start+len(b):], dst[start:end])
+	copy(dst[start:], b)
+	return dst
+}
+
+//appendZeros appends n zero bytes to dst
+func appendZeros(dst []byte, n int) []byte {
+	for ; n > 0; n-- {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+//strip removes the padding of a pad tag from a decoded value, without a pad tag b is kept as is.
+//def gives the character a pad tag leaves out, fold matches it in either case for values decoded from bcd.
+//A digit pad character is only stripped from a number, other values keep it since
+//it could not be told from the digits of the value
+func (p padRule) strip(b []byte, def padRule, fold bool, number bool) []byte {
+	if !p.isSet() {
+		return b
+	}
+	p = p.or(def)
+	if !number && p.char >= '0' && p.char <= '9' {
+		return b
+	}
+	match := func(c byte) bool {
+		if fold {
+			return bytes.EqualFold([]byte{c}, []byte{p.char})
+		}
+		return c == p.char
+	}
+	if p.side == padLeft {
+		for len(b) > 0 && match(b[0]) {
+			b = b[1:]
+		}
+		return b
+	}
+	for len(b) > 0 && match(b[len(b)-1]) {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+//isPadNumberKind reports whether decode parses a value of kind as a number
+func isPadNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8, reflect.Float64, reflect.Float32:
+		return true
+	}
+	return false
+}
+
+//defaultPad is the padding of t without a pad tag, 0 on the left of numeric fields and spaces on the right otherwise
+func (t iso8583Tag) defaultPad() padRule {
+	if t.fieldType == numeric {
+		return numericPad
+	}
+	return alphaPad
+}
+
+//subfieldPad is the padding of a sub field value of kind without a pad tag
+func subfieldPad(kind reflect.Kind) padRule {
+	if isPadNumberKind(kind) {
+		return numericPad
+	}
+	return alphaPad
+}
+
+//stripPad removes the padding of t from a decoded value, number tells whether the value is decoded as a number
+func (t iso8583Tag) stripPad(b []byte, number bool) []byte {
+	return t.pad.strip(b, t.defaultPad(), t.valEncode == bcd || t.valEncode == rbcd, number)
+}
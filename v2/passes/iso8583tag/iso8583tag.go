@@ -0,0 +1,260 @@
+//Package iso8583tag defines an analyzer that validates the struct tags of iso8583v2 messages.
+//
+//Marshal drops fields whose tags fail to parse, so a typo such as type:"llvr"
+//makes the field disappear from the message. The analyzer reports those tags,
+//numeric, alpha and binary fields without a length, duplicate field numbers,
+//encodings the field type cannot use and Go types the encoder does not support.
+//
+//A struct is checked as a message when it has an exported mti field,
+//the structs of its struct fields are checked as fixed width sub fields
+package iso8583tag
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strings"
+	"sync"
+
+	iso8583v2 "github.com/henglory/iso8583/v2"
+	"golang.org/x/tools/go/analysis"
+)
+
+const doc = `check struct tags of iso8583v2 messages
+
+Reports fields of structs with an mti field whose field, type, length, encode,
+cp, cppolicy, bitmapsize or iso8583 tags Marshal would ignore or reject at runtime.`
+
+//Analyzer reports invalid iso8583v2 struct tags
+var Analyzer = &analysis.Analyzer{
+	Name: "iso8583tag",
+	Doc:  doc,
+	Run:  run,
+}
+
+var (
+	extraTypes     string
+	extraCodepages string
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&extraTypes, "types", "", "comma separated field types registered by the program with RegisterFieldType")
+	Analyzer.Flags.StringVar(&extraCodepages, "codepages", "", "comma separated code pages registered by the program with RegisterCodepage")
+}
+
+//registerOnce registers the flag names once per process, analyzer passes run concurrently
+var (
+	registerOnce sync.Once
+	registerErr  error
+)
+
+//stubCodec stands in for field types registered by the analyzed program
+type stubCodec struct{}
+
+func (stubCodec) Encode(tag iso8583v2.FieldTag, value []byte) ([]byte, error) {
+	return value, nil
+}
+
+func (stubCodec) Decode(tag iso8583v2.FieldTag, data []byte) ([]byte, []byte, error) {
+	return data, nil, nil
+}
+
+func stubCodepage(b []byte) ([]byte, error) {
+	return b, nil
+}
+
+func registerExtra() error {
+	registerOnce.Do(func() {
+		var errs []string
+		seen := make(map[string]bool)
+		for _, name := range strings.Split(extraTypes, ",") {
+			if name = strings.TrimSpace(name); name != "" && !seen["type:"+name] {
+				seen["type:"+name] = true
+				if err := iso8583v2.RegisterFieldType(name, stubCodec{}); err != nil {
+					errs = append(errs, err.Error())
+				}
+			}
+		}
+		for _, name := range strings.Split(extraCodepages, ",") {
+			if name = strings.TrimSpace(name); name != "" && !seen["cp:"+name] {
+				seen["cp:"+name] = true
+				if err := iso8583v2.RegisterCodepage(name, stubCodepage, stubCodepage); err != nil {
+					errs = append(errs, err.Error())
+				}
+			}
+		}
+		if len(errs) > 0 {
+			registerErr = fmt.Errorf("iso8583tag: %s", strings.Join(errs, ", "))
+		}
+	})
+	return registerErr
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if err := registerExtra(); err != nil {
+		return nil, err
+	}
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			ts, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			obj := pass.TypesInfo.Defs[ts.Name]
+			if obj == nil {
+				return true
+			}
+			st, ok := obj.Type().Underlying().(*types.Struct)
+			if !ok || !isMessage(st) {
+				return true
+			}
+			checkMessage(pass, st)
+			return true
+		})
+	}
+	return nil, nil
+}
+
+func isMessage(st *types.Struct) bool {
+	for i := 0; i < st.NumFields(); i++ {
+		if f := st.Field(i); f.Exported() && isMti(f.Name(), reflect.StructTag(st.Tag(i))) {
+			return true
+		}
+	}
+	return false
+}
+
+//isMti follows the tag parser, field:"mti" or iso8583:"mti" marks the mti and
+//a field named mti without a field number is the mti
+func isMti(name string, tag reflect.StructTag) bool {
+	if v, ok := tag.Lookup("iso8583"); ok {
+		first, _, _ := strings.Cut(v, ",")
+		return strings.EqualFold(strings.TrimSpace(first), "mti")
+	}
+	if v, ok := tag.Lookup("field"); ok {
+		return strings.EqualFold(strings.TrimSpace(v), "mti")
+	}
+	return strings.ToLower(name) == "mti"
+}
+
+func checkMessage(pass *analysis.Pass, st *types.Struct) {
+	fields, pos := describe(st, true)
+	for _, e := range iso8583v2.CheckMessageTags(fields) {
+		p := pos[e.Field].Pos()
+		if e.Subfield != "" {
+			//a sub field struct of another file or package is reported at the message field
+			if sv, ok := pos[e.Field+"."+e.Subfield]; ok && pass.Fset.File(sv.Pos()) == pass.Fset.File(p) {
+				p = sv.Pos()
+			}
+		}
+		pass.Reportf(p, "iso8583 %s", e.Error())
+	}
+}
+
+//describe returns the fields of st for CheckMessageTags and their positions by name,
+//with the sub fields of struct fields when withSub is set
+func describe(st *types.Struct, withSub bool) ([]iso8583v2.TagField, map[string]*types.Var) {
+	pos := make(map[string]*types.Var)
+	var fields []iso8583v2.TagField
+	for i := 0; i < st.NumFields(); i++ {
+		v := st.Field(i)
+		if v.Embedded() {
+			continue
+		}
+		f := iso8583v2.TagField{
+			Name:      v.Name(),
+			Tag:       st.Tag(i),
+			Marshaler: isMarshaler(v.Type()),
+		}
+		f.Kind, f.Elem = kinds(v.Type())
+		pos[v.Name()] = v
+		if withSub {
+			if sub := structOf(v.Type()); sub != nil {
+				var subPos map[string]*types.Var
+				f.Fields, subPos = describe(sub, false)
+				for name, sv := range subPos {
+					pos[v.Name()+"."+name] = sv
+				}
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, pos
+}
+
+func structOf(t types.Type) *types.Struct {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	st, _ := t.Underlying().(*types.Struct)
+	return st
+}
+
+func isMarshaler(t types.Type) bool {
+	if _, ok := t.Underlying().(*types.Pointer); !ok {
+		t = types.NewPointer(t)
+	}
+	ms := types.NewMethodSet(t)
+	for _, name := range []string{"MarshalISO8583Field", "MarshalText"} {
+		if ms.Lookup(nil, name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+//kinds maps t to the reflect kinds CheckMessageTags works on
+func kinds(t types.Type) (reflect.Kind, reflect.Kind) {
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		return reflect.Ptr, kind(u.Elem())
+	case *types.Slice:
+		return reflect.Slice, kind(u.Elem())
+	}
+	return kind(t), reflect.Invalid
+}
+
+func kind(t types.Type) reflect.Kind {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return basicKinds[u.Kind()]
+	case *types.Pointer:
+		return reflect.Ptr
+	case *types.Slice:
+		return reflect.Slice
+	case *types.Array:
+		return reflect.Array
+	case *types.Struct:
+		return reflect.Struct
+	case *types.Map:
+		return reflect.Map
+	case *types.Chan:
+		return reflect.Chan
+	case *types.Signature:
+		return reflect.Func
+	case *types.Interface:
+		return reflect.Interface
+	}
+	return reflect.Invalid
+}
+
+var basicKinds = map[types.BasicKind]reflect.Kind{
+	types.Bool:       reflect.Bool,
+	types.Int:        reflect.Int,
+	types.Int8:       reflect.Int8,
+	types.Int16:      reflect.Int16,
+	types.Int32:      reflect.Int32,
+	types.Int64:      reflect.Int64,
+	types.Uint:       reflect.Uint,
+	types.Uint8:      reflect.Uint8,
+	types.Uint16:     reflect.Uint16,
+	types.Uint32:     reflect.Uint32,
+	types.Uint64:     reflect.Uint64,
+	types.Uintptr:    reflect.Uintptr,
+	types.Float32:    reflect.Float32,
+	types.Float64:    reflect.Float64,
+	types.Complex64:  reflect.Complex64,
+	types.Complex128: reflect.Complex128,
+	types.String:     reflect.String,
+}
@@ -0,0 +1,15 @@
+package iso8583tag_test
+
+import (
+	"testing"
+
+	"github.com/henglory/iso8583/v2/passes/iso8583tag"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	if err := iso8583tag.Analyzer.Flags.Set("types", "x-test-type,x-test-type"); err != nil {
+		t.Fatal(err)
+	}
+	analysistest.Run(t, analysistest.TestData(), iso8583tag.Analyzer, "a")
+}
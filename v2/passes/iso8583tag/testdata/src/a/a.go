@@ -0,0 +1,70 @@
+package a
+
+type Text string
+
+func (t Text) MarshalText() ([]byte, error) { return []byte(t), nil }
+
+type GoodSub struct {
+	T1 string  `field:"1" length:"3"`
+	T2 *int    `field:"2" length:"4"`
+	T3 []byte  `field:"3" length:"2"`
+	T4 float64 `field:"4" length:"5"`
+}
+
+type Sub struct {
+	T1 string   `field:"1" length:"3"`
+	T2 int      `field:"2" length:"x"` // want `iso8583 field:Bad subfield:T2 length "x" is not a positive number`
+	T3 string   `field:"1" length:"2"` // want `iso8583 field:Bad subfield:T3 shares field number 1 with subfield:T1`
+	T4 []string `field:"4" length:"2"` // want `iso8583 field:Bad subfield:T4 type slice of string is not supported in a fixed width field`
+	T9 string   `field:"9" length:"2"` // want `iso8583 field:Bad subfield:T9 field number 9 does not fit bitmapsize 1`
+	No string   `json:"no"`
+}
+
+type Good struct {
+	Mti    string   `encode:"bcd"`
+	Pan    string   `field:"2" type:"llvar" length:"19"`
+	Code   int      `field:"3" type:"numeric" length:"6" encode:"bcd"`
+	Amount *int64   `field:"4" type:"numeric" length:"12" encode:"rbcd"`
+	Name   string   `field:"43" type:"alpha" length:"40" cp:"ibm037"`
+	Pin    []byte   `field:"52" type:"binary" length:"8"`
+	Text   Text     `field:"60" type:"binary" length:"8"`
+	Custom []byte   `field:"61" type:"x-test-type"`
+	Sub    *GoodSub `field:"62" type:"lllvar" bitmapsize:"1"`
+	Track  string   `iso8583:"35,ll,lenc=bcd" json:"track"`
+	Note   string   `json:"note"`
+}
+
+type Bad struct {
+	MTI      float64           // want `iso8583 field:MTI mti type must be string, integer or a text marshaler, got float64`
+	Header   string            `field:"mti" encode:"rbcd"`                        // want `iso8583 field:Header Unsupport mti encode rbcd`
+	Pan      string            `field:"2" type:"llvr"`                            // want `iso8583 field:Pan type "llvr" is not a built in or registered type`
+	Code     string            `field:"3" type:"numeric"`                         // want `iso8583 field:Code numeric field requires a length`
+	Dup      string            `field:"3" type:"alpha" length:"2"`                // want `iso8583 field:Dup shares field number 3 with field:Code`
+	NoNumber string            `type:"alpha" length:"2"`                          // want `iso8583 field:NoNumber field number must be specified`
+	Encode   string            `field:"5" type:"numeric" length:"2" encode:"bdc"` // want `iso8583 field:Encode encode "bdc" is not ascii, bcd or rbcd`
+	Llvar    string            `field:"6" type:"llvar" encode:"ascii,bcd"`        // want `iso8583 field:Llvar llvar value encode must be ascii, got bcd`
+	Cp       string            `field:"7" type:"alpha" length:"2" cp:"nope"`      // want `iso8583 field:Cp Unsupport codepage nope`
+	Unsigned uint              `field:"8" type:"numeric" length:"2"`              // want `iso8583 field:Unsigned type uint is not supported`
+	Struct   GoodSub           `field:"9" type:"alpha" length:"2"`                // want `iso8583 field:Struct struct field must be llvar, lllvar or a registered type, got alpha`
+	Binary   string            `field:"10" type:"binary" length:"2"`              // want `iso8583 field:Binary binary field must be \[\]byte, got string`
+	Bitmap   string            `field:"11" type:"llvar" bitmapsize:"1"`           // want `iso8583 field:Bitmap bitmapsize is only used by struct fields`
+	Range    string            `field:"129" type:"llvar"`                         // want `iso8583 field:Range field number 129 is not between 1 and 128`
+	Bad      Sub               `field:"48" type:"llvar" bitmapsize:"1"`
+	Map      map[string]string `field:"12" type:"llvar"`  // want `iso8583 field:Map type map is not supported`
+	Short    string            `iso8583:"13,n"`           // want `iso8583 field:Short numeric field requires a length`
+	Key      string            `iso8583:"14,a,2,fill=0"`  // want `iso8583 field:Key Unsupport iso8583 tag key fill`
+	Pad      string            `iso8583:"15,ll,pad=left"` // want `iso8583 field:Pad pad is only applied to numeric and alpha fields, got llvar`
+}
+
+// Tagged marks its mti with a tag
+type Tagged struct {
+	Type int    `iso8583:"mti,enc=ebcdic"`
+	Pan  string `iso8583:"2,ll"`
+	Code string `iso8583:"3,n"` // want `iso8583 field:Code numeric field requires a length`
+}
+
+// NotMessage has tags of another library and no mti
+type NotMessage struct {
+	ID   int    `field:"id" type:"serial"`
+	Name string `length:"x"`
+}
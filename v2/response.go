@@ -0,0 +1,217 @@
+package iso8583v2
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var (
+	echoLock sync.RWMutex
+	//echoFields are the fields a response copies from its request by message class
+	echoFields = map[MTIClass][]int{
+		ClassAuthorization:     {2, 3, 4, 7, 11, 12, 13, 32, 37, 41, 42, 49},
+		ClassFinancial:         {2, 3, 4, 7, 11, 12, 13, 32, 37, 41, 42, 49},
+		ClassFileActions:       {2, 7, 11, 12, 13, 37, 41, 42},
+		ClassReversal:          {2, 3, 4, 7, 11, 12, 13, 32, 37, 41, 42, 49, 90},
+		ClassReconciliation:    {7, 11, 15, 32, 50},
+		ClassAdministrative:    {7, 11, 32, 37},
+		ClassFeeCollection:     {2, 3, 4, 7, 11, 32, 37, 49},
+		ClassNetworkManagement: {7, 11, 70},
+	}
+)
+
+//SetEchoFields replaces the fields NewResponse copies from requests of class
+func SetEchoFields(class MTIClass, fields ...int) error {
+	for _, n := range fields {
+		if !isDataField(n) {
+			return fmt.Errorf("echo field %d is not a data field", n)
+		}
+	}
+	echoLock.Lock()
+	defer echoLock.Unlock()
+	echoFields[class] = append([]int(nil), fields...)
+	return nil
+}
+
+//EchoFields returns the fields NewResponse copies from requests of class
+func EchoFields(class MTIClass) []int {
+	echoLock.RLock()
+	defer echoLock.RUnlock()
+	return append([]int(nil), echoFields[class]...)
+}
+
+//NewResponse returns the response to req, the mti becomes the response mti, the echo fields
+//of its class are copied and every other field is zero. overrides sets fields by number afterwards,
+//such as 38 for the approval code and 39 for the response code. The copy is shallow.
+//NewResponseFields builds the response of a dynamic message
+func NewResponse[T any](req T, overrides map[int]interface{}) (T, error) {
+	var res T
+	p := reflect.ValueOf(&res)
+	if v := p.Elem(); v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		p = v
+	}
+	if err := FillResponse(p.Interface(), req, overrides); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+//FillResponse is NewResponse for a response type that differs from the request type,
+//res must be a pointer to struct, fields are matched by number
+func FillResponse(res interface{}, req interface{}, overrides map[int]interface{}) error {
+	reqVal, err := validateEncode(req)
+	if err != nil {
+		return fmt.Errorf("validate failed: %s", err.Error())
+	}
+	resVal, err := validateDecode(res)
+	if err != nil {
+		return err
+	}
+	if !resVal.IsValid() || resVal.Kind() != reflect.Struct {
+		return fmt.Errorf("response must be a pointer to struct")
+	}
+	reqFields, reqMti := responseFields(reqVal)
+	resFields, resMti := responseFields(resVal)
+	if reqMti == nil || resMti == nil {
+		return fmt.Errorf("request and response must have an mti field")
+	}
+	mti, err := mtiValue(reqVal.FieldByIndex(reqMti.index), *reqMti.tag)
+	if err != nil {
+		return err
+	}
+	m, err := ParseMTI(mti)
+	if err != nil {
+		return err
+	}
+	if m, err = m.ResponseMTI(); err != nil {
+		return err
+	}
+	resVal.Set(reflect.Zero(resVal.Type()))
+	if err := setMti(resVal.FieldByIndex(resMti.index), m.String(), *resMti.tag); err != nil {
+		return err
+	}
+	for _, n := range EchoFields(m.Class) {
+		from, ok := reqFields[n]
+		to, found := resFields[n]
+		if !ok || !found {
+			continue
+		}
+		if err := setResponseField(resVal.FieldByIndex(to.index), reqVal.FieldByIndex(from.index)); err != nil {
+			return fmt.Errorf("echo field %d failed: %s", n, err.Error())
+		}
+	}
+	numbers := make([]int, 0, len(overrides))
+	for n := range overrides {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	for _, n := range numbers {
+		to, ok := resFields[n]
+		if !ok {
+			return fmt.Errorf("response %v has no field %d", resVal.Type(), n)
+		}
+		if err := setResponseField(resVal.FieldByIndex(to.index), reflect.ValueOf(overrides[n])); err != nil {
+			return fmt.Errorf("override field %d failed: %s", n, err.Error())
+		}
+	}
+	return nil
+}
+
+//NewResponseFields is NewResponse for a dynamic message held as values by field number, it returns
+//the response mti and the echo fields of req with overrides set afterwards. A nil override removes the field
+func NewResponseFields[V any](mti string, req map[int]V, overrides map[int]V) (string, map[int]V, error) {
+	m, err := ParseMTI(mti)
+	if err != nil {
+		return "", nil, err
+	}
+	if m, err = m.ResponseMTI(); err != nil {
+		return "", nil, err
+	}
+	res := make(map[int]V)
+	for _, n := range EchoFields(m.Class) {
+		if v, ok := req[n]; ok {
+			res[n] = v
+		}
+	}
+	for n, v := range overrides {
+		if !isDataField(n) {
+			return "", nil, fmt.Errorf("override field %d is not a data field", n)
+		}
+		if interface{}(v) == nil {
+			delete(res, n)
+			continue
+		}
+		res[n] = v
+	}
+	return m.String(), res, nil
+}
+
+type responseField struct {
+	index []int
+	tag   *iso8583Tag
+}
+
+//responseFields returns the fields of v by number and its mti field
+func responseFields(v reflect.Value) (map[int]responseField, *responseField) {
+	tags := cachedTag(v.Type())
+	fields := make(map[int]responseField, len(tags))
+	var mti *responseField
+	for i := 0; i < v.Type().NumField(); i++ {
+		f := v.Type().Field(i)
+		t := tags[f.Name]
+		if t == nil {
+			continue
+		}
+		if t.isMti {
+			mti = &responseField{index: f.Index, tag: t}
+			continue
+		}
+		fields[t.field] = responseField{index: f.Index, tag: t}
+	}
+	return fields, mti
+}
+
+//setResponseField sets to from a value of the same type, of its pointer type,
+//of a type with the same kind or of another signed integer type, an invalid from such as a nil override zeroes the field
+func setResponseField(to reflect.Value, from reflect.Value) error {
+	if !from.IsValid() {
+		to.Set(reflect.Zero(to.Type()))
+		return nil
+	}
+	if from.Kind() == reflect.Ptr && to.Kind() != reflect.Ptr {
+		if from.IsNil() {
+			to.Set(reflect.Zero(to.Type()))
+			return nil
+		}
+		from = from.Elem()
+	}
+	if to.Kind() == reflect.Ptr && from.Kind() != reflect.Ptr {
+		p := reflect.New(to.Type().Elem())
+		if err := setResponseField(p.Elem(), from); err != nil {
+			return err
+		}
+		to.Set(p)
+		return nil
+	}
+	switch {
+	case from.Type().AssignableTo(to.Type()):
+		to.Set(from)
+	case isIntKind(from.Kind()) && isIntKind(to.Kind()):
+		if to.OverflowInt(from.Int()) {
+			return fmt.Errorf("%d overflows %v", from.Int(), to.Type())
+		}
+		to.SetInt(from.Int())
+	case from.Kind() == to.Kind() && from.Type().ConvertibleTo(to.Type()):
+		to.Set(from.Convert(to.Type()))
+	default:
+		return fmt.Errorf("cannot use %v as %v", from.Type(), to.Type())
+	}
+	return nil
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
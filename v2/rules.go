@@ -0,0 +1,226 @@
+package iso8583v2
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//PresenceRule declares the fields a message type must carry,
+//it is read from the rules of a JSON or YAML message specification
+type PresenceRule struct {
+	MTI         string             `json:"mti" yaml:"mti"`
+	Mandatory   []int              `json:"mandatory" yaml:"mandatory"`
+	Conditional []ConditionalField `json:"conditional" yaml:"conditional"`
+}
+
+//ConditionalField makes Field mandatory when field When is present
+//and its value starts with Prefix, an empty Prefix only needs When to be present
+type ConditionalField struct {
+	Field  int    `json:"field" yaml:"field"`
+	When   int    `json:"when" yaml:"when"`
+	Prefix string `json:"prefix" yaml:"prefix"`
+}
+
+//PresenceRules are presence rules by mti, a repeat uses the rule of its original mti
+//when it has none of its own
+type PresenceRules struct {
+	rules map[string]PresenceRule
+}
+
+//PresenceError lists the fields a message lacks for its mti
+type PresenceError struct {
+	MTI     string
+	Missing []int
+}
+
+func (e *PresenceError) Error() string {
+	fields := make([]string, len(e.Missing))
+	for i, n := range e.Missing {
+		fields[i] = strconv.Itoa(n)
+	}
+	return fmt.Sprintf("mti %s is missing field %s", e.MTI, strings.Join(fields, ","))
+}
+
+//NewPresenceRules checks rules, rules of the same mti are merged
+func NewPresenceRules(rules ...PresenceRule) (*PresenceRules, error) {
+	r := &PresenceRules{rules: make(map[string]PresenceRule, len(rules))}
+	for _, rule := range rules {
+		if _, err := ParseMTI(rule.MTI); err != nil {
+			return nil, fmt.Errorf("presence rule %s", err.Error())
+		}
+		for _, n := range rule.Mandatory {
+			if !isDataField(n) {
+				return nil, fmt.Errorf("presence rule %s field %d is not a data field", rule.MTI, n)
+			}
+		}
+		for _, c := range rule.Conditional {
+			if !isDataField(c.Field) || !isDataField(c.When) {
+				return nil, fmt.Errorf("presence rule %s condition of field %d when %d is not a data field", rule.MTI, c.Field, c.When)
+			}
+		}
+		merged := r.rules[rule.MTI]
+		merged.MTI = rule.MTI
+		merged.Mandatory = append(merged.Mandatory, rule.Mandatory...)
+		merged.Conditional = append(merged.Conditional, rule.Conditional...)
+		r.rules[rule.MTI] = merged
+	}
+	return r, nil
+}
+
+//MustPresenceRules is NewPresenceRules for package variables, it panics when a rule is invalid
+func MustPresenceRules(rules ...PresenceRule) *PresenceRules {
+	r, err := NewPresenceRules(rules...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+//ReadPresenceRules reads the rules of a JSON message specification
+func ReadPresenceRules(data []byte) (*PresenceRules, error) {
+	var spec struct {
+		Rules []PresenceRule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("read presence rules failed %s", err.Error())
+	}
+	return NewPresenceRules(spec.Rules...)
+}
+
+//Rule returns the rule of mti
+func (r *PresenceRules) Rule(mti string) (PresenceRule, bool) {
+	if rule, ok := r.rules[mti]; ok {
+		return rule, true
+	}
+	m, err := ParseMTI(mti)
+	if err != nil || !m.IsRepeat() {
+		return PresenceRule{}, false
+	}
+	m.Origin &^= 1
+	rule, ok := r.rules[m.String()]
+	return rule, ok
+}
+
+//check returns a *PresenceError when the rule of mti needs fields that are not present,
+//value returns the text of a present field for the conditions
+func (r *PresenceRules) check(mti string, present func(int) bool, value func(int) string) error {
+	rule, ok := r.Rule(mti)
+	if !ok {
+		return nil
+	}
+	missing := map[int]bool{}
+	for _, n := range rule.Mandatory {
+		if !present(n) {
+			missing[n] = true
+		}
+	}
+	for _, c := range rule.Conditional {
+		if present(c.When) && !present(c.Field) && strings.HasPrefix(value(c.When), c.Prefix) {
+			missing[c.Field] = true
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	e := &PresenceError{MTI: mti}
+	for n := range missing {
+		e.Missing = append(e.Missing, n)
+	}
+	sort.Ints(e.Missing)
+	return e
+}
+
+//checkStruct applies the rules to the fields of struct v, present reads the bitmap being built or decoded
+func (r *PresenceRules) checkStruct(v reflect.Value, tag map[string]*iso8583Tag, present func(int) bool) error {
+	var mti string
+	fields := make(map[int]int, len(tag))
+	for i := 0; i < v.Type().NumField(); i++ {
+		t := tag[v.Type().Field(i).Name]
+		if t == nil {
+			continue
+		}
+		if t.isMti {
+			s, err := mtiValue(v.Field(i), *t)
+			if err != nil {
+				return err
+			}
+			mti = s
+			continue
+		}
+		fields[t.field] = i
+	}
+	return r.check(mti, present, func(n int) string {
+		i, ok := fields[n]
+		if !ok {
+			return ""
+		}
+		return presenceValue(v.Field(i), *tag[v.Type().Field(i).Name])
+	})
+}
+
+//presenceValue returns the text of a field value as it is sent before encoding,
+//numeric and alpha fields are padded to the field length by their pad tag
+func presenceValue(v reflect.Value, t iso8583Tag) string {
+	s := presenceText(v, t)
+	def := alphaPad
+	switch t.fieldType {
+	case numeric:
+		def = numericPad
+	case alpha:
+	default:
+		return s
+	}
+	if s == "" || t.length <= 0 {
+		return s
+	}
+	b, err := t.pad.or(def).fill([]byte(s), t.length, defaultCp)
+	if err != nil {
+		return s
+	}
+	return string(b)
+}
+
+//presenceText returns the text of a field value without padding
+func presenceText(v reflect.Value, t iso8583Tag) string {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if b, ok, err := marshalValue(v, t.export()); ok {
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes())
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+//bitmapPresent reads presence from a decoded bitmap
+func bitmapPresent(bitmap []byte) func(int) bool {
+	return func(n int) bool {
+		on, err := isBitOn(bitmap, n)
+		return err == nil && on
+	}
+}
+
+func isDataField(n int) bool {
+	return n >= 2 && n <= 128 && n != 65
+}
@@ -0,0 +1,138 @@
+package iso8583v2
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+//Framing is the length header in front of every message on a stream
+type Framing int
+
+const (
+	//FramingBinary2 is a two byte big endian length
+	FramingBinary2 Framing = iota + 1
+	//FramingASCII4 is a four digit ascii length
+	FramingASCII4
+	//FramingBCD2 is a four digit length packed into two bcd bytes
+	FramingBCD2
+)
+
+//DefaultMaxMessageSize is the largest message a Decoder accepts unless SetMaxMessageSize is called
+const DefaultMaxMessageSize = 8192
+
+//Encoder writes iso8583 messages to an io.Writer
+type Encoder struct {
+	w    io.Writer
+	opts []Option
+}
+
+//NewEncoder returns an encoder that writes to w, opts are passed to every MarshalAppend
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	return &Encoder{w: w, opts: opts}
+}
+
+//Encode marshals v into a pooled buffer and writes it to the underlying writer with a single Write
+func (e *Encoder) Encode(v interface{}) error {
+	bp := getBuffer()
+	defer putBuffer(bp)
+	b, err := MarshalAppend(*bp, v, e.opts...)
+	*bp = b
+	if err != nil {
+		return err
+	}
+	if _, err = e.w.Write(b); err != nil {
+		return fmt.Errorf("write message failed %s", err.Error())
+	}
+	return nil
+}
+
+//Decoder reads length framed iso8583 messages from an io.Reader
+type Decoder struct {
+	r       io.Reader
+	framing Framing
+	max     int
+	opt     options
+	header  [4]byte
+	buf     []byte
+}
+
+//NewDecoder returns a decoder that reads messages framed by framing from r.
+//Without ZeroCopy the read buffer is reused between messages
+func NewDecoder(r io.Reader, framing Framing, opts ...Option) *Decoder {
+	return &Decoder{
+		r:       r,
+		framing: framing,
+		max:     DefaultMaxMessageSize,
+		opt:     newOptions(opts),
+	}
+}
+
+//SetMaxMessageSize sets the largest message length Decode accepts
+func (d *Decoder) SetMaxMessageSize(n int) {
+	d.max = n
+}
+
+//Decode reads the next message and unmarshals it into v.
+//It returns io.EOF when the stream ends before a header
+func (d *Decoder) Decode(v interface{}) error {
+	n, err := d.readLength()
+	if err != nil {
+		return err
+	}
+	if n > d.max {
+		return fmt.Errorf("message length %d is larger than maximum %d", n, d.max)
+	}
+	var data []byte
+	if d.opt.zeroCopy {
+		//decoded fields point into data so it cannot be reused
+		data = make([]byte, n)
+	} else {
+		if cap(d.buf) < n {
+			d.buf = make([]byte, n)
+		}
+		data = d.buf[:n]
+	}
+	if _, err = io.ReadFull(d.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("read message failed %w", err)
+	}
+	return unmarshal(data, v, d.opt)
+}
+
+func (d *Decoder) readLength() (int, error) {
+	var size int
+	switch d.framing {
+	case FramingBinary2, FramingBCD2:
+		size = 2
+	case FramingASCII4:
+		size = 4
+	default:
+		return 0, fmt.Errorf("framing %d is not supported", d.framing)
+	}
+	header := d.header[:size]
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		return 0, fmt.Errorf("read length header failed %w", err)
+	}
+	switch d.framing {
+	case FramingBinary2:
+		return int(header[0])<<8 | int(header[1]), nil
+	case FramingBCD2:
+		n, err := strconv.Atoi(string(bcd2Ascii(header)))
+		if err != nil {
+			return 0, fmt.Errorf("bcd length header %X is invalid", header)
+		}
+		return n, nil
+	default:
+		n, err := strconv.Atoi(string(header))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("ascii length header %q is invalid", header)
+		}
+		return n, nil
+	}
+}
@@ -11,7 +11,13 @@ type encodeBase int
 
 type iso8583FieldType int
 
-type codepageType int
+type codepageType string
+
+type codepagePolicy int
+
+type attributeClass string
+
+type padSide int
 
 const (
 	mtiWord = "mti"
@@ -22,16 +28,30 @@ const (
 	bitmapsizeWord = "bitmapsize"
 	typeWord       = "type"
 	codepageWord   = "cp"
+	cpPolicyWord   = "cppolicy"
+	classWord      = "class"
+	padWord        = "pad"
+	trimWord       = "trim"
 
 	fixedFieldWord    = "field"
 	fixedLengthWord   = "length"
 	fixedCodepageWord = "cp"
+	fixedCpPolicyWord = "cppolicy"
+	fixedClassWord    = "class"
+	fixedPadWord      = "pad"
+	fixedTrimWord     = "trim"
+
+	namespaceWord = "iso8583"
+	dictWord      = "dict"
 )
 
 const (
 	ascii encodeBase = iota + 1
 	bcd
 	rbcd
+	//ebcdic and hexEncode are only used by the mti
+	ebcdic
+	hexEncode
 )
 
 const (
@@ -43,12 +63,32 @@ const (
 )
 
 const (
-	defaultCp codepageType = iota + 1
-	windows874
-	tis620
-	hexstring
+	defaultCp codepageType = ""
+	hexstring codepageType = "hexstring"
+)
+
+const (
+	failPolicy codepagePolicy = iota + 1
+	replacePolicy
+	dropPolicy
+	//lenientPolicy is the policy without a cppolicy tag, it fails only in strict mode
+	lenientPolicy
 )
 
+const (
+	padDefault padSide = iota
+	padLeft
+	padRight
+)
+
+//padRule is the padding of a pad tag such as pad:"left,F",
+//the zero padRule keeps the padding of the field type. Decode strips a digit pad character only from numbers,
+//a number padded on the right with 0 should not end in 0 itself
+type padRule struct {
+	side padSide
+	char byte
+}
+
 type iso8583Tag struct {
 	name       string
 	isMti      bool
@@ -58,7 +98,13 @@ type iso8583Tag struct {
 	valEncode  encodeBase
 	fieldType  iso8583FieldType
 	codePage   codepageType
+	cpPolicy   codepagePolicy
+	class      attributeClass
+	pad        padRule
+	trim       TrimPolicy
 	bitmapSize int
+	//dict is the dictionary an mti field names for its message
+	dict *Dictionary
 }
 
 type fixedwidthTag struct {
@@ -66,13 +112,25 @@ type fixedwidthTag struct {
 	field    int
 	length   int
 	codePage codepageType
+	cpPolicy codepagePolicy
+	class    attributeClass
+	pad      padRule
+	trim     TrimPolicy
+}
+
+func loadTag(typ reflect.Type) map[string]*iso8583Tag {
+	return loadTagWith(typ, nil)
 }
 
-func loadTag(v reflect.Value) map[string]*iso8583Tag {
+//loadTagWith resolves untyped tags with d, or with the dictionary the mti names when d is nil
+func loadTagWith(typ reflect.Type, d *Dictionary) map[string]*iso8583Tag {
 	mp := make(map[string]*iso8583Tag)
-	for i := 0; i < v.Type().NumField(); i++ {
-		f := v.Type().Field(i)
-		t, err := parseIso8583Tag(f)
+	if d == nil {
+		d = typeDictionary(typ)
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		t, err := parseMessageTag(f, d)
 		if err != nil {
 			continue
 		}
@@ -81,10 +139,10 @@ func loadTag(v reflect.Value) map[string]*iso8583Tag {
 	return mp
 }
 
-func loadFixedwidthTag(v reflect.Value) map[string]*fixedwidthTag {
+func loadFixedwidthTag(typ reflect.Type) map[string]*fixedwidthTag {
 	mp := make(map[string]*fixedwidthTag)
-	for i := 0; i < v.Type().NumField(); i++ {
-		f := v.Type().Field(i)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
 		t, err := parseFixedLengthTag(f)
 		if err != nil {
 			continue
@@ -96,22 +154,56 @@ func loadFixedwidthTag(v reflect.Value) map[string]*fixedwidthTag {
 
 func parseFixedLengthTag(f reflect.StructField) (t fixedwidthTag, err error) {
 	t.name = f.Name
+	if f.Tag, err = expandTag(f.Tag, true); err != nil {
+		return
+	}
 	if t.field, err = strconv.Atoi(f.Tag.Get(fixedFieldWord)); err != nil {
 		return
 	}
 	if t.length, err = strconv.Atoi(f.Tag.Get(fixedLengthWord)); err != nil {
 		return
 	}
-	t.codePage, err = parseCodepage(f.Tag.Get(fixedCodepageWord))
+	if t.codePage, err = parseCodepage(f.Tag.Get(fixedCodepageWord)); err != nil {
+		return
+	}
+	if t.class, err = parseClass(f.Tag.Get(fixedClassWord)); err != nil {
+		return
+	}
+	if t.pad, err = parsePad(f.Tag.Get(fixedPadWord)); err != nil {
+		return
+	}
+	if t.trim, err = parseTrim(f.Tag.Get(fixedTrimWord)); err != nil {
+		return
+	}
+	t.cpPolicy, err = parseCodepagePolicy(f.Tag.Get(fixedCpPolicyWord))
 	return
 }
 
+//parseIso8583Tag parses a field on its own, with DefaultDictionary unless the tag names a dictionary
 func parseIso8583Tag(f reflect.StructField) (t iso8583Tag, err error) {
+	d, err := parseDictionary("")
+	if err != nil {
+		return
+	}
+	return parseMessageTag(f, d)
+}
+
+//parseMessageTag parses a field of a message whose dictionary is d
+func parseMessageTag(f reflect.StructField, d *Dictionary) (t iso8583Tag, err error) {
 	var parseErr error
 	t.name = f.Name
-	if strings.ToLower(f.Name) == mtiWord {
+	if f.Tag, err = expandTag(f.Tag, false); err != nil {
+		return
+	}
+	if isMtiField(f.Name, f.Tag) {
 		t.isMti = true
-		t.valEncode = parseEncode(f.Tag.Get(encodeWord))
+		if t.valEncode, err = parseMtiEncode(f.Tag.Get(encodeWord)); err != nil {
+			return
+		}
+		t.dict, err = parseDictionary(f.Tag.Get(dictWord))
+		return
+	}
+	if f.Tag, err = fieldDictionaryTag(f.Tag, d); err != nil {
 		return
 	}
 	if t.field, err = strconv.Atoi(f.Tag.Get(fieldWord)); err != nil {
@@ -141,10 +233,174 @@ func parseIso8583Tag(f reflect.StructField) (t iso8583Tag, err error) {
 		err = fmt.Errorf("type must be specified")
 		return
 	}
-	t.codePage, err = parseCodepage(f.Tag.Get(codepageWord))
+	if t.codePage, err = parseCodepage(f.Tag.Get(codepageWord)); err != nil {
+		return
+	}
+	if t.class, err = parseClass(f.Tag.Get(classWord)); err != nil {
+		return
+	}
+	if t.pad, err = parsePad(f.Tag.Get(padWord)); err != nil {
+		return
+	}
+	if t.trim, err = parseTrim(f.Tag.Get(trimWord)); err != nil {
+		return
+	}
+	t.cpPolicy, err = parseCodepagePolicy(f.Tag.Get(cpPolicyWord))
 	return
 }
 
+//namespaceKeys maps the keys of a namespaced tag to the legacy tag words
+var (
+	namespaceKeys = map[string]string{
+		"enc":        encodeWord,
+		"lenc":       encodeWord,
+		"cp":         codepageWord,
+		"cppolicy":   cpPolicyWord,
+		"bitmapsize": bitmapsizeWord,
+		"dict":       dictWord,
+		"class":      classWord,
+		"pad":        padWord,
+		"trim":       trimWord,
+	}
+	namespaceFixedKeys = map[string]string{
+		"cp":       fixedCodepageWord,
+		"cppolicy": fixedCpPolicyWord,
+		"class":    fixedClassWord,
+		"pad":      fixedPadWord,
+		"trim":     fixedTrimWord,
+	}
+	typeAbbreviations = map[string]string{
+		"n":   "numeric",
+		"a":   "alpha",
+		"an":  "alpha",
+		"ans": "alpha",
+		"b":   "binary",
+		"ll":  "llvar",
+		"lll": "lllvar",
+	}
+)
+
+//expandTag rewrites a namespaced tag such as iso8583:"4,n,12,enc=bcd" into the legacy keys.
+//Values are field, type and length for a message field and field and length for a fixed width field,
+//an empty value is skipped. A type abbreviation is also the class unless the class key is given,
+//pad takes a colon in place of the comma such as pad=left:F.
+//A tag without the iso8583 key is returned as is, otherwise legacy keys the namespaced tag leaves out are kept
+//and the namespaced values take precedence
+func expandTag(tag reflect.StructTag, fixed bool) (reflect.StructTag, error) {
+	raw, ok := tag.Lookup(namespaceWord)
+	if !ok {
+		return tag, nil
+	}
+	positional, keys := []string{fieldWord, typeWord, lengthWord}, namespaceKeys
+	if fixed {
+		positional, keys = []string{fixedFieldWord, fixedLengthWord}, namespaceFixedKeys
+	}
+	var parts []string
+	var enc, lenc, class string
+	seen := make(map[string]bool)
+	pos := 0
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if key, val, isKey := strings.Cut(item, "="); isKey {
+			key = strings.ToLower(strings.TrimSpace(key))
+			word, known := keys[key]
+			if !known {
+				return tag, fmt.Errorf("Unsupport iso8583 tag key %s", key)
+			}
+			if seen[key] {
+				return tag, fmt.Errorf("iso8583 tag key %s is repeated", key)
+			}
+			seen[key] = true
+			switch key {
+			case "enc":
+				enc = val
+			case "lenc":
+				lenc = val
+			case "pad":
+				parts = append(parts, word+":"+strconv.Quote(strings.Replace(val, ":", ",", 1)))
+			default:
+				parts = append(parts, word+":"+strconv.Quote(val))
+			}
+			continue
+		}
+		if pos >= len(positional) {
+			return tag, fmt.Errorf("iso8583 tag %q has more than %d values", raw, len(positional))
+		}
+		if item != "" {
+			if positional[pos] == typeWord {
+				if full, short := typeAbbreviations[strings.ToLower(item)]; short {
+					class = strings.ToLower(item)
+					item = full
+				}
+			}
+			parts = append(parts, positional[pos]+":"+strconv.Quote(item))
+		}
+		pos++
+	}
+	switch {
+	case lenc != "":
+		parts = append(parts, encodeWord+":"+strconv.Quote(lenc+","+enc))
+	case enc != "":
+		parts = append(parts, encodeWord+":"+strconv.Quote(enc))
+	}
+	if _, ok := attributeClasses[attributeClass(class)]; ok && !seen["class"] {
+		parts = append(parts, classWord+":"+strconv.Quote(class))
+	}
+	set := make(map[string]bool)
+	for _, part := range parts {
+		word, _, _ := strings.Cut(part, ":")
+		set[word] = true
+	}
+	words := positional
+	for _, word := range keys {
+		words = append(words, word)
+	}
+	for _, word := range words {
+		if set[word] {
+			continue
+		}
+		set[word] = true
+		if val, ok := tag.Lookup(word); ok {
+			parts = append(parts, word+":"+strconv.Quote(val))
+		}
+	}
+	return reflect.StructTag(strings.Join(parts, " ")), nil
+}
+
+//fieldDictionaryTag fills tag from the dictionary the field names, or from d
+func fieldDictionaryTag(tag reflect.StructTag, d *Dictionary) (reflect.StructTag, error) {
+	if v, ok := tag.Lookup(dictWord); ok {
+		var err error
+		if d, err = parseDictionary(v); err != nil {
+			return tag, err
+		}
+	}
+	return applyDictionary(tag, d), nil
+}
+
+//isMtiField reports whether a field holds the mti, field:"mti" marks it explicitly,
+//otherwise a field without a field number named mti is the mti
+func isMtiField(name string, tag reflect.StructTag) bool {
+	if v, ok := tag.Lookup(fieldWord); ok {
+		return strings.EqualFold(strings.TrimSpace(v), mtiWord)
+	}
+	return strings.ToLower(name) == mtiWord
+}
+
+func parseMtiEncode(s string) (encodeBase, error) {
+	switch strings.ToLower(s) {
+	case "", "ascii":
+		return ascii, nil
+	case "bcd", "lbcd":
+		return bcd, nil
+	case "ebcdic":
+		return ebcdic, nil
+	case "hex":
+		return hexEncode, nil
+	}
+	return ascii, fmt.Errorf("Unsupport mti encode %s", s)
+}
+
 func parseEncode(s string) encodeBase {
 	switch strings.ToLower(s) {
 	case "ascii":
@@ -160,7 +416,18 @@ func parseEncode(s string) encodeBase {
 }
 
 func parseType(s string) (iso8583FieldType, error) {
-	switch strings.ToLower(s) {
+	s = strings.ToLower(s)
+	if t, err := parseBuiltinType(s); err == nil {
+		return t, nil
+	}
+	if t, ok := lookupFieldType(s); ok {
+		return t, nil
+	}
+	return -1, fmt.Errorf("Unsupport type for type %s", s)
+}
+
+func parseBuiltinType(s string) (iso8583FieldType, error) {
+	switch s {
 	case "numeric":
 		return numeric, nil
 	case "alpha":
@@ -176,30 +443,94 @@ func parseType(s string) (iso8583FieldType, error) {
 }
 
 func parseCodepage(s string) (codepageType, error) {
-	switch strings.ToLower(s) {
-	case "":
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
 		return defaultCp, nil
-	case "tis-620":
-		return tis620, nil
-	case "windows-874":
-		return windows874, nil
-	case "hexstring":
-		return hexstring, nil
 	}
-	return -1, fmt.Errorf("Unsupport codepage %s", s)
+	if _, ok := lookupCodepage(s); !ok {
+		return defaultCp, fmt.Errorf("Unsupport codepage %s", s)
+	}
+	return codepageType(s), nil
 }
 
-func (c codepageType) value() string {
-	switch c {
-	case hexstring:
-		return "hexstring"
-	case tis620:
-		return "TIS-620"
-	case windows874:
-		return "Windows-874"
+func parseClass(s string) (attributeClass, error) {
+	c := attributeClass(strings.ToLower(strings.TrimSpace(s)))
+	if c == noClass {
+		return noClass, nil
+	}
+	if _, ok := attributeClasses[c]; !ok {
+		return noClass, fmt.Errorf("Unsupport class %s", s)
+	}
+	return c, nil
+}
+
+//parsePad parses side,char where side is left or right and char is one printable ascii character,
+//without char the field type pads with its own character
+func parsePad(s string) (padRule, error) {
+	if s == "" {
+		return padRule{}, nil
+	}
+	side, char, _ := strings.Cut(s, ",")
+	var p padRule
+	switch strings.ToLower(strings.TrimSpace(side)) {
+	case "left":
+		p.side = padLeft
+	case "right":
+		p.side = padRight
 	default:
-		return ""
+		return padRule{}, fmt.Errorf("Unsupport pad %s", s)
+	}
+	if char != "" {
+		if len(char) != 1 || char[0] < 0x20 || char[0] > 0x7e {
+			return padRule{}, fmt.Errorf("Unsupport pad character %q", char)
+		}
+		p.char = char[0]
 	}
+	return p, nil
+}
+
+//parseTrim parses none, right, left or both, without a value the field uses DefaultTrim
+func parseTrim(s string) (TrimPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return 0, nil
+	case "none":
+		return TrimNone, nil
+	case "right":
+		return TrimRight, nil
+	case "left":
+		return TrimLeft, nil
+	case "both":
+		return TrimBoth, nil
+	}
+	return 0, fmt.Errorf("Unsupport trim %s", s)
+}
+
+func parseCodepagePolicy(s string) (codepagePolicy, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return lenientPolicy, nil
+	case "fail", "strict":
+		return failPolicy, nil
+	case "replace":
+		return replacePolicy, nil
+	case "drop":
+		return dropPolicy, nil
+	}
+	return -1, fmt.Errorf("Unsupport codepage policy %s", s)
+}
+
+//under returns the policy applied in strict mode or not, a field without a cppolicy tag
+//fails in strict mode and otherwise keeps the replacement characters of its code page
+func (p codepagePolicy) under(strict bool) codepagePolicy {
+	if p == lenientPolicy && strict {
+		return failPolicy
+	}
+	return p
+}
+
+func (c codepageType) value() string {
+	return string(c)
 }
 
 func (e encodeBase) value() string {
@@ -210,6 +541,10 @@ func (e encodeBase) value() string {
 		return "bcd"
 	case rbcd:
 		return "rbcd"
+	case ebcdic:
+		return "ebcdic"
+	case hexEncode:
+		return "hex"
 	default:
 		return ""
 	}
@@ -228,6 +563,9 @@ func (t iso8583FieldType) value() string {
 	case lllvar:
 		return "lllvar"
 	default:
+		if c, ok := lookupFieldCodec(t); ok {
+			return c.name
+		}
 		return fmt.Sprintf("unknown type %v", t)
 	}
 }
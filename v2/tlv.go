@@ -0,0 +1,243 @@
+package iso8583v2
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//TLV is a BER-TLV data object of EMV ICC data such as field 55. Tag is the tag in upper case hex such as 9F02.
+//The Children of a constructed tag are its data objects, they replace Value when the list is encoded
+type TLV struct {
+	Tag      string
+	Value    []byte
+	Children TLVList
+}
+
+//TLVList is an ordered list of data objects, a field of type tlv holds a TLVList, a []byte
+//or a struct whose fields are tagged by their EMV tag such as tlv:"9F02"
+type TLVList []TLV
+
+//tlvFieldType is the field type tlv, registered on init
+var tlvFieldType iso8583FieldType
+
+func init() {
+	if err := RegisterFieldType("tlv", tlvCodec{}); err != nil {
+		panic(err)
+	}
+	tlvFieldType, _ = lookupFieldType("tlv")
+}
+
+//Constructed reports whether the tag of t holds data objects
+func (t TLV) Constructed() bool {
+	b, err := hex.DecodeString(t.Tag)
+	return err == nil && len(b) > 0 && b[0]&0x20 != 0
+}
+
+//ParseTLV parses BER-TLV data, the data objects of constructed tags are parsed into Children.
+//Zero bytes before, between and after data objects are skipped
+func ParseTLV(data []byte) (TLVList, error) {
+	var l TLVList
+	for {
+		for len(data) > 0 && data[0] == 0x00 {
+			data = data[1:]
+		}
+		if len(data) == 0 {
+			return l, nil
+		}
+		tag, rest, err := readTLVTag(data)
+		if err != nil {
+			return nil, err
+		}
+		n, rest, err := readTLVLength(rest)
+		if err != nil {
+			return nil, fmt.Errorf("tag %X %s", tag, err.Error())
+		}
+		if len(rest) < n {
+			return nil, fmt.Errorf("tag %X length %d is larger than data(%d)", tag, n, len(rest))
+		}
+		t := TLV{Tag: strings.ToUpper(hex.EncodeToString(tag)), Value: rest[:n:n]}
+		if tag[0]&0x20 != 0 {
+			if t.Children, err = ParseTLV(t.Value); err != nil {
+				return nil, fmt.Errorf("tag %s %s", t.Tag, err.Error())
+			}
+		}
+		l = append(l, t)
+		data = rest[n:]
+	}
+}
+
+//Bytes encodes the data objects of l
+func (l TLVList) Bytes() ([]byte, error) {
+	var out []byte
+	for _, t := range l {
+		tag, err := parseTLVTagName(t.Tag)
+		if err != nil {
+			return nil, err
+		}
+		value := t.Value
+		if t.Children != nil {
+			if value, err = t.Children.Bytes(); err != nil {
+				return nil, fmt.Errorf("tag %s %s", t.Tag, err.Error())
+			}
+		}
+		if out, err = appendTLV(out, tag, value); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+//Find returns the first data object of tag in l or in the children of its constructed tags
+func (l TLVList) Find(tag string) (TLV, bool) {
+	for _, t := range l {
+		if strings.EqualFold(t.Tag, tag) {
+			return t, true
+		}
+		if found, ok := t.Children.Find(tag); ok {
+			return found, true
+		}
+	}
+	return TLV{}, false
+}
+
+//MarshalISO8583Field implements FieldMarshaler
+func (l TLVList) MarshalISO8583Field(tag FieldTag) ([]byte, error) {
+	return l.Bytes()
+}
+
+//UnmarshalISO8583Field implements FieldUnmarshaler
+func (l *TLVList) UnmarshalISO8583Field(tag FieldTag, data []byte) error {
+	parsed, err := ParseTLV(append([]byte(nil), data...))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+//readTLVTag reads a tag of one or more bytes, a first byte with the low five bits set is followed
+//by bytes whose high bit is set until the last byte of the tag
+func readTLVTag(data []byte) ([]byte, []byte, error) {
+	if data[0]&0x1f != 0x1f {
+		return data[:1], data[1:], nil
+	}
+	for i := 1; i < len(data); i++ {
+		if data[i]&0x80 == 0 {
+			return data[:i+1], data[i+1:], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("tag %X is not complete", data)
+}
+
+//readTLVLength reads a short form length below 0x80 or a long form length of up to four bytes
+func readTLVLength(data []byte) (int, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, fmt.Errorf("length is missing")
+	}
+	if data[0] < 0x80 {
+		return int(data[0]), data[1:], nil
+	}
+	size := int(data[0] & 0x7f)
+	if size == 0 || size > 4 {
+		return 0, nil, fmt.Errorf("length form %02X is not supported", data[0])
+	}
+	if len(data) < 1+size {
+		return 0, nil, fmt.Errorf("length of %d bytes is not complete", size)
+	}
+	var n int
+	for _, b := range data[1 : 1+size] {
+		n = n<<8 | int(b)
+	}
+	return n, data[1+size:], nil
+}
+
+//parseTLVTagName parses a tag in hex and checks it is one complete tag
+func parseTLVTagName(s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("Unsupport tlv tag %s", s)
+	}
+	tag, rest, err := readTLVTag(b)
+	if err != nil || len(rest) > 0 || len(tag) != len(b) {
+		return nil, fmt.Errorf("Unsupport tlv tag %s", s)
+	}
+	return b, nil
+}
+
+func appendTLV(out []byte, tag []byte, value []byte) ([]byte, error) {
+	out = append(out, tag...)
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	case n <= 0xffff:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	case n <= 0xffffff:
+		out = append(out, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	default:
+		return nil, fmt.Errorf("tag %X value(%d) is too long", tag, n)
+	}
+	return append(out, value...), nil
+}
+
+//tlvCodec is a three digit length prefixed BER-TLV value, the length counts the bytes of the data objects
+type tlvCodec struct{}
+
+func (tlvCodec) Encode(tag FieldTag, value []byte) ([]byte, error) {
+	maxLength := 999
+	if tag.Length > 0 {
+		maxLength = tag.Length
+	}
+	if len(value) > maxLength {
+		return nil, fmt.Errorf("value(%d) is larger than %d", len(value), maxLength)
+	}
+	if _, err := ParseTLV(value); err != nil {
+		return nil, err
+	}
+	contentLen := []byte(fmt.Sprintf("%03d", len(value)))
+	switch tag.LengthEncode {
+	case "ascii":
+		return append(contentLen, value...), nil
+	case "bcd", "rbcd":
+		lenVal, err := rbcdEncode(contentLen)
+		if err != nil {
+			return nil, err
+		}
+		return append(lenVal, value...), nil
+	}
+	return nil, fmt.Errorf("length encode %s is invalid", tag.LengthEncode)
+}
+
+func (tlvCodec) Decode(tag FieldTag, data []byte) ([]byte, []byte, error) {
+	var contentLen int
+	var err error
+	switch tag.LengthEncode {
+	case "ascii":
+		if len(data) < 3 {
+			return nil, nil, fmt.Errorf("ascii data length is too small")
+		}
+		contentLen, err = strconv.Atoi(string(data[:3]))
+		data = data[3:]
+	case "bcd", "rbcd":
+		if len(data) < 2 {
+			return nil, nil, fmt.Errorf("bcd data length is too small")
+		}
+		contentLen, err = strconv.Atoi(string(bcdr2Ascii(data[:2], 3)))
+		data = data[2:]
+	default:
+		return nil, nil, fmt.Errorf("length encode %s is invalid", tag.LengthEncode)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing length failed %s", err.Error())
+	}
+	if len(data) < contentLen {
+		return nil, nil, fmt.Errorf("data length(%d) is smaller than %d", len(data), contentLen)
+	}
+	if _, err := ParseTLV(data[:contentLen]); err != nil {
+		return nil, nil, err
+	}
+	return data[:contentLen], data[contentLen:], nil
+}
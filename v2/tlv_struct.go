@@ -0,0 +1,306 @@
+package iso8583v2
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type tlvFormat int
+
+const (
+	tlvWord = "tlv"
+)
+
+const (
+	//tlvBinary is format b, the bytes of a []byte or the hex of a string
+	tlvBinary tlvFormat = iota + 1
+	//tlvNumeric is format n, right justified bcd digits padded with 0 on the left
+	tlvNumeric
+	//tlvCompressed is format cn, left justified bcd digits padded with F on the right
+	tlvCompressed
+	//tlvText is format a, an or ans, the text as it is
+	tlvText
+	//tlvConstructed holds the data objects of a struct or a TLVList
+	tlvConstructed
+)
+
+//tlvTag is a sub field of a tlv struct such as tlv:"9F02,n12", the format after the tag
+//is b, n, cn, a, an or ans and n and cn take the number of digits
+type tlvTag struct {
+	name   string
+	tag    []byte
+	format tlvFormat
+	digits int
+}
+
+var tlvListType = reflect.TypeOf(TLVList(nil))
+
+func cachedTLVTag(t reflect.Type) map[string]*tlvTag {
+	if tag, ok := tlvTagCache.Load(t); ok {
+		return tag.(map[string]*tlvTag)
+	}
+	tag, _ := tlvTagCache.LoadOrStore(t, loadTLVTag(t))
+	return tag.(map[string]*tlvTag)
+}
+
+func loadTLVTag(typ reflect.Type) map[string]*tlvTag {
+	mp := make(map[string]*tlvTag)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		raw, ok := f.Tag.Lookup(tlvWord)
+		if !ok {
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Slice && ft != tlvListType && ft.Elem().Kind() != reflect.Uint8 {
+			continue
+		}
+		t, err := parseTLVTag(f.Name, raw, ft.Kind(), ft == tlvListType)
+		if err != nil {
+			continue
+		}
+		mp[f.Name] = &t
+	}
+	return mp
+}
+
+//parseTLVTag parses the tlv tag of a sub field whose type, or the type it points to, is of kind,
+//list is set for a TLVList and any other slice is a []byte
+func parseTLVTag(name string, raw string, kind reflect.Kind, list bool) (t tlvTag, err error) {
+	t.name = name
+	tag, format, _ := strings.Cut(raw, ",")
+	if t.tag, err = parseTLVTagName(strings.TrimSpace(tag)); err != nil {
+		return
+	}
+	format = strings.ToLower(strings.TrimSpace(format))
+	if list || kind == reflect.Struct {
+		if format != "" {
+			return t, fmt.Errorf("tlv format %s is not applied to a constructed tag", format)
+		}
+		if t.tag[0]&0x20 == 0 {
+			return t, fmt.Errorf("tlv tag %X of a struct or TLVList is not constructed", t.tag)
+		}
+		t.format = tlvConstructed
+		return
+	}
+	if format == "" {
+		switch {
+		case kind == reflect.Slice:
+			format = "b"
+		case kind == reflect.String:
+			format = "ans"
+		case isIntKind(kind):
+			format = "n"
+		}
+	}
+	switch {
+	case format == "b":
+		t.format = tlvBinary
+	case format == "a" || format == "an" || format == "ans":
+		t.format = tlvText
+	case strings.HasPrefix(format, "cn"):
+		t.format = tlvCompressed
+		format = format[2:]
+	case strings.HasPrefix(format, "n"):
+		t.format = tlvNumeric
+		format = format[1:]
+	default:
+		return t, fmt.Errorf("Unsupport tlv format %s", format)
+	}
+	if t.format == tlvNumeric || t.format == tlvCompressed {
+		if format != "" {
+			if t.digits, err = strconv.Atoi(format); err != nil || t.digits <= 0 {
+				return t, fmt.Errorf("tlv digits %s is not a positive number", format)
+			}
+		}
+	}
+	switch t.format {
+	case tlvBinary, tlvText:
+		if kind == reflect.String || kind == reflect.Slice {
+			return t, nil
+		}
+	case tlvNumeric, tlvCompressed:
+		if kind == reflect.String || isIntKind(kind) {
+			return t, nil
+		}
+	}
+	return t, fmt.Errorf("tlv tag %X format does not support %s", t.tag, kind)
+}
+
+//encodeTLVStruct encodes the tagged fields of v as data objects in field order,
+//zero values are left out unless a pointer is set
+func encodeTLVStruct(v reflect.Value) ([]byte, error) {
+	tags := cachedTLVTag(v.Type())
+	var out []byte
+	for i := 0; i < v.NumField(); i++ {
+		t := tags[v.Type().Field(i).Name]
+		if t == nil {
+			continue
+		}
+		value, ok, err := t.encode(v.Field(i))
+		if err != nil {
+			return nil, fmt.Errorf("subfield:%s %s", t.name, err.Error())
+		}
+		if !ok {
+			continue
+		}
+		if out, err = appendTLV(out, t.tag, value); err != nil {
+			return nil, fmt.Errorf("subfield:%s %s", t.name, err.Error())
+		}
+	}
+	return out, nil
+}
+
+func (t tlvTag) encode(v reflect.Value) ([]byte, bool, error) {
+	set := false
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, false, nil
+		}
+		set, v = true, v.Elem()
+	}
+	var b []byte
+	var err error
+	switch t.format {
+	case tlvConstructed:
+		if v.Type() == tlvListType {
+			b, err = v.Interface().(TLVList).Bytes()
+		} else {
+			b, err = encodeTLVStruct(v)
+		}
+	case tlvBinary:
+		if v.Kind() == reflect.String {
+			b, err = hex.DecodeString(v.String())
+		} else {
+			b = v.Bytes()
+		}
+	case tlvText:
+		if v.Kind() == reflect.String {
+			b = []byte(v.String())
+		} else {
+			b = v.Bytes()
+		}
+	case tlvNumeric, tlvCompressed:
+		b, err = t.encodeDigits(v, set)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(b) == 0 && !set {
+		return nil, false, nil
+	}
+	return b, true, nil
+}
+
+func (t tlvTag) encodeDigits(v reflect.Value, set bool) ([]byte, error) {
+	var digits string
+	if v.Kind() == reflect.String {
+		digits = v.String()
+	} else if v.Int() != 0 || set {
+		digits = strconv.FormatInt(v.Int(), 10)
+	}
+	if digits == "" {
+		return nil, nil
+	}
+	if err := attributeClass("n").check([]byte(digits)); err != nil {
+		return nil, err
+	}
+	if t.digits > 0 {
+		if len(digits) > t.digits {
+			return nil, fmt.Errorf("value(%d) has more than %d digits", len(digits), t.digits)
+		}
+		if t.format == tlvNumeric {
+			digits = strings.Repeat("0", t.digits-len(digits)) + digits
+		}
+	}
+	if t.format == tlvCompressed {
+		return lbcdPadEncode([]byte(digits), 'F')
+	}
+	return rbcdEncode([]byte(digits))
+}
+
+//decodeTLVStruct sets the tagged fields of v from the first data object of their tag in data,
+//fields without a data object are left unchanged
+func decodeTLVStruct(v reflect.Value, data []byte) error {
+	l, err := ParseTLV(data)
+	if err != nil {
+		return err
+	}
+	tags := cachedTLVTag(v.Type())
+	for i := 0; i < v.NumField(); i++ {
+		t := tags[v.Type().Field(i).Name]
+		if t == nil {
+			continue
+		}
+		name := strings.ToUpper(hex.EncodeToString(t.tag))
+		for _, obj := range l {
+			if obj.Tag != name {
+				continue
+			}
+			if err := t.decode(v.Field(i), obj); err != nil {
+				return fmt.Errorf("subfield:%s %s", t.name, err.Error())
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func (t tlvTag) decode(v reflect.Value, obj TLV) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	switch t.format {
+	case tlvConstructed:
+		if v.Type() == tlvListType {
+			v.Set(reflect.ValueOf(obj.Children))
+			return nil
+		}
+		return decodeTLVStruct(v, obj.Value)
+	case tlvBinary:
+		if v.Kind() == reflect.String {
+			v.SetString(strings.ToUpper(hex.EncodeToString(obj.Value)))
+		} else {
+			v.SetBytes(append([]byte(nil), obj.Value...))
+		}
+		return nil
+	case tlvText:
+		if v.Kind() == reflect.String {
+			v.SetString(string(obj.Value))
+		} else {
+			v.SetBytes(append([]byte(nil), obj.Value...))
+		}
+		return nil
+	}
+	digits := strings.ToUpper(string(bcd2Ascii(obj.Value)))
+	if t.format == tlvCompressed {
+		digits = strings.TrimRight(digits, "F")
+	} else if t.digits > 0 && len(digits) > t.digits {
+		digits = digits[len(digits)-t.digits:]
+	}
+	if err := attributeClass("n").check([]byte(digits)); err != nil {
+		return err
+	}
+	if v.Kind() == reflect.String {
+		v.SetString(digits)
+		return nil
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return err
+	}
+	if v.OverflowInt(n) {
+		return fmt.Errorf("value %d overflows %s", n, v.Type())
+	}
+	v.SetInt(n)
+	return nil
+}
@@ -0,0 +1,234 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//Track2 is the track 2 equivalent data of field 35, a field of type track2 holds a Track2 or the whole track as a string.
+//Expiry is YYMM, Expiry and ServiceCode are empty when the track leaves them out
+type Track2 struct {
+	PAN           string
+	Expiry        string
+	ServiceCode   string
+	Discretionary string
+}
+
+const (
+	track2Separator = '='
+	//track2MaxLength is the longest track 2 of ISO 7813 without the sentinels and the lrc
+	track2MaxLength = 37
+)
+
+func init() {
+	if err := RegisterFieldType("track2", track2Codec{}); err != nil {
+		panic(err)
+	}
+}
+
+//ParseTrack2 parses track 2 data with D or = as the separator. After the separator
+//a single separator in place of the expiry or the service code means it is left out
+func ParseTrack2(s string) (Track2, error) {
+	s = translateTrack2(s, track2Separator)
+	pan, rest, ok := strings.Cut(s, string(track2Separator))
+	if !ok {
+		return Track2{}, fmt.Errorf("track 2 separator not found")
+	}
+	var t Track2
+	t.PAN = pan
+	var err error
+	if t.Expiry, rest, err = cutTrack2(rest, 4, "expiry"); err != nil {
+		return Track2{}, err
+	}
+	if t.ServiceCode, rest, err = cutTrack2(rest, 3, "service code"); err != nil {
+		return Track2{}, err
+	}
+	t.Discretionary = rest
+	if err := t.check(); err != nil {
+		return Track2{}, err
+	}
+	return t, nil
+}
+
+//cutTrack2 cuts the n digits of name from the front of s, or the separator that leaves name out
+func cutTrack2(s string, n int, name string) (string, string, error) {
+	if s == "" {
+		return "", "", nil
+	}
+	if s[0] == track2Separator {
+		return "", s[1:], nil
+	}
+	if len(s) < n {
+		return "", "", fmt.Errorf("track 2 %s %q is shorter than %d digits", name, s, n)
+	}
+	return s[:n], s[n:], nil
+}
+
+func (t Track2) check() error {
+	if t.PAN == "" || len(t.PAN) > 19 {
+		return fmt.Errorf("track 2 pan length %d is not between 1 and 19", len(t.PAN))
+	}
+	for _, part := range []struct {
+		name, value string
+		length      int
+	}{
+		{"pan", t.PAN, 0},
+		{"expiry", t.Expiry, 4},
+		{"service code", t.ServiceCode, 3},
+		{"discretionary data", t.Discretionary, 0},
+	} {
+		if part.length > 0 && part.value != "" && len(part.value) != part.length {
+			return fmt.Errorf("track 2 %s %q must be %d digits", part.name, part.value, part.length)
+		}
+		for i := 0; i < len(part.value); i++ {
+			if part.value[i] < '0' || part.value[i] > '9' {
+				return fmt.Errorf("track 2 %s character %q at %d is not a digit", part.name, part.value[i], i)
+			}
+		}
+	}
+	return nil
+}
+
+//String returns the track with = as the separator
+func (t Track2) String() string {
+	s := t.PAN + string(track2Separator)
+	if t.Expiry == "" && (t.ServiceCode != "" || t.Discretionary != "") {
+		s += string(track2Separator)
+	}
+	s += t.Expiry
+	if t.ServiceCode == "" && t.Discretionary != "" {
+		s += string(track2Separator)
+	}
+	return s + t.ServiceCode + t.Discretionary
+}
+
+//MarshalText implements encoding.TextMarshaler
+func (t Track2) MarshalText() ([]byte, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	return []byte(t.String()), nil
+}
+
+//UnmarshalText implements encoding.TextUnmarshaler
+func (t *Track2) UnmarshalText(text []byte) error {
+	v, err := ParseTrack2(string(text))
+	if err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
+//translateTrack2 replaces the separators D and = of s with sep
+func translateTrack2(s string, sep byte) string {
+	return strings.Map(func(r rune) rune {
+		if r == 'D' || r == 'd' || r == '=' {
+			return rune(sep)
+		}
+		return r
+	}, s)
+}
+
+//track2Codec is a two digit length prefixed track 2, the length counts the characters of the track.
+//An ascii value has = as the separator, a bcd value has the nibble D and pads an odd length with F
+type track2Codec struct{}
+
+func (track2Codec) Encode(tag FieldTag, value []byte) ([]byte, error) {
+	maxLength := track2MaxLength
+	if tag.Length > 0 {
+		maxLength = tag.Length
+	}
+	if len(value) > maxLength {
+		return nil, fmt.Errorf("value(%d) is larger than %d", len(value), maxLength)
+	}
+	sep := byte(track2Separator)
+	if tag.ValueEncode != "ascii" {
+		sep = 'D'
+	}
+	text := []byte(translateTrack2(string(value), sep))
+	for i, c := range text {
+		if (c < '0' || c > '9') && c != sep {
+			return nil, fmt.Errorf("character %q at %d is not a digit or separator", c, i)
+		}
+	}
+	contentLen := []byte(fmt.Sprintf("%02d", len(text)))
+	var ret []byte
+	switch tag.LengthEncode {
+	case "ascii":
+		ret = contentLen
+	case "bcd", "rbcd":
+		lenVal, err := rbcdEncode(contentLen)
+		if err != nil {
+			return nil, err
+		}
+		ret = lenVal
+	default:
+		return nil, fmt.Errorf("length encode %s is invalid", tag.LengthEncode)
+	}
+	switch tag.ValueEncode {
+	case "ascii":
+		return append(ret, text...), nil
+	case "bcd":
+		b, err := lbcdPadEncode(text, 'F')
+		if err != nil {
+			return nil, err
+		}
+		return append(ret, b...), nil
+	case "rbcd":
+		b, err := rbcdPadEncode(text, 'F')
+		if err != nil {
+			return nil, err
+		}
+		return append(ret, b...), nil
+	}
+	return nil, fmt.Errorf("value encode %s is invalid", tag.ValueEncode)
+}
+
+func (track2Codec) Decode(tag FieldTag, data []byte) ([]byte, []byte, error) {
+	var contentLen int
+	var err error
+	switch tag.LengthEncode {
+	case "ascii":
+		if len(data) < 2 {
+			return nil, nil, fmt.Errorf("ascii data length is too small")
+		}
+		contentLen, err = strconv.Atoi(string(data[:2]))
+		data = data[2:]
+	case "bcd", "rbcd":
+		if len(data) < 1 {
+			return nil, nil, fmt.Errorf("bcd data length is too small")
+		}
+		contentLen, err = strconv.Atoi(string(bcdr2Ascii(data[:1], 2)))
+		data = data[1:]
+	default:
+		return nil, nil, fmt.Errorf("length encode %s is invalid", tag.LengthEncode)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing length failed %s", err.Error())
+	}
+	var val []byte
+	switch tag.ValueEncode {
+	case "ascii":
+		if len(data) < contentLen {
+			return nil, nil, fmt.Errorf("data length(%d) is smaller than %d", len(data), contentLen)
+		}
+		val, data = data[:contentLen], data[contentLen:]
+	case "bcd", "rbcd":
+		l := (contentLen + 1) / 2
+		if len(data) < l {
+			return nil, nil, fmt.Errorf("data length(%d) is smaller than %d", len(data), l)
+		}
+		if tag.ValueEncode == "bcd" {
+			val = bcdl2Ascii(data[:l], contentLen)
+		} else {
+			val = bcdr2Ascii(data[:l], contentLen)
+		}
+		val, data = bytes.ToUpper(val), data[l:]
+	default:
+		return nil, nil, fmt.Errorf("value encode %s is invalid", tag.ValueEncode)
+	}
+	return []byte(translateTrack2(string(val), track2Separator)), data, nil
+}
@@ -0,0 +1,74 @@
+package iso8583v2
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+	"unicode"
+)
+
+//TrimPolicy is the white space a decoded text value drops
+type TrimPolicy int32
+
+const (
+	//TrimNone keeps the value as it is on the wire
+	TrimNone TrimPolicy = iota + 1
+	//TrimRight drops trailing white space
+	TrimRight
+	//TrimLeft drops leading white space
+	TrimLeft
+	//TrimBoth drops leading and trailing white space
+	TrimBoth
+)
+
+//defaultTrim is the policy of fields and sub fields without a trim tag
+var defaultTrim = int32(TrimBoth)
+
+//SetDefaultTrim sets p as the policy of numeric and alpha fields and of sub fields without a trim tag,
+//llvar and lllvar fields only trim with a tag. The default is TrimBoth, a tag of trim:"none" keeps
+//a field as it is on the wire
+func SetDefaultTrim(p TrimPolicy) error {
+	if p < TrimNone || p > TrimBoth {
+		return fmt.Errorf("Unsupport trim policy %d", p)
+	}
+	atomic.StoreInt32(&defaultTrim, int32(p))
+	return nil
+}
+
+//DefaultTrim returns the policy of fields and sub fields without a trim tag
+func DefaultTrim() TrimPolicy {
+	return TrimPolicy(atomic.LoadInt32(&defaultTrim))
+}
+
+func (p TrimPolicy) apply(b []byte) []byte {
+	switch p {
+	case TrimRight:
+		return bytes.TrimRightFunc(b, unicode.IsSpace)
+	case TrimLeft:
+		return bytes.TrimLeftFunc(b, unicode.IsSpace)
+	case TrimBoth:
+		return bytes.TrimSpace(b)
+	}
+	return b
+}
+
+//trimValue trims a decoded value of t by its trim tag, numeric and alpha fields without one use the default
+func (t iso8583Tag) trimValue(b []byte) []byte {
+	p := t.trim
+	if p == 0 {
+		if t.fieldType != numeric && t.fieldType != alpha {
+			return b
+		}
+		p = DefaultTrim()
+	}
+	return p.apply(b)
+}
+
+//trimValue trims a decoded sub field value by its trim tag or the default
+func (t fixedwidthTag) trimValue(b []byte) []byte {
+	p := t.trim
+	if p == 0 {
+		p = DefaultTrim()
+	}
+	return p.apply(b)
+}
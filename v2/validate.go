@@ -0,0 +1,484 @@
+package iso8583v2
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//TagField describes a struct field for CheckMessageTags,
+//so tools without the reflect.Type of a message such as vet analyzers can validate its tags
+type TagField struct {
+	Name string
+	Tag  string
+	//Kind is the kind of the field type, Elem is the kind a pointer points to or a slice holds
+	Kind reflect.Kind
+	Elem reflect.Kind
+	//Marshaler is set when the type implements FieldMarshaler or encoding.TextMarshaler
+	Marshaler bool
+	//Fields are the fields of a struct, or of the struct a pointer points to
+	Fields []TagField
+}
+
+//TagError is a struct tag problem found by CheckMessageTags
+type TagError struct {
+	Field    string
+	Subfield string
+	Err      string
+}
+
+func (e *TagError) Error() string {
+	if e.Subfield != "" {
+		return fmt.Sprintf("field:%s subfield:%s %s", e.Field, e.Subfield, e.Err)
+	}
+	return fmt.Sprintf("field:%s %s", e.Field, e.Err)
+}
+
+//ValidationError holds every tag problem of a message type
+type ValidationError struct {
+	Type   reflect.Type
+	Errors []*TagError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid tags of %v: %s", e.Type, strings.Join(msgs, "; "))
+}
+
+//dictionaryKey -> error, nil errors are stored as validTags
+var validatedTypes sync.Map
+
+type validTags struct{}
+
+//Validate reports every struct tag of T that Marshal and Unmarshal would ignore or reject,
+//the error is a *ValidationError when T is a struct
+func Validate[T any]() error {
+	return validateType(reflect.TypeOf((*T)(nil)).Elem(), nil)
+}
+
+//MustRegister validates T and loads its tags ahead of the first message,
+//it panics when T has invalid tags
+func MustRegister[T any]() {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if err := validateType(typ, nil); err != nil {
+		panic(err)
+	}
+	cachedTag(typ)
+}
+
+//validateType checks typ with the dictionary d, or the one its mti names when d is nil, once and caches the result.
+//The result with an unregistered dictionary is not cached
+func validateType(typ reflect.Type, d *Dictionary) error {
+	if d != nil && !d.registered() {
+		return checkType(typ, d)
+	}
+	key := dictionaryKey{typ: typ, dict: d}
+	if res, ok := validatedTypes.Load(key); ok {
+		if err, isErr := res.(error); isErr {
+			return err
+		}
+		return nil
+	}
+	err := checkType(typ, d)
+	if err != nil {
+		validatedTypes.Store(key, err)
+	} else {
+		validatedTypes.Store(key, validTags{})
+	}
+	return err
+}
+
+func checkType(typ reflect.Type, d *Dictionary) error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("not support for not struct type %v", typ)
+	}
+	fields := describeFields(typ)
+	errs := checkMessageTags(fields, d)
+	hasMti := false
+	for _, f := range fields {
+		tag, _ := expandTag(reflect.StructTag(f.Tag), false)
+		hasMti = hasMti || isMtiField(f.Name, tag)
+	}
+	if !hasMti {
+		errs = append([]*TagError{{Field: mtiWord, Err: "field is required"}}, errs...)
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Type: typ, Errors: errs}
+	}
+	return nil
+}
+
+//describeFields builds the TagFields of a struct type, struct fields are described one level down
+func describeFields(typ reflect.Type) []TagField {
+	fields := make([]TagField, typ.NumField())
+	for i := range fields {
+		fields[i] = describeField(typ.Field(i))
+		if _, isStruct := fixedwidthFields(fields[i]); isStruct {
+			st := typ.Field(i).Type
+			if st.Kind() == reflect.Ptr {
+				st = st.Elem()
+			}
+			for j := 0; j < st.NumField(); j++ {
+				fields[i].Fields = append(fields[i].Fields, describeField(st.Field(j)))
+			}
+		}
+	}
+	return fields
+}
+
+func describeField(f reflect.StructField) TagField {
+	tf := TagField{
+		Name:      f.Name,
+		Tag:       string(f.Tag),
+		Kind:      f.Type.Kind(),
+		Marshaler: implements(f.Type, fieldMarshalerType) || implements(f.Type, textMarshalerType),
+	}
+	if tf.Kind == reflect.Ptr || tf.Kind == reflect.Slice {
+		tf.Elem = f.Type.Elem().Kind()
+	}
+	return tf
+}
+
+var (
+	messageTagWords = []string{namespaceWord, fieldWord, typeWord, lengthWord, encodeWord, codepageWord, cpPolicyWord, bitmapsizeWord, dictWord, classWord, padWord, trimWord}
+	fixedTagWords   = []string{namespaceWord, fixedFieldWord, fixedLengthWord, fixedCodepageWord, fixedCpPolicyWord, fixedClassWord, fixedPadWord, fixedTrimWord}
+)
+
+//CheckMessageTags reports the tags of a message that Marshal and Unmarshal would ignore or reject.
+//Fields without any tag of this library and not named mti are not message fields and are not reported
+func CheckMessageTags(fields []TagField) []*TagError {
+	return checkMessageTags(fields, nil)
+}
+
+//checkMessageTags resolves untyped tags with override, or with the dictionary the mti names when override is nil
+func checkMessageTags(fields []TagField, override *Dictionary) []*TagError {
+	var errs []*TagError
+	var mti string
+	numbers := make(map[int]string)
+	names := make([]string, len(fields))
+	tags := make([]reflect.StructTag, len(fields))
+	for i, f := range fields {
+		names[i], tags[i] = f.Name, reflect.StructTag(f.Tag)
+	}
+	//an unknown dictionary is reported on the mti
+	d := override
+	if d == nil {
+		d, _ = messageDictionary(names, tags)
+	}
+	for _, f := range fields {
+		if !isMtiName(f.Name) && !hasTagWord(f.Tag, messageTagWords) {
+			continue
+		}
+		fieldErr := func(format string, args ...interface{}) {
+			errs = append(errs, &TagError{Field: f.Name, Err: fmt.Sprintf(format, args...)})
+		}
+		tag, err := expandTag(reflect.StructTag(f.Tag), false)
+		if err != nil {
+			fieldErr("%s", err.Error())
+			continue
+		}
+		if !isMtiField(f.Name, tag) {
+			if tag, err = fieldDictionaryTag(tag, d); err != nil {
+				fieldErr("%s", err.Error())
+				continue
+			}
+		}
+		f.Tag = string(tag)
+		t, err := parseMessageTag(reflect.StructField{Name: f.Name, Tag: reflect.StructTag(f.Tag)}, d)
+		if err != nil {
+			fieldErr("%s", tagErrorText(f.Tag, err))
+			continue
+		}
+		if t.isMti {
+			if mti != "" {
+				fieldErr("is a second mti after field:%s", mti)
+			}
+			mti = f.Name
+			for _, msg := range checkMtiTag(f) {
+				fieldErr("%s", msg)
+			}
+			continue
+		}
+		if other, ok := numbers[t.field]; ok {
+			fieldErr("shares field number %d with field:%s", t.field, other)
+		} else {
+			numbers[t.field] = f.Name
+		}
+		for _, msg := range checkFieldTag(t, f) {
+			fieldErr("%s", msg)
+		}
+		if sub, ok := fixedwidthFields(f); ok && t.fieldType == tlvFieldType {
+			errs = append(errs, checkTLVTags(t, sub)...)
+		} else if ok && t.fieldType != binary {
+			errs = append(errs, checkFixedwidthTags(t, sub)...)
+		}
+	}
+	return errs
+}
+
+func isMtiName(name string) bool {
+	return strings.ToLower(name) == mtiWord
+}
+
+func hasTagWord(tag string, words []string) bool {
+	st := reflect.StructTag(tag)
+	for _, w := range words {
+		if _, ok := st.Lookup(w); ok {
+			return true
+		}
+	}
+	return false
+}
+
+//tagErrorText explains a tag the parser refused
+func tagErrorText(tag string, err error) string {
+	st := reflect.StructTag(tag)
+	if v, ok := st.Lookup(typeWord); ok {
+		if _, typeErr := parseType(v); typeErr != nil {
+			return fmt.Sprintf("type %q is not a built in or registered type", v)
+		}
+	}
+	if v, ok := st.Lookup(fieldWord); ok {
+		if _, numErr := strconv.Atoi(v); numErr != nil && !strings.EqualFold(v, mtiWord) {
+			return fmt.Sprintf("field number %q is not a number", v)
+		}
+	}
+	return err.Error()
+}
+
+func isEncodeWord(s string) bool {
+	switch strings.ToLower(s) {
+	case "ascii", "bcd", "lbcd", "rbcd":
+		return true
+	}
+	return false
+}
+
+func checkMtiTag(f TagField) []string {
+	if f.Marshaler {
+		return nil
+	}
+	switch f.Kind {
+	case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return nil
+	}
+	return []string{fmt.Sprintf("mti type must be string, integer or a text marshaler, got %s", typeText(f))}
+}
+
+func checkFieldTag(t iso8583Tag, f TagField) []string {
+	var msgs []string
+	st := reflect.StructTag(f.Tag)
+	if t.field < 1 || t.field > 128 {
+		msgs = append(msgs, fmt.Sprintf("field number %d is not between 1 and 128", t.field))
+	}
+	if v, ok := st.Lookup(lengthWord); ok {
+		if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+			msgs = append(msgs, fmt.Sprintf("length %q is not a positive number", v))
+		}
+	} else if t.fieldType == numeric || t.fieldType == alpha || t.fieldType == binary {
+		msgs = append(msgs, fmt.Sprintf("%s field requires a length", t.fieldType.value()))
+	}
+	if v, ok := st.Lookup(encodeWord); ok {
+		//a registered type reads the length encode of its tag for its own prefix
+		_, isCustom := lookupFieldCodec(t.fieldType)
+		enc := strings.Split(v, ",")
+		for _, e := range enc {
+			//an empty part is read as ascii
+			if e != "" && !isEncodeWord(e) {
+				msgs = append(msgs, fmt.Sprintf("encode %q is not ascii, bcd or rbcd", e))
+			}
+		}
+		switch {
+		case len(enc) > 2:
+			msgs = append(msgs, fmt.Sprintf("encode %q has more than a length and a value encode", v))
+		case len(enc) == 2 && t.fieldType != llvar && t.fieldType != lllvar && !isCustom:
+			msgs = append(msgs, fmt.Sprintf("encode %q sets a length encode but %s has no length prefix", v, t.fieldType.value()))
+		}
+	}
+	switch t.fieldType {
+	case llvar, lllvar:
+		if t.valEncode != ascii {
+			msgs = append(msgs, fmt.Sprintf("%s value encode must be ascii, got %s", t.fieldType.value(), t.valEncode.value()))
+		}
+	case alpha, binary:
+		if t.valEncode != ascii {
+			msgs = append(msgs, fmt.Sprintf("%s value encode must be ascii, got %s", t.fieldType.value(), t.valEncode.value()))
+		}
+	}
+	if t.codePage != defaultCp && (t.fieldType == numeric || t.fieldType == binary) {
+		msgs = append(msgs, fmt.Sprintf("cp %s is not applied when encoding a %s field", t.codePage.value(), t.fieldType.value()))
+	}
+	if t.pad.isSet() && t.fieldType != numeric && t.fieldType != alpha {
+		msgs = append(msgs, fmt.Sprintf("pad is only applied to numeric and alpha fields, got %s", t.fieldType.value()))
+	}
+	if t.trim != 0 && t.fieldType == binary {
+		msgs = append(msgs, "trim is not applied to a binary field")
+	}
+	if v, ok := st.Lookup(bitmapsizeWord); ok {
+		if n, err := strconv.Atoi(v); err != nil || n < 0 {
+			msgs = append(msgs, fmt.Sprintf("bitmapsize %q is not a number", v))
+		} else if _, isStruct := fixedwidthFields(f); !isStruct && n > 0 {
+			msgs = append(msgs, "bitmapsize is only used by struct fields")
+		}
+	}
+	return append(msgs, checkFieldKind(t, f)...)
+}
+
+//checkFieldKind reports Go kinds the encoder or decoder of t does not support
+func checkFieldKind(t iso8583Tag, f TagField) []string {
+	if f.Marshaler {
+		return nil
+	}
+	kind := f.Kind
+	if kind == reflect.Ptr {
+		kind = f.Elem
+	}
+	if t.fieldType == binary && !(f.Kind == reflect.Slice && f.Elem == reflect.Uint8) {
+		return []string{fmt.Sprintf("binary field must be []byte, got %s", typeText(f))}
+	}
+	switch kind {
+	case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+		return nil
+	case reflect.Slice:
+		//the element of a pointer to a slice is not described
+		if f.Kind == reflect.Ptr || f.Elem == reflect.Uint8 {
+			return nil
+		}
+	case reflect.Struct:
+		if _, custom := lookupFieldCodec(t.fieldType); custom || t.fieldType == llvar || t.fieldType == lllvar {
+			return nil
+		}
+		return []string{fmt.Sprintf("struct field must be llvar, lllvar or a registered type, got %s", t.fieldType.value())}
+	}
+	return []string{fmt.Sprintf("type %s is not supported", typeText(f))}
+}
+
+func typeText(f TagField) string {
+	switch f.Kind {
+	case reflect.Ptr:
+		return "pointer to " + f.Elem.String()
+	case reflect.Slice:
+		return "slice of " + f.Elem.String()
+	}
+	return f.Kind.String()
+}
+
+//fixedwidthFields returns the sub fields of a struct field, encoded by Marshal as fixed width
+func fixedwidthFields(f TagField) ([]TagField, bool) {
+	if f.Marshaler {
+		return nil, false
+	}
+	if f.Kind == reflect.Struct || (f.Kind == reflect.Ptr && f.Elem == reflect.Struct) {
+		return f.Fields, true
+	}
+	return nil, false
+}
+
+//checkTLVTags checks the sub fields of a tlv struct, only fields with a tlv tag are encoded
+func checkTLVTags(parent iso8583Tag, fields []TagField) []*TagError {
+	var errs []*TagError
+	tags := make(map[string]string)
+	for _, f := range fields {
+		raw, ok := reflect.StructTag(f.Tag).Lookup(tlvWord)
+		if !ok {
+			continue
+		}
+		subErr := func(format string, args ...interface{}) {
+			errs = append(errs, &TagError{Field: parent.name, Subfield: f.Name, Err: fmt.Sprintf(format, args...)})
+		}
+		kind, list := f.Kind, f.Kind == reflect.Slice && f.Elem == reflect.Struct && f.Marshaler
+		if kind == reflect.Ptr {
+			kind = f.Elem
+		}
+		if f.Kind == reflect.Slice && !list && f.Elem != reflect.Uint8 {
+			subErr("tlv field must be []byte or TLVList, got %s", typeText(f))
+			continue
+		}
+		t, err := parseTLVTag(f.Name, raw, kind, list)
+		if err != nil {
+			subErr("%s", err.Error())
+			continue
+		}
+		name := fmt.Sprintf("%X", t.tag)
+		if other, ok := tags[name]; ok {
+			subErr("shares tlv tag %s with subfield:%s", name, other)
+		} else {
+			tags[name] = f.Name
+		}
+	}
+	return errs
+}
+
+func checkFixedwidthTags(parent iso8583Tag, fields []TagField) []*TagError {
+	var errs []*TagError
+	numbers := make(map[int]string)
+	for _, f := range fields {
+		if !hasTagWord(f.Tag, fixedTagWords) {
+			continue
+		}
+		subErr := func(format string, args ...interface{}) {
+			errs = append(errs, &TagError{Field: parent.name, Subfield: f.Name, Err: fmt.Sprintf(format, args...)})
+		}
+		st, err := expandTag(reflect.StructTag(f.Tag), true)
+		if err != nil {
+			subErr("%s", err.Error())
+			continue
+		}
+		f.Tag = string(st)
+		if _, err := strconv.Atoi(st.Get(fixedFieldWord)); err != nil {
+			subErr("field number %q is not a number", st.Get(fixedFieldWord))
+			continue
+		}
+		if n, err := strconv.Atoi(st.Get(fixedLengthWord)); err != nil || n <= 0 {
+			subErr("length %q is not a positive number", st.Get(fixedLengthWord))
+			continue
+		}
+		t, err := parseFixedLengthTag(reflect.StructField{Name: f.Name, Tag: reflect.StructTag(f.Tag)})
+		if err != nil {
+			subErr("%s", err.Error())
+			continue
+		}
+		if t.field < 1 {
+			subErr("field number %d is below 1", t.field)
+		} else if parent.bitmapSize > 0 && t.field > parent.bitmapSize*8 {
+			subErr("field number %d does not fit bitmapsize %d", t.field, parent.bitmapSize)
+		}
+		if other, ok := numbers[t.field]; ok {
+			subErr("shares field number %d with subfield:%s", t.field, other)
+		} else {
+			numbers[t.field] = f.Name
+		}
+		if msg := checkFixedwidthKind(f); msg != "" {
+			subErr("%s", msg)
+		}
+	}
+	return errs
+}
+
+func checkFixedwidthKind(f TagField) string {
+	if f.Marshaler {
+		return ""
+	}
+	kind := f.Kind
+	if kind == reflect.Ptr {
+		kind = f.Elem
+	}
+	switch kind {
+	case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+		return ""
+	case reflect.Slice:
+		if f.Kind == reflect.Ptr || f.Elem == reflect.Uint8 {
+			return ""
+		}
+	}
+	return fmt.Sprintf("type %s is not supported in a fixed width field", typeText(f))
+}